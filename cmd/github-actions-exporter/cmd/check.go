@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"github-actions-exporter/pkg/server"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func checkCmd() *cobra.Command {
+	var (
+		checkArgs = server.DefaultArgs()
+	)
+	checkArgs.Verbose = false
+
+	checkCmd := &cobra.Command{
+		Use:          "check",
+		Short:        "Checks credentials and permissions needed by collectors",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("%q is an invalid argument", args[0])
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			err := server.Check(checkArgs, os.Stdout)
+			if err != nil {
+				log.Fatalf("Failed to run server.Check: %s\n", err.Error())
+			}
+		},
+	}
+
+	addGitHubFlags(checkCmd.PersistentFlags(), checkArgs)
+
+	if err := viper.BindPFlags(checkCmd.PersistentFlags()); err != nil {
+		log.Fatalf("Failed to execute check command: %s\n", err.Error())
+	}
+
+	return checkCmd
+}
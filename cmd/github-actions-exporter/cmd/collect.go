@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"github-actions-exporter/pkg/server"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func collectCmd() *cobra.Command {
+	var (
+		collectArgs = server.DefaultArgs()
+	)
+	collectArgs.Verbose = false
+
+	collectCmd := &cobra.Command{
+		Use:          "collect",
+		Short:        "Runs collectors once and prints metrics to stdout",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("%q is an invalid argument", args[0])
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			err := server.Collect(collectArgs, os.Stdout)
+			if err != nil {
+				log.Fatalf("Failed to run server.Collect: %s\n", err.Error())
+			}
+		},
+	}
+
+	collectCmd.PersistentFlags().StringVarP(
+		&collectArgs.OutputFormat,
+		"output",
+		"o",
+		collectArgs.OutputFormat,
+		"Output format (text, openmetrics or json)",
+	)
+	addGitHubFlags(collectCmd.PersistentFlags(), collectArgs)
+
+	if err := viper.BindPFlags(collectCmd.PersistentFlags()); err != nil {
+		log.Fatalf("Failed to execute collect command: %s\n", err.Error())
+	}
+
+	return collectCmd
+}
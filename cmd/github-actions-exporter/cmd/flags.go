@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"github-actions-exporter/pkg/server"
+
+	"github.com/spf13/pflag"
+)
+
+func addGitHubFlags(flags *pflag.FlagSet, args *server.Args) {
+	flags.BoolVarP(
+		&args.Verbose,
+		"verbose",
+		"",
+		args.Verbose,
+		"Verbose logging (same as --log-level=debug)",
+	)
+	flags.StringVarP(
+		&args.LogFormat,
+		"log-format",
+		"",
+		args.LogFormat,
+		"Log format (text or json)",
+	)
+	flags.StringVarP(
+		&args.LogLevel,
+		"log-level",
+		"",
+		args.LogLevel,
+		"Log level (debug, info or error), which overrides --verbose",
+	)
+	flags.StringSliceVarP(
+		&args.Repositories,
+		"repository",
+		"",
+		args.Repositories,
+		"GitHub Repository Names",
+	)
+	flags.StringVarP(
+		&args.Backend,
+		"backend",
+		"",
+		args.Backend,
+		"GitHub API to use (rest or graphql)",
+	)
+	flags.StringSliceVarP(
+		&args.Collectors,
+		"collector",
+		"",
+		args.Collectors,
+		"Collectors to enable",
+	)
+	flags.StringVarP(
+		&args.LabelPolicyFile,
+		"label-policy-file",
+		"",
+		args.LabelPolicyFile,
+		"Path to label policy file limiting cardinality of metrics",
+	)
+	flags.StringVarP(
+		&args.DeploymentWorkflowPattern,
+		"deployment-workflow",
+		"",
+		args.DeploymentWorkflowPattern,
+		"Regex matching name or path of workflows which deploy (a named group environment sets the environment)",
+	)
+	flags.BoolVarP(
+		&args.EnableDeploymentsAPI,
+		"enable-deployments-api",
+		"",
+		args.EnableDeploymentsAPI,
+		"Collect deployments from GitHub Deployments API",
+	)
+	flags.StringVarP(
+		&args.RequiredWorkflowPattern,
+		"required-workflow",
+		"",
+		args.RequiredWorkflowPattern,
+		"Regex matching name of workflows which are required checks of pull requests (all workflows if empty)",
+	)
+	flags.IntVarP(
+		&args.ActorTopN,
+		"actor-top-n",
+		"",
+		args.ActorTopN,
+		"Number of triggering actors with most runs exported individually (disabled if zero, requires --backend=rest)",
+	)
+	flags.StringVarP(
+		&args.StateStore,
+		"state-store",
+		"",
+		args.StateStore,
+		"Store to persist collector state (memory, file or configmap)",
+	)
+	flags.StringVarP(
+		&args.StateFile,
+		"state-file",
+		"",
+		args.StateFile,
+		"Path of state file used by file state store",
+	)
+	flags.StringVarP(
+		&args.StateConfigMap,
+		"state-configmap",
+		"",
+		args.StateConfigMap,
+		"Name of ConfigMap used by configmap state store",
+	)
+	flags.BoolVarP(
+		&args.EnableETagCache,
+		"enable-etag-cache",
+		"",
+		args.EnableETagCache,
+		"Enable conditional requests with ETag cached in state store",
+	)
+	flags.StringSliceVarP(
+		&args.Tokens,
+		"token",
+		"",
+		args.Tokens,
+		"GitHub Token (one per shard if several are given)",
+	)
+	flags.IntSliceVarP(
+		&args.AppIDs,
+		"app-id",
+		"",
+		args.AppIDs,
+		"GitHub App ID used instead of token (one per shard if several are given)",
+	)
+	flags.IntSliceVarP(
+		&args.AppInstallationIDs,
+		"app-installation-id",
+		"",
+		args.AppInstallationIDs,
+		"GitHub App installation ID (one per shard if several are given)",
+	)
+	flags.StringSliceVarP(
+		&args.AppPrivateKeyFiles,
+		"app-private-key-file",
+		"",
+		args.AppPrivateKeyFiles,
+		"Path of GitHub App private key (one per shard if several are given)",
+	)
+	flags.IntVarP(
+		&args.ShardIndex,
+		"shard-index",
+		"",
+		args.ShardIndex,
+		"Index of shard collected by this replica (derived from the ordinal of hostname if negative)",
+	)
+	flags.IntVarP(
+		&args.ShardCount,
+		"shard-count",
+		"",
+		args.ShardCount,
+		"Number of shards that repositories are distributed across",
+	)
+}
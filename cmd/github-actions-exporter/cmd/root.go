@@ -11,6 +11,8 @@ func GetRootCmd(args []string) *cobra.Command {
 
 	rootCmd.SetArgs(args)
 	rootCmd.AddCommand(serverCmd())
+	rootCmd.AddCommand(collectCmd())
+	rootCmd.AddCommand(checkCmd())
 
 	return rootCmd
 }
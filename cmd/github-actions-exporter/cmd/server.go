@@ -60,6 +60,13 @@ func serverCmd() *cobra.Command {
 		serverArgs.MonitorMaxConnections,
 		"Max connections of self-monitoring information",
 	)
+	serverCmd.PersistentFlags().StringVarP(
+		&serverArgs.WebConfigFile,
+		"web-config-file",
+		"",
+		serverArgs.WebConfigFile,
+		"Path to web config file enabling TLS and authentication of API and self-monitoring servers",
+	)
 	serverCmd.PersistentFlags().StringVarP(
 		&serverArgs.MonitoringJaegerEndpoint,
 		"monitoring-jaeger-endpoint",
@@ -88,6 +95,76 @@ func serverCmd() *cobra.Command {
 		serverArgs.TracingSampleRate,
 		"Tracing sample rate",
 	)
+	serverCmd.PersistentFlags().StringVarP(
+		&serverArgs.TracingExporter,
+		"tracing-exporter",
+		"",
+		serverArgs.TracingExporter,
+		"Exporter of distributed tracing (jaeger or otlp)",
+	)
+	serverCmd.PersistentFlags().StringVarP(
+		&serverArgs.OTLPEndpoint,
+		"otlp-endpoint",
+		"",
+		serverArgs.OTLPEndpoint,
+		"Base URL of OTLP/HTTP receiver to use for distributed tracing",
+	)
+	serverCmd.PersistentFlags().StringToStringVarP(
+		&serverArgs.OTLPHeaders,
+		"otlp-header",
+		"",
+		serverArgs.OTLPHeaders,
+		"Headers to send with OTLP requests (key=value)",
+	)
+	serverCmd.PersistentFlags().BoolVarP(
+		&serverArgs.EnableRunTraces,
+		"enable-run-traces",
+		"",
+		serverArgs.EnableRunTraces,
+		"Export completed workflow runs as traces through the tracing exporter (requires --backend=rest)",
+	)
+	serverCmd.PersistentFlags().StringVarP(
+		&serverArgs.RunTracesServiceName,
+		"run-traces-service-name",
+		"",
+		serverArgs.RunTracesServiceName,
+		"Service name of workflow run traces",
+	)
+	serverCmd.PersistentFlags().StringVarP(
+		&serverArgs.PushTarget,
+		"push-target",
+		"",
+		serverArgs.PushTarget,
+		"Target to push metrics to periodically (pushgateway, remote-write or otlp)",
+	)
+	serverCmd.PersistentFlags().StringVarP(
+		&serverArgs.PushEndpoint,
+		"push-endpoint",
+		"",
+		serverArgs.PushEndpoint,
+		"URL of Pushgateway, remote-write endpoint or OTLP/HTTP receiver",
+	)
+	serverCmd.PersistentFlags().StringVarP(
+		&serverArgs.PushJob,
+		"push-job",
+		"",
+		serverArgs.PushJob,
+		"Job name to push metrics to Pushgateway with",
+	)
+	serverCmd.PersistentFlags().Int64VarP(
+		&serverArgs.PushInterval,
+		"push-interval",
+		"",
+		serverArgs.PushInterval,
+		"Interval of pushing metrics",
+	)
+	serverCmd.PersistentFlags().StringToStringVarP(
+		&serverArgs.PushHeaders,
+		"push-header",
+		"",
+		serverArgs.PushHeaders,
+		"Headers to send with push requests (key=value)",
+	)
 	serverCmd.PersistentFlags().BoolVarP(
 		&serverArgs.KeepAlived,
 		"enable-keep-alived",
@@ -110,26 +187,41 @@ func serverCmd() *cobra.Command {
 		"Interval of TCP KeepAlive",
 	)
 	serverCmd.PersistentFlags().BoolVarP(
-		&serverArgs.Verbose,
-		"verbose",
+		&serverArgs.EnableLeaderElection,
+		"enable-leader-election",
 		"",
-		serverArgs.Verbose,
-		"Verbose logging",
+		serverArgs.EnableLeaderElection,
+		"Enable leader election so that only the leader polls GitHub",
 	)
 	serverCmd.PersistentFlags().StringVarP(
-		&serverArgs.Repository,
-		"repository",
+		&serverArgs.LeaderElectionLease,
+		"leader-election-lease",
 		"",
-		serverArgs.Repository,
-		"GitHub Repository Name",
+		serverArgs.LeaderElectionLease,
+		"Name of Lease used for leader election",
 	)
-	serverCmd.PersistentFlags().StringVarP(
-		&serverArgs.Token,
-		"token",
+	serverCmd.PersistentFlags().Int64VarP(
+		&serverArgs.LeaderElectionLeaseDuration,
+		"leader-election-lease-duration",
+		"",
+		serverArgs.LeaderElectionLeaseDuration,
+		"Duration that followers wait before acquiring leadership",
+	)
+	serverCmd.PersistentFlags().Int64VarP(
+		&serverArgs.LeaderElectionRenewDeadline,
+		"leader-election-renew-deadline",
+		"",
+		serverArgs.LeaderElectionRenewDeadline,
+		"Duration that the leader retries refreshing leadership before giving up",
+	)
+	serverCmd.PersistentFlags().Int64VarP(
+		&serverArgs.LeaderElectionRetryPeriod,
+		"leader-election-retry-period",
 		"",
-		serverArgs.Token,
-		"GitHub Token",
+		serverArgs.LeaderElectionRetryPeriod,
+		"Duration between leader election actions",
 	)
+	addGitHubFlags(serverCmd.PersistentFlags(), serverArgs)
 
 	if err := viper.BindPFlags(serverCmd.PersistentFlags()); err != nil {
 		log.Fatalf("Failed to execute server command: %s\n", err.Error())
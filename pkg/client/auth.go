@@ -0,0 +1,200 @@
+package client
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	appTokenExpiryMargin = 1 * time.Minute
+)
+
+type ITokenSource interface {
+	Token() (string, error)
+}
+
+type StaticTokenSource struct {
+	token string
+}
+
+func NewStaticTokenSource(token string) *StaticTokenSource {
+	return &StaticTokenSource{
+		token: token,
+	}
+}
+
+func (s *StaticTokenSource) Token() (string, error) {
+	return s.token, nil
+}
+
+type AppTokenSource struct {
+	appID          int64
+	installationID int64
+	privateKey     *rsa.PrivateKey
+	httpClient     IHTTPClient
+	mutex          sync.Mutex
+	token          string
+	expiresAt      time.Time
+	permissions    map[string]string
+}
+
+func NewAppTokenSource(appID int64, installationID int64, privateKeyPEM []byte, httpClient IHTTPClient) (*AppTokenSource, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, xerrors.New("failed to decode private key")
+	}
+	var privateKey *rsa.PrivateKey
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		privateKey = k
+	} else {
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse private key: %w", err)
+		}
+		rsaPrivateKey, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return nil, xerrors.New("private key is not RSA")
+		}
+		privateKey = rsaPrivateKey
+	}
+	return &AppTokenSource{
+		appID:          appID,
+		installationID: installationID,
+		privateKey:     privateKey,
+		httpClient:     httpClient,
+	}, nil
+}
+
+func (s *AppTokenSource) JWT() (string, error) {
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+	})
+	if err != nil {
+		return "", xerrors.Errorf("failed to marshal header: %w", err)
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-60 * time.Second).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": s.appID,
+	})
+	if err != nil {
+		return "", xerrors.Errorf("failed to marshal claims: %w", err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hashed := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", xerrors.Errorf("failed to sign: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (s *AppTokenSource) Token() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.token != "" && time.Now().Add(appTokenExpiryMargin).Before(s.expiresAt) {
+		return s.token, nil
+	}
+
+	jwt, err := s.JWT()
+	if err != nil {
+		return "", xerrors.Errorf("failed to create jwt: %w", err)
+	}
+	request, err := http.NewRequest("POST", fmt.Sprintf("https://api.github.com/app/installations/%d/access_tokens", s.installationID), nil)
+	if err != nil {
+		return "", xerrors.Errorf("failed to create request object: %w", err)
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", jwt))
+	request.Header.Set("Accept", "application/vnd.github+json")
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return "", xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var accessToken struct {
+		Token       string            `json:"token"`
+		ExpiresAt   time.Time         `json:"expires_at"`
+		Permissions map[string]string `json:"permissions"`
+	}
+	if err := json.Unmarshal(body, &accessToken); err != nil {
+		return "", xerrors.Errorf("failed to parse response: %w", err)
+	}
+	if accessToken.Token == "" {
+		return "", xerrors.Errorf("bad response: %s", string(body))
+	}
+
+	s.token = accessToken.Token
+	s.expiresAt = accessToken.ExpiresAt
+	s.permissions = accessToken.Permissions
+	return s.token, nil
+}
+
+func (s *AppTokenSource) Permissions() (map[string]string, error) {
+	if _, err := s.Token(); err != nil {
+		return nil, xerrors.Errorf("failed to get token: %w", err)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.permissions, nil
+}
+
+type AuthorizedHTTPClient struct {
+	TokenSource ITokenSource
+	Inner       IHTTPClient
+	mutex       sync.RWMutex
+	authErr     error
+}
+
+func (c *AuthorizedHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	token, err := c.TokenSource.Token()
+	if err != nil {
+		err = xerrors.Errorf("failed to get token: %w", err)
+		c.setAuthError(err)
+		return nil, err
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	response, err := c.Inner.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case response.StatusCode == http.StatusUnauthorized:
+		c.setAuthError(xerrors.Errorf("authentication is rejected by %s %s: %s", request.Method, request.URL.Path, response.Status))
+	case response.StatusCode < http.StatusBadRequest:
+		c.setAuthError(nil)
+	}
+	return response, nil
+}
+
+func (c *AuthorizedHTTPClient) AuthError() error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.authErr
+}
+
+func (c *AuthorizedHTTPClient) setAuthError(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.authErr = err
+}
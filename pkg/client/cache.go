@@ -0,0 +1,170 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ListingPaths are the listing endpoints of GitHub API whose responses are
+// worth caching with their ETags. Endpoints per run, deployment or commit are
+// left out because their URLs keep growing, and variables are left out
+// because their responses contain the values.
+var ListingPaths = []*regexp.Regexp{
+	regexp.MustCompile(`^/repos/[^/]+/[^/]+/actions/(runs|workflows|runners|secrets|organization-secrets)$`),
+	regexp.MustCompile(`^/repos/[^/]+/[^/]+/actions/workflows/[0-9]+/(runs|timing)$`),
+	regexp.MustCompile(`^/repos/[^/]+/[^/]+/actions/runners/downloads$`),
+	regexp.MustCompile(`^/repos/[^/]+/[^/]+/actions/permissions(/.*)?$`),
+	regexp.MustCompile(`^/repos/[^/]+/[^/]+/(pulls|deployments)$`),
+	regexp.MustCompile(`^/repos/[^/]+/[^/]+/contents/.+$`),
+}
+
+type ICache interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+}
+
+type IHTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+const (
+	// DefaultETagCacheMaxEntrySize is the default size limit of a cached response body.
+	DefaultETagCacheMaxEntrySize = 256 * 1024
+	// DefaultETagCacheTTL is the default time after which a cached response is fetched again.
+	DefaultETagCacheTTL = 24 * time.Hour
+)
+
+type cacheEntry struct {
+	ETag     string      `json:"etag"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	CachedAt time.Time   `json:"cached_at"`
+}
+
+type ETagCachingHTTPClient struct {
+	Cache  ICache
+	Inner  IHTTPClient
+	Logger ILogger
+	// Paths restricts caching to requests whose URL path matches any of
+	// them, as every cached URL is kept in the cache forever. All GET
+	// requests are cached if it is empty.
+	Paths []*regexp.Regexp
+	// MaxEntrySize skips caching responses whose body is larger than it.
+	// There is no limit if it is zero.
+	MaxEntrySize int
+	// TTL expires cached responses so that they are fetched again in full.
+	// They never expire if it is zero.
+	TTL time.Duration
+}
+
+func (c *ETagCachingHTTPClient) cacheable(request *http.Request) bool {
+	if request.Method != "GET" {
+		return false
+	}
+	if len(c.Paths) == 0 {
+		return true
+	}
+	for _, path := range c.Paths {
+		if path.MatchString(request.URL.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ETagCachingHTTPClient) cacheKey(request *http.Request) string {
+	sum := sha256.Sum256([]byte(request.URL.String()))
+	return "etag/" + hex.EncodeToString(sum[:])
+}
+
+func (c *ETagCachingHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	if !c.cacheable(request) {
+		return c.Inner.Do(request)
+	}
+
+	key := c.cacheKey(request)
+	var entry *cacheEntry
+	if b, err := c.Cache.Get(key); err == nil && b != nil {
+		if err := json.Unmarshal(b, &entry); err != nil || entry.ETag == "" || c.expired(entry) {
+			entry = nil
+		} else {
+			request.Header.Set("If-None-Match", entry.ETag)
+		}
+	}
+
+	response, err := c.Inner.Do(request)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
+	}
+
+	if response.StatusCode == http.StatusNotModified && entry != nil {
+		response.Body.Close()
+		header := entry.Header.Clone()
+		for k, v := range response.Header {
+			header[k] = v
+		}
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         response.Proto,
+			ProtoMajor:    response.ProtoMajor,
+			ProtoMinor:    response.ProtoMinor,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(entry.Body)),
+			ContentLength: int64(len(entry.Body)),
+			Request:       request,
+		}, nil
+	}
+
+	etag := response.Header.Get("ETag")
+	if response.StatusCode != http.StatusOK || etag == "" {
+		return response, nil
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if c.MaxEntrySize > 0 && len(body) > c.MaxEntrySize {
+		return response, nil
+	}
+
+	if err := c.put(key, &cacheEntry{
+		ETag:     etag,
+		Header:   response.Header,
+		Body:     body,
+		CachedAt: time.Now(),
+	}); err != nil {
+		c.Logger.Errorw("Failed to cache response",
+			"url", request.URL.String(),
+			"error", err.Error(),
+		)
+	}
+
+	return response, nil
+}
+
+func (c *ETagCachingHTTPClient) expired(entry *cacheEntry) bool {
+	return c.TTL > 0 && time.Since(entry.CachedAt) >= c.TTL
+}
+
+func (c *ETagCachingHTTPClient) put(key string, entry *cacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return xerrors.Errorf("failed to marshal cache entry: %w", err)
+	}
+	if err := c.Cache.Put(key, b); err != nil {
+		return xerrors.Errorf("failed to put cache entry: %w", err)
+	}
+	return nil
+}
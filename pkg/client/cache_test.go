@@ -0,0 +1,318 @@
+package client_test
+
+import (
+	"fmt"
+	"github-actions-exporter/pkg/client"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type cacheMock struct {
+	m map[string][]byte
+}
+
+func (c *cacheMock) Get(key string) ([]byte, error) {
+	return c.m[key], nil
+}
+
+func (c *cacheMock) Put(key string, value []byte) error {
+	c.m[key] = value
+	return nil
+}
+
+type etagTransport struct {
+	etag            string
+	body            string
+	gotIfNoneMatchs []string
+}
+
+func (t *etagTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.gotIfNoneMatchs = append(t.gotIfNoneMatchs, request.Header.Get("If-None-Match"))
+	if request.Header.Get("If-None-Match") == t.etag {
+		return &http.Response{
+			StatusCode: http.StatusNotModified,
+			Header:     http.Header{"Etag": {t.etag}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Etag": {t.etag}},
+		Body:       ioutil.NopCloser(strings.NewReader(t.body)),
+	}, nil
+}
+
+func TestETagCachingHTTPClientDo(t *testing.T) {
+	type want struct {
+		statusCode int
+		body       string
+	}
+
+	tests := []struct {
+		name                string
+		receiver            *client.ETagCachingHTTPClient
+		in                  string
+		wants               []want
+		wantGotIfNoneMatchs []string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			&client.ETagCachingHTTPClient{
+				Cache: &cacheMock{
+					m: make(map[string][]byte),
+				},
+				Inner: &http.Client{
+					Transport: &etagTransport{
+						etag: `"fake1"`,
+						body: "fake2",
+					},
+				},
+				Logger: client.NewDefaultLogger(),
+			},
+			"GET",
+			[]want{
+				{
+					http.StatusOK,
+					"fake2",
+				},
+				{
+					http.StatusOK,
+					"fake2",
+				},
+			},
+			[]string{"", `"fake1"`},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			&client.ETagCachingHTTPClient{
+				Cache: &cacheMock{
+					m: make(map[string][]byte),
+				},
+				Inner: &http.Client{
+					Transport: &etagTransport{
+						etag: `"fake1"`,
+						body: "fake2",
+					},
+				},
+				Logger: client.NewDefaultLogger(),
+			},
+			"POST",
+			[]want{
+				{
+					http.StatusOK,
+					"fake2",
+				},
+				{
+					http.StatusOK,
+					"fake2",
+				},
+			},
+			[]string{"", ""},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			&client.ETagCachingHTTPClient{
+				Cache: &cacheMock{
+					m: make(map[string][]byte),
+				},
+				Inner: &http.Client{
+					Transport: &etagTransport{
+						etag: `"fake1"`,
+						body: "fake2",
+					},
+				},
+				Logger:       client.NewDefaultLogger(),
+				MaxEntrySize: 4,
+			},
+			"GET",
+			[]want{
+				{
+					http.StatusOK,
+					"fake2",
+				},
+				{
+					http.StatusOK,
+					"fake2",
+				},
+			},
+			[]string{"", ""},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			&client.ETagCachingHTTPClient{
+				Cache: &cacheMock{
+					m: make(map[string][]byte),
+				},
+				Inner: &http.Client{
+					Transport: &etagTransport{
+						etag: `"fake1"`,
+						body: "fake2",
+					},
+				},
+				Logger: client.NewDefaultLogger(),
+				TTL:    time.Nanosecond,
+			},
+			"GET",
+			[]want{
+				{
+					http.StatusOK,
+					"fake2",
+				},
+				{
+					http.StatusOK,
+					"fake2",
+				},
+			},
+			[]string{"", ""},
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		receiver := tt.receiver
+		in := tt.in
+		wants := tt.wants
+		wantGotIfNoneMatchs := tt.wantGotIfNoneMatchs
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, want := range wants {
+				request, err := http.NewRequest(in, "http://example.com/", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				response, err := receiver.Do(request)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, err := ioutil.ReadAll(response.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(want.statusCode, response.StatusCode); diff != "" {
+					t.Errorf("(-want +got):\n%s", diff)
+				}
+				if diff := cmp.Diff(want.body, string(body)); diff != "" {
+					t.Errorf("(-want +got):\n%s", diff)
+				}
+			}
+			rt := receiver.Inner.(*http.Client).Transport.(*etagTransport)
+			if diff := cmp.Diff(wantGotIfNoneMatchs, rt.gotIfNoneMatchs); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestETagCachingHTTPClientDoListingPaths(t *testing.T) {
+	tests := []struct {
+		name                string
+		in                  string
+		wantGotIfNoneMatchs []string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			"https://api.github.com/repos/fake/fake/actions/runs?per_page=100&page=1",
+			[]string{"", `"fake1"`},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			"https://api.github.com/repos/fake/fake/actions/workflows/1/timing",
+			[]string{"", `"fake1"`},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			"https://api.github.com/repos/fake/fake/actions/runs/1/jobs?per_page=100&page=1",
+			[]string{"", ""},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			"https://api.github.com/repos/fake/fake/commits/fake",
+			[]string{"", ""},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			"https://api.github.com/repos/fake/fake/actions/variables?per_page=100",
+			[]string{"", ""},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			"https://api.github.com/repos/fake/fake/actions/organization-variables?per_page=100",
+			[]string{"", ""},
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		in := tt.in
+		wantGotIfNoneMatchs := tt.wantGotIfNoneMatchs
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := &etagTransport{
+				etag: `"fake1"`,
+				body: "fake2",
+			}
+			cache := &cacheMock{
+				m: make(map[string][]byte),
+			}
+			receiver := &client.ETagCachingHTTPClient{
+				Cache:  cache,
+				Inner:  &http.Client{Transport: rt},
+				Logger: client.NewDefaultLogger(),
+				Paths:  client.ListingPaths,
+			}
+			for range wantGotIfNoneMatchs {
+				request, err := http.NewRequest("GET", in, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				response, err := receiver.Do(request)
+				if err != nil {
+					t.Fatal(err)
+				}
+				response.Body.Close()
+			}
+			if diff := cmp.Diff(wantGotIfNoneMatchs, rt.gotIfNoneMatchs); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+			if wantGotIfNoneMatchs[1] == "" && len(cache.m) > 0 {
+				t.Errorf("want no cache entry, but got %d", len(cache.m))
+			}
+		})
+	}
+}
@@ -27,3 +27,15 @@ func (c *HTTPClient) Do(request *http.Request) (*http.Response, error) {
 
 	return response, nil
 }
+
+type HeaderHTTPClient struct {
+	Headers map[string]string
+	Inner   IHTTPClient
+}
+
+func (c *HeaderHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	for key, value := range c.Headers {
+		request.Header.Set(key, value)
+	}
+	return c.Inner.Do(request)
+}
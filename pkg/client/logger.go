@@ -2,16 +2,25 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"os"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
 )
 
 type ILogger interface {
 	Errorf(format string, v ...interface{})
 	Infof(format string, v ...interface{})
 	Debugf(format string, v ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Debugw(msg string, keysAndValues ...interface{})
 }
 
 type DefaultLogger struct{}
@@ -26,34 +35,143 @@ func (dl *DefaultLogger) Infof(format string, v ...interface{}) {}
 
 func (dl *DefaultLogger) Debugf(format string, v ...interface{}) {}
 
-type StandardLogger struct {
-	errorLogger *log.Logger
-	infoLogger  *log.Logger
-	debugLogger *log.Logger
+func (dl *DefaultLogger) Errorw(msg string, keysAndValues ...interface{}) {}
+
+func (dl *DefaultLogger) Infow(msg string, keysAndValues ...interface{}) {}
+
+func (dl *DefaultLogger) Debugw(msg string, keysAndValues ...interface{}) {}
+
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelError
+)
+
+var (
+	logLevelNames = map[LogLevel]string{
+		LogLevelDebug: "debug",
+		LogLevelInfo:  "info",
+		LogLevelError: "error",
+	}
+)
+
+func ParseLogLevel(s string) (LogLevel, error) {
+	for level, name := range logLevelNames {
+		if name == strings.ToLower(s) {
+			return level, nil
+		}
+	}
+	return 0, xerrors.Errorf("unknown log level: %s", s)
+}
+
+type StructuredLogger struct {
+	out    io.Writer
+	errOut io.Writer
+	format string
+	level  LogLevel
+	mutex  sync.Mutex
 }
 
-func NewStandardLogger(verbose bool) *StandardLogger {
-	logger := &StandardLogger{}
-	logger.errorLogger = log.New(os.Stderr, "", log.LUTC)
-	logger.infoLogger = log.New(os.Stdout, "", log.LUTC)
-	if verbose {
-		logger.debugLogger = log.New(os.Stdout, "", log.LUTC)
-	} else {
-		logger.debugLogger = log.New(ioutil.Discard, "", log.LUTC)
+func NewStructuredLogger(out io.Writer, errOut io.Writer, format string, level LogLevel) (*StructuredLogger, error) {
+	switch format {
+	case "json", "text":
+	default:
+		return nil, xerrors.Errorf("unknown log format: %s", format)
 	}
-	return logger
+	return &StructuredLogger{
+		out:    out,
+		errOut: errOut,
+		format: format,
+		level:  level,
+	}, nil
+}
+
+func (sl *StructuredLogger) Errorf(format string, v ...interface{}) {
+	sl.log(LogLevelError, fmt.Sprintf(format, v...), nil)
+}
+
+func (sl *StructuredLogger) Infof(format string, v ...interface{}) {
+	sl.log(LogLevelInfo, fmt.Sprintf(format, v...), nil)
+}
+
+func (sl *StructuredLogger) Debugf(format string, v ...interface{}) {
+	sl.log(LogLevelDebug, fmt.Sprintf(format, v...), nil)
 }
 
-func (sl *StandardLogger) Errorf(format string, v ...interface{}) {
-	sl.errorLogger.Printf(format, v...)
+func (sl *StructuredLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	sl.log(LogLevelError, msg, keysAndValues)
 }
 
-func (sl *StandardLogger) Infof(format string, v ...interface{}) {
-	sl.infoLogger.Printf(format, v...)
+func (sl *StructuredLogger) Infow(msg string, keysAndValues ...interface{}) {
+	sl.log(LogLevelInfo, msg, keysAndValues)
 }
 
-func (sl *StandardLogger) Debugf(format string, v ...interface{}) {
-	sl.debugLogger.Printf(format, v...)
+func (sl *StructuredLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	sl.log(LogLevelDebug, msg, keysAndValues)
+}
+
+func (sl *StructuredLogger) log(level LogLevel, msg string, keysAndValues []interface{}) {
+	if level < sl.level {
+		return
+	}
+	fields := make(map[string]interface{})
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 < len(keysAndValues) {
+			fields[key] = keysAndValues[i+1]
+		} else {
+			fields[key] = nil
+		}
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	msg = strings.TrimSuffix(msg, "\n")
+
+	var line []byte
+	switch sl.format {
+	case "json":
+		entry := make(map[string]interface{})
+		for key, value := range fields {
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			entry[key] = value
+		}
+		entry["time"] = now
+		entry["level"] = logLevelNames[level]
+		entry["msg"] = msg
+		b, err := json.Marshal(entry)
+		if err != nil {
+			b = []byte(fmt.Sprintf(`{"time":%q,"level":"error","msg":%q}`, now, "failed to marshal log entry: "+err.Error()))
+		}
+		line = append(b, '\n')
+	default:
+		var b strings.Builder
+		fmt.Fprintf(&b, "time=%s level=%s msg=%s", now, logLevelNames[level], strconv.Quote(msg))
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := fmt.Sprint(fields[key])
+			if strings.ContainsAny(value, " \"=") || value == "" {
+				value = strconv.Quote(value)
+			}
+			fmt.Fprintf(&b, " %s=%s", key, value)
+		}
+		b.WriteString("\n")
+		line = []byte(b.String())
+	}
+
+	out := sl.out
+	if level == LogLevelError {
+		out = sl.errOut
+	}
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+	_, _ = out.Write(line)
 }
 
 type contextKey string
@@ -73,15 +191,27 @@ func NewRequestLogger(requestID string, logger ILogger) *RequestLogger {
 }
 
 func (l *RequestLogger) Errorf(format string, v ...interface{}) {
-	l.inner.Errorf(`{"severity":"error","requestID":"%s","payload":%q}`, l.requestID, fmt.Sprintf(format, v...))
+	l.inner.Errorw(fmt.Sprintf(format, v...), "request_id", l.requestID)
 }
 
 func (l *RequestLogger) Infof(format string, v ...interface{}) {
-	l.inner.Infof(`{"severity":"info","requestID":"%s","payload":%q}`, l.requestID, fmt.Sprintf(format, v...))
+	l.inner.Infow(fmt.Sprintf(format, v...), "request_id", l.requestID)
 }
 
 func (l *RequestLogger) Debugf(format string, v ...interface{}) {
-	l.inner.Debugf(`{"severity":"debug","requestID":"%s","payload":%q}`, l.requestID, fmt.Sprintf(format, v...))
+	l.inner.Debugw(fmt.Sprintf(format, v...), "request_id", l.requestID)
+}
+
+func (l *RequestLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.inner.Errorw(msg, append([]interface{}{"request_id", l.requestID}, keysAndValues...)...)
+}
+
+func (l *RequestLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.inner.Infow(msg, append([]interface{}{"request_id", l.requestID}, keysAndValues...)...)
+}
+
+func (l *RequestLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.inner.Debugw(msg, append([]interface{}{"request_id", l.requestID}, keysAndValues...)...)
 }
 
 func GetRequestLogger(ctx context.Context) *RequestLogger {
@@ -0,0 +1,79 @@
+package client_test
+
+import (
+	"bytes"
+	"fmt"
+	"github-actions-exporter/pkg/client"
+	"regexp"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStructuredLogger(t *testing.T) {
+	timePattern := regexp.MustCompile(`"?time"?[=:]"?[0-9TZ:.-]+"?`)
+
+	tests := []struct {
+		name       string
+		format     string
+		level      client.LogLevel
+		log        func(logger client.ILogger)
+		wantOut    string
+		wantErrOut string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			"json",
+			client.LogLevelInfo,
+			func(logger client.ILogger) {
+				logger.Debugw("fake1", "repository", "fake/fake")
+				logger.Infow("fake2", "repository", "fake/fake", "status_code", 200)
+				logger.Errorw("fake3", "collector", "runs")
+			},
+			`{"level":"info","msg":"fake2","repository":"fake/fake","status_code":200,TIME}` + "\n",
+			`{"collector":"runs","level":"error","msg":"fake3",TIME}` + "\n",
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			"text",
+			client.LogLevelDebug,
+			func(logger client.ILogger) {
+				logger.Debugf("fake%d\n", 1)
+				logger.Errorw("fake 2", "repository", "fake/fake", "error", "fake error")
+			},
+			`TIME level=debug msg="fake1"` + "\n",
+			`TIME level=error msg="fake 2" error="fake error" repository=fake/fake` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		format := tt.format
+		level := tt.level
+		log := tt.log
+		wantOut := tt.wantOut
+		wantErrOut := tt.wantErrOut
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var out, errOut bytes.Buffer
+			logger, err := client.NewStructuredLogger(&out, &errOut, format, level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			log(logger)
+			if diff := cmp.Diff(wantOut, timePattern.ReplaceAllString(out.String(), "TIME")); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(wantErrOut, timePattern.ReplaceAllString(errOut.String(), "TIME")); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
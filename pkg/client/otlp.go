@@ -0,0 +1,468 @@
+package client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"go.opencensus.io/trace"
+	"golang.org/x/xerrors"
+	"google.golang.org/api/support/bundler"
+)
+
+const (
+	otlpTracesPath                       = "/v1/traces"
+	otlpMetricsPath                      = "/v1/metrics"
+	otlpAggregationTemporalityCumulative = 2
+)
+
+var (
+	otlpSpanKinds = map[int]int{
+		trace.SpanKindUnspecified: 1,
+		trace.SpanKindServer:      2,
+		trace.SpanKindClient:      3,
+	}
+)
+
+type OTLPAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type OTLPKeyValue struct {
+	Key   string       `json:"key"`
+	Value OTLPAnyValue `json:"value"`
+}
+
+type OTLPEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []OTLPKeyValue `json:"attributes,omitempty"`
+}
+
+type OTLPStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type OTLPSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []OTLPKeyValue `json:"attributes,omitempty"`
+	Events            []OTLPEvent    `json:"events,omitempty"`
+	Status            OTLPStatus     `json:"status"`
+}
+
+type OTLPScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []OTLPSpan `json:"spans"`
+}
+
+type OTLPResourceSpans struct {
+	Resource struct {
+		Attributes []OTLPKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []OTLPScopeSpans `json:"scopeSpans"`
+}
+
+type OTLPTracesRequest struct {
+	ResourceSpans []OTLPResourceSpans `json:"resourceSpans"`
+}
+
+type OTLPExporter struct {
+	endpoint    string
+	serviceName string
+	headers     map[string]string
+	httpClient  IHTTPClient
+	logger      ILogger
+	bundler     *bundler.Bundler
+}
+
+func NewOTLPExporter(
+	endpoint string,
+	serviceName string,
+	headers map[string]string,
+	httpClient IHTTPClient,
+	logger ILogger,
+) *OTLPExporter {
+	e := &OTLPExporter{
+		endpoint:    strings.TrimSuffix(endpoint, "/") + otlpTracesPath,
+		serviceName: serviceName,
+		headers:     headers,
+		httpClient:  httpClient,
+		logger:      logger,
+	}
+	e.bundler = bundler.NewBundler((*trace.SpanData)(nil), func(bundle interface{}) {
+		if err := e.upload(bundle.([]*trace.SpanData)); err != nil {
+			e.logger.Errorf("Failed to export spans: %s\n", err.Error())
+		}
+	})
+	return e
+}
+
+func (e *OTLPExporter) ExportSpan(data *trace.SpanData) {
+	if err := e.bundler.Add(data, 1); err != nil {
+		e.logger.Errorf("Failed to buffer span: %s\n", err.Error())
+	}
+}
+
+func (e *OTLPExporter) Flush() {
+	e.bundler.Flush()
+}
+
+func (e *OTLPExporter) upload(spans []*trace.SpanData) error {
+	scopeSpans := OTLPScopeSpans{}
+	scopeSpans.Scope.Name = e.serviceName
+	for _, span := range spans {
+		scopeSpans.Spans = append(scopeSpans.Spans, newOTLPSpan(span))
+	}
+	resourceSpans := OTLPResourceSpans{
+		ScopeSpans: []OTLPScopeSpans{scopeSpans},
+	}
+	resourceSpans.Resource.Attributes = []OTLPKeyValue{
+		newOTLPKeyValue("service.name", e.serviceName),
+	}
+
+	requestBody, err := json.Marshal(OTLPTracesRequest{
+		ResourceSpans: []OTLPResourceSpans{resourceSpans},
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to marshal spans: %w", err)
+	}
+	request, err := http.NewRequest("POST", e.endpoint, bytes.NewReader(requestBody))
+	if err != nil {
+		return xerrors.Errorf("failed to create request object: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		request.Header.Set(key, value)
+	}
+	response, err := e.httpClient.Do(request)
+	if err != nil {
+		return xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return xerrors.Errorf("failed to read response: %w", err)
+	}
+	if response.StatusCode/100 != 2 {
+		return xerrors.Errorf("bad response: %d %s", response.StatusCode, string(body))
+	}
+	return nil
+}
+
+func newOTLPSpan(data *trace.SpanData) OTLPSpan {
+	span := OTLPSpan{
+		TraceID:           hex.EncodeToString(data.TraceID[:]),
+		SpanID:            hex.EncodeToString(data.SpanID[:]),
+		Name:              data.Name,
+		Kind:              otlpSpanKinds[data.SpanKind],
+		StartTimeUnixNano: strconv.FormatInt(data.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(data.EndTime.UnixNano(), 10),
+		Attributes:        newOTLPKeyValues(data.Attributes),
+	}
+	if data.ParentSpanID != (trace.SpanID{}) {
+		span.ParentSpanID = hex.EncodeToString(data.ParentSpanID[:])
+	}
+	for _, annotation := range data.Annotations {
+		span.Events = append(span.Events, OTLPEvent{
+			TimeUnixNano: strconv.FormatInt(annotation.Time.UnixNano(), 10),
+			Name:         annotation.Message,
+			Attributes:   newOTLPKeyValues(annotation.Attributes),
+		})
+	}
+	if data.Code != trace.StatusCodeOK {
+		span.Status = OTLPStatus{
+			Code:    2,
+			Message: data.Message,
+		}
+	}
+	return span
+}
+
+func newOTLPKeyValues(attributes map[string]interface{}) []OTLPKeyValue {
+	var keyValues []OTLPKeyValue
+	for key, value := range attributes {
+		keyValues = append(keyValues, newOTLPKeyValue(key, value))
+	}
+	sort.Slice(keyValues, func(i, j int) bool {
+		return keyValues[i].Key < keyValues[j].Key
+	})
+	return keyValues
+}
+
+func newOTLPKeyValue(key string, value interface{}) OTLPKeyValue {
+	keyValue := OTLPKeyValue{
+		Key: key,
+	}
+	switch v := value.(type) {
+	case bool:
+		keyValue.Value.BoolValue = &v
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		keyValue.Value.IntValue = &s
+	case string:
+		keyValue.Value.StringValue = &v
+	default:
+		s := fmt.Sprint(v)
+		keyValue.Value.StringValue = &s
+	}
+	return keyValue
+}
+
+type OTLPNumberDataPoint struct {
+	Attributes   []OTLPKeyValue `json:"attributes,omitempty"`
+	TimeUnixNano string         `json:"timeUnixNano"`
+	AsDouble     float64        `json:"asDouble"`
+}
+
+type OTLPHistogramDataPoint struct {
+	Attributes     []OTLPKeyValue `json:"attributes,omitempty"`
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	Count          string         `json:"count"`
+	Sum            float64        `json:"sum"`
+	BucketCounts   []string       `json:"bucketCounts"`
+	ExplicitBounds []float64      `json:"explicitBounds"`
+}
+
+type OTLPValueAtQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+type OTLPSummaryDataPoint struct {
+	Attributes     []OTLPKeyValue        `json:"attributes,omitempty"`
+	TimeUnixNano   string                `json:"timeUnixNano"`
+	Count          string                `json:"count"`
+	Sum            float64               `json:"sum"`
+	QuantileValues []OTLPValueAtQuantile `json:"quantileValues"`
+}
+
+type OTLPGauge struct {
+	DataPoints []OTLPNumberDataPoint `json:"dataPoints"`
+}
+
+type OTLPSum struct {
+	DataPoints             []OTLPNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type OTLPHistogram struct {
+	DataPoints             []OTLPHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type OTLPSummary struct {
+	DataPoints []OTLPSummaryDataPoint `json:"dataPoints"`
+}
+
+type OTLPMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Gauge       *OTLPGauge     `json:"gauge,omitempty"`
+	Sum         *OTLPSum       `json:"sum,omitempty"`
+	Histogram   *OTLPHistogram `json:"histogram,omitempty"`
+	Summary     *OTLPSummary   `json:"summary,omitempty"`
+}
+
+type OTLPScopeMetrics struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Metrics []OTLPMetric `json:"metrics"`
+}
+
+type OTLPResourceMetrics struct {
+	Resource struct {
+		Attributes []OTLPKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeMetrics []OTLPScopeMetrics `json:"scopeMetrics"`
+}
+
+type OTLPMetricsRequest struct {
+	ResourceMetrics []OTLPResourceMetrics `json:"resourceMetrics"`
+}
+
+type OTLPMetricsClient struct {
+	endpoint    string
+	serviceName string
+	httpClient  IHTTPClient
+}
+
+func NewOTLPMetricsClient(
+	endpoint string,
+	serviceName string,
+	httpClient IHTTPClient,
+) *OTLPMetricsClient {
+	return &OTLPMetricsClient{
+		endpoint:    strings.TrimSuffix(endpoint, "/") + otlpMetricsPath,
+		serviceName: serviceName,
+		httpClient:  httpClient,
+	}
+}
+
+func (c *OTLPMetricsClient) Export(families []*dto.MetricFamily, now time.Time) error {
+	scopeMetrics := OTLPScopeMetrics{
+		Metrics: NewOTLPMetrics(families, now),
+	}
+	scopeMetrics.Scope.Name = c.serviceName
+	resourceMetrics := OTLPResourceMetrics{
+		ScopeMetrics: []OTLPScopeMetrics{scopeMetrics},
+	}
+	resourceMetrics.Resource.Attributes = []OTLPKeyValue{
+		newOTLPKeyValue("service.name", c.serviceName),
+	}
+
+	requestBody, err := json.Marshal(OTLPMetricsRequest{
+		ResourceMetrics: []OTLPResourceMetrics{resourceMetrics},
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to marshal metrics: %w", err)
+	}
+	request, err := http.NewRequest("POST", c.endpoint, bytes.NewReader(requestBody))
+	if err != nil {
+		return xerrors.Errorf("failed to create request object: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return xerrors.Errorf("failed to read response: %w", err)
+	}
+	if response.StatusCode/100 != 2 {
+		return xerrors.Errorf("bad response: %d %s", response.StatusCode, string(body))
+	}
+	return nil
+}
+
+func NewOTLPMetrics(families []*dto.MetricFamily, now time.Time) []OTLPMetric {
+	var metrics []OTLPMetric
+	for _, family := range families {
+		metric := OTLPMetric{
+			Name:        family.GetName(),
+			Description: family.GetHelp(),
+		}
+		for _, m := range family.GetMetric() {
+			attributes := make(map[string]interface{})
+			for _, label := range m.GetLabel() {
+				attributes[label.GetName()] = label.GetValue()
+			}
+			t := now
+			if m.TimestampMs != nil {
+				t = time.Unix(0, m.GetTimestampMs()*int64(time.Millisecond))
+			}
+			timeUnixNano := strconv.FormatInt(t.UnixNano(), 10)
+			keyValues := newOTLPKeyValues(attributes)
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				if metric.Sum == nil {
+					metric.Sum = &OTLPSum{
+						AggregationTemporality: otlpAggregationTemporalityCumulative,
+						IsMonotonic:            true,
+					}
+				}
+				if isFinite(m.GetCounter().GetValue()) {
+					metric.Sum.DataPoints = append(metric.Sum.DataPoints, OTLPNumberDataPoint{
+						Attributes:   keyValues,
+						TimeUnixNano: timeUnixNano,
+						AsDouble:     m.GetCounter().GetValue(),
+					})
+				}
+			case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+				if metric.Gauge == nil {
+					metric.Gauge = &OTLPGauge{}
+				}
+				value := m.GetGauge().GetValue()
+				if family.GetType() == dto.MetricType_UNTYPED {
+					value = m.GetUntyped().GetValue()
+				}
+				if isFinite(value) {
+					metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, OTLPNumberDataPoint{
+						Attributes:   keyValues,
+						TimeUnixNano: timeUnixNano,
+						AsDouble:     value,
+					})
+				}
+			case dto.MetricType_SUMMARY:
+				if metric.Summary == nil {
+					metric.Summary = &OTLPSummary{}
+				}
+				summary := m.GetSummary()
+				dataPoint := OTLPSummaryDataPoint{
+					Attributes:     keyValues,
+					TimeUnixNano:   timeUnixNano,
+					Count:          strconv.FormatUint(summary.GetSampleCount(), 10),
+					Sum:            summary.GetSampleSum(),
+					QuantileValues: []OTLPValueAtQuantile{},
+				}
+				for _, quantile := range summary.GetQuantile() {
+					if isFinite(quantile.GetValue()) {
+						dataPoint.QuantileValues = append(dataPoint.QuantileValues, OTLPValueAtQuantile{
+							Quantile: quantile.GetQuantile(),
+							Value:    quantile.GetValue(),
+						})
+					}
+				}
+				metric.Summary.DataPoints = append(metric.Summary.DataPoints, dataPoint)
+			case dto.MetricType_HISTOGRAM:
+				if metric.Histogram == nil {
+					metric.Histogram = &OTLPHistogram{
+						AggregationTemporality: otlpAggregationTemporalityCumulative,
+					}
+				}
+				histogram := m.GetHistogram()
+				dataPoint := OTLPHistogramDataPoint{
+					Attributes:     keyValues,
+					TimeUnixNano:   timeUnixNano,
+					Count:          strconv.FormatUint(histogram.GetSampleCount(), 10),
+					Sum:            histogram.GetSampleSum(),
+					BucketCounts:   []string{},
+					ExplicitBounds: []float64{},
+				}
+				previous := uint64(0)
+				for _, bucket := range histogram.GetBucket() {
+					if math.IsInf(bucket.GetUpperBound(), +1) {
+						continue
+					}
+					dataPoint.ExplicitBounds = append(dataPoint.ExplicitBounds, bucket.GetUpperBound())
+					dataPoint.BucketCounts = append(dataPoint.BucketCounts, strconv.FormatUint(bucket.GetCumulativeCount()-previous, 10))
+					previous = bucket.GetCumulativeCount()
+				}
+				dataPoint.BucketCounts = append(dataPoint.BucketCounts, strconv.FormatUint(histogram.GetSampleCount()-previous, 10))
+				metric.Histogram.DataPoints = append(metric.Histogram.DataPoints, dataPoint)
+			}
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
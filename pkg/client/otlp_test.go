@@ -0,0 +1,173 @@
+package client_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github-actions-exporter/pkg/client"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/trace"
+)
+
+type otlpTransport struct {
+	mutex  sync.Mutex
+	paths  []string
+	bodies []client.OTLPTracesRequest
+}
+
+func (t *otlpTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var body client.OTLPTracesRequest
+	if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.paths = append(t.paths, request.URL.Path)
+	t.bodies = append(t.bodies, body)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader("{}")),
+	}, nil
+}
+
+func TestOTLPExporterExportSpan(t *testing.T) {
+	fakeString := "fake"
+	fakeInt := "200"
+
+	tests := []struct {
+		name      string
+		in        *trace.SpanData
+		wantPaths []string
+		wantSpans []client.OTLPSpan
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			&trace.SpanData{
+				SpanContext: trace.SpanContext{
+					TraceID: trace.TraceID{1},
+					SpanID:  trace.SpanID{2},
+				},
+				ParentSpanID: trace.SpanID{3},
+				SpanKind:     trace.SpanKindClient,
+				Name:         "GET /fake",
+				StartTime:    time.Unix(1, 0),
+				EndTime:      time.Unix(2, 0),
+				Attributes: map[string]interface{}{
+					"github.repository": "fake",
+				},
+				Status: trace.Status{
+					Code:    trace.StatusCodeUnknown,
+					Message: "fake",
+				},
+			},
+			[]string{"/v1/traces"},
+			[]client.OTLPSpan{
+				{
+					TraceID:           "01000000000000000000000000000000",
+					SpanID:            "0200000000000000",
+					ParentSpanID:      "0300000000000000",
+					Name:              "GET /fake",
+					Kind:              3,
+					StartTimeUnixNano: "1000000000",
+					EndTimeUnixNano:   "2000000000",
+					Attributes: []client.OTLPKeyValue{
+						{
+							Key: "github.repository",
+							Value: client.OTLPAnyValue{
+								StringValue: &fakeString,
+							},
+						},
+					},
+					Status: client.OTLPStatus{
+						Code:    2,
+						Message: "fake",
+					},
+				},
+			},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			&trace.SpanData{
+				SpanContext: trace.SpanContext{
+					TraceID: trace.TraceID{1},
+					SpanID:  trace.SpanID{2},
+				},
+				Name:      "fake",
+				StartTime: time.Unix(1, 0),
+				EndTime:   time.Unix(2, 0),
+				Attributes: map[string]interface{}{
+					"http.status_code": int64(200),
+				},
+			},
+			[]string{"/v1/traces"},
+			[]client.OTLPSpan{
+				{
+					TraceID:           "01000000000000000000000000000000",
+					SpanID:            "0200000000000000",
+					Name:              "fake",
+					Kind:              1,
+					StartTimeUnixNano: "1000000000",
+					EndTimeUnixNano:   "2000000000",
+					Attributes: []client.OTLPKeyValue{
+						{
+							Key: "http.status_code",
+							Value: client.OTLPAnyValue{
+								IntValue: &fakeInt,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		in := tt.in
+		wantPaths := tt.wantPaths
+		wantSpans := tt.wantSpans
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &otlpTransport{}
+			exporter := client.NewOTLPExporter(
+				"http://fake/",
+				"fake",
+				map[string]string{},
+				&http.Client{
+					Transport: transport,
+				},
+				client.NewDefaultLogger(),
+			)
+			exporter.ExportSpan(in)
+			exporter.Flush()
+
+			if diff := cmp.Diff(wantPaths, transport.paths); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+			var gotSpans []client.OTLPSpan
+			for _, body := range transport.bodies {
+				for _, resourceSpans := range body.ResourceSpans {
+					for _, scopeSpans := range resourceSpans.ScopeSpans {
+						gotSpans = append(gotSpans, scopeSpans.Spans...)
+					}
+				}
+			}
+			if diff := cmp.Diff(wantSpans, gotSpans); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type RateLimit struct {
+	Resource  string    `json:"resource"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Used      int       `json:"used"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+type RateLimitHTTPClient struct {
+	Inner      IHTTPClient
+	mutex      sync.RWMutex
+	rateLimits map[string]RateLimit
+}
+
+func (c *RateLimitHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	response, err := c.Inner.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if rateLimit, ok := parseRateLimit(response.Header); ok {
+		c.mutex.Lock()
+		if c.rateLimits == nil {
+			c.rateLimits = make(map[string]RateLimit)
+		}
+		c.rateLimits[rateLimit.Resource] = rateLimit
+		c.mutex.Unlock()
+	}
+	return response, nil
+}
+
+func (c *RateLimitHTTPClient) RateLimits() []RateLimit {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	rateLimits := make([]RateLimit, 0, len(c.rateLimits))
+	for _, rateLimit := range c.rateLimits {
+		rateLimits = append(rateLimits, rateLimit)
+	}
+	sort.Slice(rateLimits, func(i, j int) bool {
+		return rateLimits[i].Resource < rateLimits[j].Resource
+	})
+	return rateLimits
+}
+
+func parseRateLimit(header http.Header) (RateLimit, bool) {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+	used, _ := strconv.Atoi(header.Get("X-RateLimit-Used"))
+	reset, _ := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	resource := header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = "core"
+	}
+	return RateLimit{
+		Resource:  resource,
+		Limit:     limit,
+		Remaining: remaining,
+		Used:      used,
+		ResetAt:   time.Unix(reset, 0).UTC(),
+	}, true
+}
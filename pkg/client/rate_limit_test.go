@@ -0,0 +1,86 @@
+package client_test
+
+import (
+	"fmt"
+	"github-actions-exporter/pkg/client"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRateLimitHTTPClientDo(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []http.Header
+		want []client.RateLimit
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]http.Header{
+				{
+					"X-Ratelimit-Limit":     {"5000"},
+					"X-Ratelimit-Remaining": {"4999"},
+					"X-Ratelimit-Used":      {"1"},
+					"X-Ratelimit-Reset":     {"1577836800"},
+					"X-Ratelimit-Resource":  {"core"},
+				},
+				{
+					"X-Ratelimit-Limit":     {"5000"},
+					"X-Ratelimit-Remaining": {"4900"},
+					"X-Ratelimit-Used":      {"100"},
+					"X-Ratelimit-Reset":     {"1577836800"},
+					"X-Ratelimit-Resource":  {"graphql"},
+				},
+				{
+					"X-Ratelimit-Limit":     {"5000"},
+					"X-Ratelimit-Remaining": {"4998"},
+					"X-Ratelimit-Used":      {"2"},
+					"X-Ratelimit-Reset":     {"1577836800"},
+				},
+				{},
+			},
+			[]client.RateLimit{
+				{Resource: "core", Limit: 5000, Remaining: 4998, Used: 2, ResetAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Resource: "graphql", Limit: 5000, Remaining: 4900, Used: 100, ResetAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		in := tt.in
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &recordingTransport{}
+			receiver := &client.RateLimitHTTPClient{
+				Inner: &http.Client{
+					Transport: transport,
+				},
+			}
+			for _, header := range in {
+				transport.returnResponse = &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     header,
+					Body:       http.NoBody,
+				}
+				request, err := http.NewRequest("GET", "https://api.github.com/", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := receiver.Do(request); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if diff := cmp.Diff(want, receiver.RateLimits()); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
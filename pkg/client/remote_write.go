@@ -0,0 +1,173 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/xerrors"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Labels      []Label
+	Value       float64
+	TimestampMs int64
+}
+
+type RemoteWriteClient struct {
+	endpoint   string
+	httpClient IHTTPClient
+}
+
+func NewRemoteWriteClient(
+	endpoint string,
+	httpClient IHTTPClient,
+) *RemoteWriteClient {
+	return &RemoteWriteClient{
+		endpoint:   endpoint,
+		httpClient: httpClient,
+	}
+}
+
+func (c *RemoteWriteClient) Write(families []*dto.MetricFamily, now time.Time) error {
+	request, err := http.NewRequest("POST", c.endpoint, bytes.NewReader(snappy.Encode(nil, EncodeWriteRequest(FlattenMetricFamilies(families, now)))))
+	if err != nil {
+		return xerrors.Errorf("failed to create request object: %w", err)
+	}
+	request.Header.Set("Content-Encoding", "snappy")
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	request.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return xerrors.Errorf("failed to read response: %w", err)
+	}
+	if response.StatusCode/100 != 2 {
+		return xerrors.Errorf("bad response: %d %s", response.StatusCode, string(body))
+	}
+	return nil
+}
+
+func FlattenMetricFamilies(families []*dto.MetricFamily, now time.Time) []Sample {
+	var samples []Sample
+	for _, family := range families {
+		name := family.GetName()
+		for _, metric := range family.GetMetric() {
+			timestampMs := now.UnixNano() / int64(time.Millisecond)
+			if metric.TimestampMs != nil {
+				timestampMs = metric.GetTimestampMs()
+			}
+			newSample := func(name string, value float64, extra ...Label) Sample {
+				labels := []Label{{Name: "__name__", Value: name}}
+				for _, label := range metric.GetLabel() {
+					labels = append(labels, Label{Name: label.GetName(), Value: label.GetValue()})
+				}
+				labels = append(labels, extra...)
+				sort.Slice(labels, func(i, j int) bool {
+					return labels[i].Name < labels[j].Name
+				})
+				return Sample{
+					Labels:      labels,
+					Value:       value,
+					TimestampMs: timestampMs,
+				}
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				samples = append(samples, newSample(name, metric.GetCounter().GetValue()))
+			case dto.MetricType_GAUGE:
+				samples = append(samples, newSample(name, metric.GetGauge().GetValue()))
+			case dto.MetricType_UNTYPED:
+				samples = append(samples, newSample(name, metric.GetUntyped().GetValue()))
+			case dto.MetricType_SUMMARY:
+				summary := metric.GetSummary()
+				for _, quantile := range summary.GetQuantile() {
+					samples = append(samples, newSample(name, quantile.GetValue(), Label{Name: "quantile", Value: formatFloat(quantile.GetQuantile())}))
+				}
+				samples = append(samples, newSample(name+"_sum", summary.GetSampleSum()))
+				samples = append(samples, newSample(name+"_count", float64(summary.GetSampleCount())))
+			case dto.MetricType_HISTOGRAM:
+				histogram := metric.GetHistogram()
+				infSeen := false
+				for _, bucket := range histogram.GetBucket() {
+					if math.IsInf(bucket.GetUpperBound(), +1) {
+						infSeen = true
+					}
+					samples = append(samples, newSample(name+"_bucket", float64(bucket.GetCumulativeCount()), Label{Name: "le", Value: formatFloat(bucket.GetUpperBound())}))
+				}
+				if !infSeen {
+					samples = append(samples, newSample(name+"_bucket", float64(histogram.GetSampleCount()), Label{Name: "le", Value: "+Inf"}))
+				}
+				samples = append(samples, newSample(name+"_sum", histogram.GetSampleSum()))
+				samples = append(samples, newSample(name+"_count", float64(histogram.GetSampleCount())))
+			}
+		}
+	}
+	return samples
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func EncodeWriteRequest(samples []Sample) []byte {
+	var writeRequest []byte
+	for _, sample := range samples {
+		var timeSeries []byte
+		for _, label := range sample.Labels {
+			var l []byte
+			l = appendBytesField(l, 1, []byte(label.Name))
+			l = appendBytesField(l, 2, []byte(label.Value))
+			timeSeries = appendBytesField(timeSeries, 1, l)
+		}
+		var s []byte
+		s = appendTag(s, 1, 1)
+		s = appendFixed64(s, math.Float64bits(sample.Value))
+		s = appendTag(s, 2, 0)
+		s = appendVarint(s, uint64(sample.TimestampMs))
+		timeSeries = appendBytesField(timeSeries, 2, s)
+		writeRequest = appendBytesField(writeRequest, 1, timeSeries)
+	}
+	return writeRequest
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+func appendTag(b []byte, field uint64, wireType uint64) []byte {
+	return appendVarint(b, field<<3|wireType)
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, v)
+	return append(b, buf...)
+}
+
+func appendBytesField(b []byte, field uint64, v []byte) []byte {
+	b = appendTag(b, field, 2)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
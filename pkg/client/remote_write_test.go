@@ -0,0 +1,155 @@
+package client_test
+
+import (
+	"fmt"
+	"github-actions-exporter/pkg/client"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestFlattenMetricFamilies(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []*dto.MetricFamily
+		want []client.Sample
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]*dto.MetricFamily{
+				{
+					Name: proto.String("fake_runs"),
+					Type: dto.MetricType_GAUGE.Enum(),
+					Metric: []*dto.Metric{
+						{
+							Label: []*dto.LabelPair{
+								{Name: proto.String("status"), Value: proto.String("queued")},
+								{Name: proto.String("repository"), Value: proto.String("fake/fake")},
+							},
+							Gauge: &dto.Gauge{Value: proto.Float64(1)},
+						},
+					},
+				},
+				{
+					Name: proto.String("fake_seconds"),
+					Type: dto.MetricType_HISTOGRAM.Enum(),
+					Metric: []*dto.Metric{
+						{
+							Histogram: &dto.Histogram{
+								SampleCount: proto.Uint64(3),
+								SampleSum:   proto.Float64(4),
+								Bucket: []*dto.Bucket{
+									{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(2)},
+								},
+							},
+							TimestampMs: proto.Int64(1000),
+						},
+					},
+				},
+			},
+			[]client.Sample{
+				{
+					Labels: []client.Label{
+						{Name: "__name__", Value: "fake_runs"},
+						{Name: "repository", Value: "fake/fake"},
+						{Name: "status", Value: "queued"},
+					},
+					Value:       1,
+					TimestampMs: 2000,
+				},
+				{
+					Labels: []client.Label{
+						{Name: "__name__", Value: "fake_seconds_bucket"},
+						{Name: "le", Value: "1"},
+					},
+					Value:       2,
+					TimestampMs: 1000,
+				},
+				{
+					Labels: []client.Label{
+						{Name: "__name__", Value: "fake_seconds_bucket"},
+						{Name: "le", Value: "+Inf"},
+					},
+					Value:       3,
+					TimestampMs: 1000,
+				},
+				{
+					Labels: []client.Label{
+						{Name: "__name__", Value: "fake_seconds_sum"},
+					},
+					Value:       4,
+					TimestampMs: 1000,
+				},
+				{
+					Labels: []client.Label{
+						{Name: "__name__", Value: "fake_seconds_count"},
+					},
+					Value:       3,
+					TimestampMs: 1000,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		in := tt.in
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := client.FlattenMetricFamilies(in, time.Unix(2, 0))
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []client.Sample
+		want []byte
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]client.Sample{
+				{
+					Labels: []client.Label{
+						{Name: "a", Value: "b"},
+					},
+					Value:       1,
+					TimestampMs: 2,
+				},
+			},
+			[]byte{
+				0x0a, 0x15,
+				0x0a, 0x06, 0x0a, 0x01, 'a', 0x12, 0x01, 'b',
+				0x12, 0x0b, 0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f, 0x10, 0x02,
+			},
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		in := tt.in
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := client.EncodeWriteRequest(in)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,43 +1,123 @@
 package server
 
-import "math"
+import (
+	"github-actions-exporter/pkg/server/collector"
+	"math"
+)
 
 type Args struct {
-	APIAddress                     string
-	APIMaxConnections              int64
-	MonitorAddress                 string
-	MonitorMaxConnections          int64
-	MonitoringJaegerEndpoint       string
-	EnableProfiling                bool
-	EnableTracing                  bool
-	TracingSampleRate              float64
-	KeepAlived                     bool
-	ReUsePort                      bool
-	TCPKeepAliveInterval           int64
-	Verbose                        bool
-	Repository                     string
-	RunsCollectorLoopInterval      int64
-	RunnersCollectorLoopInterval   int64
-	WorkflowsCollectorLoopInterval int64
-	Token                          string
+	APIAddress                         string
+	APIMaxConnections                  int64
+	MonitorAddress                     string
+	MonitorMaxConnections              int64
+	WebConfigFile                      string
+	MonitoringJaegerEndpoint           string
+	EnableProfiling                    bool
+	EnableTracing                      bool
+	TracingSampleRate                  float64
+	TracingExporter                    string
+	OTLPEndpoint                       string
+	OTLPHeaders                        map[string]string
+	KeepAlived                         bool
+	ReUsePort                          bool
+	TCPKeepAliveInterval               int64
+	Verbose                            bool
+	LogFormat                          string
+	LogLevel                           string
+	Repositories                       []string
+	Backend                            string
+	Collectors                         []string
+	LabelPolicyFile                    string
+	DeploymentWorkflowPattern          string
+	EnableDeploymentsAPI               bool
+	RequiredWorkflowPattern            string
+	ActorTopN                          int
+	OutputFormat                       string
+	StateStore                         string
+	StateFile                          string
+	StateConfigMap                     string
+	EnableETagCache                    bool
+	EnableLeaderElection               bool
+	LeaderElectionLease                string
+	LeaderElectionLeaseDuration        int64
+	LeaderElectionRenewDeadline        int64
+	LeaderElectionRetryPeriod          int64
+	RunsCollectorLoopInterval          int64
+	RunnersCollectorLoopInterval       int64
+	WorkflowsCollectorLoopInterval     int64
+	RunTracesCollectorLoopInterval     int64
+	PullRequestsCollectorLoopInterval  int64
+	FlakyCollectorLoopInterval         int64
+	SecurityCollectorLoopInterval      int64
+	WorkflowFilesCollectorLoopInterval int64
+	EnableRunTraces                    bool
+	RunTracesServiceName               string
+	PushTarget                         string
+	PushEndpoint                       string
+	PushJob                            string
+	PushInterval                       int64
+	PushHeaders                        map[string]string
+	Tokens                             []string
+	AppIDs                             []int
+	AppInstallationIDs                 []int
+	AppPrivateKeyFiles                 []string
+	ShardIndex                         int
+	ShardCount                         int
 }
 
 func DefaultArgs() *Args {
 	return &Args{
-		APIAddress:                     "127.0.0.1:8000",
-		APIMaxConnections:              math.MaxInt64,
-		MonitorAddress:                 "127.0.0.1:9090",
-		MonitorMaxConnections:          math.MaxInt64,
-		MonitoringJaegerEndpoint:       "jaeger-agent.istio-system.svc.cluster.local:6831",
-		EnableProfiling:                false,
-		EnableTracing:                  false,
-		TracingSampleRate:              0,
-		KeepAlived:                     true,
-		ReUsePort:                      false,
-		TCPKeepAliveInterval:           0,
-		RunsCollectorLoopInterval:      300,
-		RunnersCollectorLoopInterval:   300,
-		WorkflowsCollectorLoopInterval: 3600,
-		Verbose:                        true,
+		APIAddress:                         "127.0.0.1:8000",
+		APIMaxConnections:                  math.MaxInt64,
+		MonitorAddress:                     "127.0.0.1:9090",
+		MonitorMaxConnections:              math.MaxInt64,
+		WebConfigFile:                      "",
+		MonitoringJaegerEndpoint:           "jaeger-agent.istio-system.svc.cluster.local:6831",
+		EnableProfiling:                    false,
+		EnableTracing:                      false,
+		TracingSampleRate:                  0,
+		TracingExporter:                    "jaeger",
+		OTLPEndpoint:                       "http://localhost:4318",
+		OTLPHeaders:                        map[string]string{},
+		KeepAlived:                         true,
+		ReUsePort:                          false,
+		TCPKeepAliveInterval:               0,
+		RunsCollectorLoopInterval:          300,
+		RunnersCollectorLoopInterval:       300,
+		WorkflowsCollectorLoopInterval:     3600,
+		RunTracesCollectorLoopInterval:     300,
+		PullRequestsCollectorLoopInterval:  300,
+		FlakyCollectorLoopInterval:         300,
+		SecurityCollectorLoopInterval:      3600,
+		WorkflowFilesCollectorLoopInterval: 3600,
+		EnableRunTraces:                    false,
+		RunTracesServiceName:               "github-actions",
+		PushTarget:                         "",
+		PushEndpoint:                       "",
+		PushJob:                            "github-actions-exporter",
+		PushInterval:                       60,
+		PushHeaders:                        map[string]string{},
+		Verbose:                            true,
+		LogFormat:                          "text",
+		LogLevel:                           "",
+		Backend:                            "rest",
+		Collectors:                         collector.DefaultCollectors,
+		LabelPolicyFile:                    "",
+		DeploymentWorkflowPattern:          "",
+		EnableDeploymentsAPI:               false,
+		RequiredWorkflowPattern:            "",
+		ActorTopN:                          0,
+		OutputFormat:                       "text",
+		StateStore:                         "memory",
+		StateFile:                          "/var/lib/github-actions-exporter/state.db",
+		StateConfigMap:                     "github-actions-exporter-state",
+		EnableETagCache:                    false,
+		EnableLeaderElection:               false,
+		LeaderElectionLease:                "github-actions-exporter",
+		LeaderElectionLeaseDuration:        15,
+		LeaderElectionRenewDeadline:        10,
+		LeaderElectionRetryPeriod:          2,
+		ShardIndex:                         -1,
+		ShardCount:                         1,
 	}
 }
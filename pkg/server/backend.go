@@ -0,0 +1,29 @@
+package server
+
+import (
+	"time"
+)
+
+// backendCacheTTL lets collectors of the same cycle share responses of the
+// backend, while the next cycle of any of them fetches fresh ones.
+func backendCacheTTL(a *Args) time.Duration {
+	intervals := map[string]int64{
+		"runs":           a.RunsCollectorLoopInterval,
+		"runners":        a.RunnersCollectorLoopInterval,
+		"workflows":      a.WorkflowsCollectorLoopInterval,
+		"pull_requests":  a.PullRequestsCollectorLoopInterval,
+		"flaky":          a.FlakyCollectorLoopInterval,
+		"security":       a.SecurityCollectorLoopInterval,
+		"workflow_files": a.WorkflowFilesCollectorLoopInterval,
+	}
+	var shortest int64
+	for _, name := range a.Collectors {
+		if interval, ok := intervals[name]; ok && (shortest == 0 || interval < shortest) {
+			shortest = interval
+		}
+	}
+	if a.EnableRunTraces && (shortest == 0 || a.RunTracesCollectorLoopInterval < shortest) {
+		shortest = a.RunTracesCollectorLoopInterval
+	}
+	return time.Duration(shortest) * time.Second / 2
+}
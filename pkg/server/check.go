@@ -0,0 +1,561 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github-actions-exporter/pkg/client"
+	"github-actions-exporter/pkg/server/shard"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	checkPass = "PASS"
+	checkFail = "FAIL"
+	checkSkip = "SKIP"
+)
+
+type CheckResult struct {
+	Check  string
+	Target string
+	Result string
+	Detail string
+}
+
+type requiredPermission struct {
+	name          string
+	scopes        []string
+	appPermission string
+	collectors    []string
+}
+
+var (
+	requiredPermissions = []requiredPermission{
+		{
+			name:          "actions:read",
+			scopes:        []string{"repo", "public_repo"},
+			appPermission: "actions",
+			collectors:    []string{"runs", "workflows", "pull_requests", "flaky", "workflow_files"},
+		},
+		{
+			name:          "pull_requests:read",
+			scopes:        []string{"repo", "public_repo"},
+			appPermission: "pull_requests",
+			collectors:    []string{"pull_requests"},
+		},
+		{
+			name:          "administration:read",
+			scopes:        []string{"repo"},
+			appPermission: "administration",
+			collectors:    []string{"runners", "security"},
+		},
+		{
+			name:          "contents:read",
+			scopes:        []string{"repo", "public_repo"},
+			appPermission: "contents",
+			collectors:    []string{"workflow_files"},
+		},
+		{
+			name:          "secrets:read",
+			scopes:        []string{"repo"},
+			appPermission: "secrets",
+			collectors:    []string{"security"},
+		},
+		{
+			name:          "variables:read",
+			scopes:        []string{"repo"},
+			appPermission: "actions_variables",
+			collectors:    []string{"security"},
+		},
+		{
+			name:          "organization billing",
+			scopes:        []string{"repo", "admin:org"},
+			appPermission: "organization_administration",
+			collectors:    []string{"workflows"},
+		},
+	}
+)
+
+type checkEndpoint struct {
+	collectors []string
+	method     string
+	path       string
+	body       []byte
+	skip       string
+}
+
+// checkIDs holds the IDs of existing resources of a repository, which are
+// needed to call the endpoints of a single workflow, run or deployment.
+type checkIDs struct {
+	workflowID   uint64
+	runID        uint64
+	runAttempt   uint64
+	deploymentID uint64
+	sha          string
+}
+
+func Check(a *Args, w io.Writer) error {
+	i := NewInstance()
+	logger, err := newLogger(a, os.Stderr)
+	if err != nil {
+		return xerrors.Errorf("failed to create logger: %w", err)
+	}
+	i.SetLogger(logger)
+
+	shardIndex, err := resolveShardIndex(a)
+	if err != nil {
+		return xerrors.Errorf("failed to resolve shard index: %w", err)
+	}
+	repositories := shard.Filter(a.Repositories, shardIndex, a.ShardCount)
+	tokenSource, err := newTokenSource(i, a, shardIndex)
+	if err != nil {
+		return xerrors.Errorf("failed to create token source: %w", err)
+	}
+	gitHubClient := &client.AuthorizedHTTPClient{
+		TokenSource: tokenSource,
+		Inner:       i.HTTPClient(),
+	}
+
+	enabled := make(map[string]bool)
+	for _, name := range a.Collectors {
+		enabled[name] = true
+	}
+	enabled["run_traces"] = a.EnableRunTraces
+	enabled["deployment_workflows"] = enabled["workflows"] && a.DeploymentWorkflowPattern != ""
+	enabled["deployments_api"] = enabled["workflows"] && a.EnableDeploymentsAPI
+
+	var results []CheckResult
+	results = append(results, checkCredential(gitHubClient, tokenSource, enabled)...)
+	for _, repository := range repositories {
+		ids := resolveCheckIDs(gitHubClient, repository)
+		for _, endpoint := range checkEndpoints(repository, a.Backend, ids) {
+			if !isAnyEnabled(enabled, endpoint.collectors) {
+				continue
+			}
+			if endpoint.skip != "" {
+				results = append(results, CheckResult{
+					Check:  "endpoint",
+					Target: fmt.Sprintf("%s %s", endpoint.method, endpoint.path),
+					Result: checkSkip,
+					Detail: endpoint.skip,
+				})
+				continue
+			}
+			result := checkRequest(gitHubClient, endpoint.method, endpoint.path, endpoint.body)
+			if endpoint.body != nil {
+				result.Target = fmt.Sprintf("%s (%s)", result.Target, repository)
+			}
+			results = append(results, result)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tTARGET\tRESULT\tDETAIL")
+	failed := 0
+	for _, result := range results {
+		if result.Result == checkFail {
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Check, result.Target, result.Result, result.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return xerrors.Errorf("failed to write results: %w", err)
+	}
+
+	if failed > 0 {
+		return xerrors.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+func checkCredential(httpClient IHTTPClient, tokenSource ITokenSource, enabled map[string]bool) []CheckResult {
+	var results []CheckResult
+	response, body, err := doCheckRequest(httpClient, "GET", "/rate_limit", nil)
+	if err != nil {
+		return append(results, CheckResult{
+			Check:  "credential",
+			Target: credentialName(tokenSource),
+			Result: checkFail,
+			Detail: err.Error(),
+		})
+	}
+	if response.StatusCode != http.StatusOK {
+		return append(results, CheckResult{
+			Check:  "credential",
+			Target: credentialName(tokenSource),
+			Result: checkFail,
+			Detail: responseDetail(response, body),
+		})
+	}
+	results = append(results, CheckResult{
+		Check:  "credential",
+		Target: credentialName(tokenSource),
+		Result: checkPass,
+		Detail: fmt.Sprintf("rate limit %s/%s remaining", response.Header.Get("X-RateLimit-Remaining"), response.Header.Get("X-RateLimit-Limit")),
+	})
+
+	var granted func(p requiredPermission) (bool, string)
+	if appTokenSource, ok := tokenSource.(*client.AppTokenSource); ok {
+		permissions, err := appTokenSource.Permissions()
+		if err != nil {
+			return append(results, CheckResult{
+				Check:  "permission",
+				Target: credentialName(tokenSource),
+				Result: checkFail,
+				Detail: err.Error(),
+			})
+		}
+		granted = func(p requiredPermission) (bool, string) {
+			level, ok := permissions[p.appPermission]
+			if !ok {
+				return false, fmt.Sprintf("%s is not granted", p.appPermission)
+			}
+			return true, fmt.Sprintf("%s: %s", p.appPermission, level)
+		}
+	} else if _, ok := response.Header["X-Oauth-Scopes"]; ok {
+		scopes := make(map[string]bool)
+		for _, scope := range strings.Split(response.Header.Get("X-OAuth-Scopes"), ",") {
+			scopes[strings.TrimSpace(scope)] = true
+		}
+		granted = func(p requiredPermission) (bool, string) {
+			for _, scope := range p.scopes {
+				if scopes[scope] {
+					return true, fmt.Sprintf("scope %s", scope)
+				}
+			}
+			return false, fmt.Sprintf("any of scopes %s is required", strings.Join(p.scopes, ", "))
+		}
+	}
+
+	for _, p := range requiredPermissions {
+		result := CheckResult{
+			Check:  "permission",
+			Target: p.name,
+		}
+		if granted == nil {
+			result.Result = checkSkip
+			result.Detail = "scopes of fine-grained token are not visible, see endpoint checks"
+		} else if ok, detail := granted(p); ok {
+			result.Result = checkPass
+			result.Detail = detail
+		} else if isAnyEnabled(enabled, p.collectors) {
+			result.Result = checkFail
+			result.Detail = detail
+		} else {
+			result.Result = checkSkip
+			result.Detail = detail + " (not used by enabled collectors)"
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// resolveCheckIDs looks up the latest workflow, run and deployment of the
+// repository. Failures are ignored, since the listing endpoints are checked
+// on their own and the endpoints depending on a missing ID are skipped.
+func resolveCheckIDs(httpClient IHTTPClient, repository string) checkIDs {
+	var ids checkIDs
+	var workflows struct {
+		Workflows []struct {
+			ID uint64 `json:"id"`
+		} `json:"workflows"`
+	}
+	if getCheckJSON(httpClient, fmt.Sprintf("/repos/%s/actions/workflows?per_page=1", repository), &workflows) && len(workflows.Workflows) > 0 {
+		ids.workflowID = workflows.Workflows[0].ID
+	}
+	var runs struct {
+		WorkflowRuns []struct {
+			ID         uint64 `json:"id"`
+			RunAttempt uint64 `json:"run_attempt"`
+		} `json:"workflow_runs"`
+	}
+	if getCheckJSON(httpClient, fmt.Sprintf("/repos/%s/actions/runs?per_page=1", repository), &runs) && len(runs.WorkflowRuns) > 0 {
+		ids.runID = runs.WorkflowRuns[0].ID
+		ids.runAttempt = runs.WorkflowRuns[0].RunAttempt
+		if ids.runAttempt == 0 {
+			ids.runAttempt = 1
+		}
+	}
+	var deployments []struct {
+		ID  uint64 `json:"id"`
+		SHA string `json:"sha"`
+	}
+	if getCheckJSON(httpClient, fmt.Sprintf("/repos/%s/deployments?per_page=1", repository), &deployments) && len(deployments) > 0 {
+		ids.deploymentID = deployments[0].ID
+		ids.sha = deployments[0].SHA
+	}
+	return ids
+}
+
+func getCheckJSON(httpClient IHTTPClient, path string, v interface{}) bool {
+	response, body, err := doCheckRequest(httpClient, "GET", path, nil)
+	if err != nil || response.StatusCode != http.StatusOK {
+		return false
+	}
+	return json.Unmarshal(body, v) == nil
+}
+
+func checkEndpoints(repository string, backend string, ids checkIDs) []checkEndpoint {
+	endpoints := []checkEndpoint{
+		{
+			collectors: []string{"runs", "flaky", "run_traces"},
+			method:     "GET",
+			path:       fmt.Sprintf("/repos/%s/actions/runs?per_page=1", repository),
+		},
+		{
+			collectors: []string{"runs"},
+			method:     "GET",
+			path:       fmt.Sprintf("/repos/%s/actions/runs?status=waiting&per_page=1", repository),
+		},
+		{
+			collectors: []string{"runners"},
+			method:     "GET",
+			path:       fmt.Sprintf("/repos/%s/actions/runners?per_page=1", repository),
+		},
+		{
+			collectors: []string{"runners"},
+			method:     "GET",
+			path:       fmt.Sprintf("/repos/%s/actions/runners/downloads", repository),
+		},
+		{
+			collectors: []string{"workflows", "workflow_files"},
+			method:     "GET",
+			path:       fmt.Sprintf("/repos/%s/actions/workflows?per_page=1", repository),
+		},
+		{
+			collectors: []string{"pull_requests"},
+			method:     "GET",
+			path:       fmt.Sprintf("/repos/%s/pulls?per_page=1", repository),
+		},
+		{
+			collectors: []string{"workflow_files"},
+			method:     "GET",
+			path:       fmt.Sprintf("/repos/%s/contents/.github/workflows", repository),
+		},
+		{
+			collectors: []string{"security"},
+			method:     "GET",
+			path:       fmt.Sprintf("/repos/%s/actions/secrets?per_page=1", repository),
+		},
+		{
+			collectors: []string{"security"},
+			method:     "GET",
+			path:       fmt.Sprintf("/repos/%s/actions/variables?per_page=1", repository),
+		},
+		{
+			collectors: []string{"security"},
+			method:     "GET",
+			path:       fmt.Sprintf("/repos/%s/actions/permissions", repository),
+		},
+		{
+			collectors: []string{"deployments_api"},
+			method:     "GET",
+			path:       fmt.Sprintf("/repos/%s/deployments?per_page=1", repository),
+		},
+	}
+
+	if ids.workflowID != 0 {
+		endpoints = append(endpoints,
+			checkEndpoint{
+				collectors: []string{"workflows"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/actions/workflows/%d/timing", repository, ids.workflowID),
+			},
+			checkEndpoint{
+				collectors: []string{"deployment_workflows"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/actions/workflows/%d/runs?per_page=1", repository, ids.workflowID),
+			},
+		)
+	} else {
+		endpoints = append(endpoints,
+			checkEndpoint{
+				collectors: []string{"workflows"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/actions/workflows/{workflow_id}/timing", repository),
+				skip:       "no workflow to check",
+			},
+			checkEndpoint{
+				collectors: []string{"deployment_workflows"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/actions/workflows/{workflow_id}/runs", repository),
+				skip:       "no workflow to check",
+			},
+		)
+	}
+
+	if ids.runID != 0 {
+		endpoints = append(endpoints,
+			checkEndpoint{
+				collectors: []string{"run_traces"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/actions/runs/%d/jobs?per_page=1", repository, ids.runID),
+			},
+			checkEndpoint{
+				collectors: []string{"flaky"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/actions/runs/%d/attempts/%d/jobs?per_page=1", repository, ids.runID, ids.runAttempt),
+			},
+			checkEndpoint{
+				collectors: []string{"runs"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/actions/runs/%d/pending_deployments", repository, ids.runID),
+			},
+		)
+	} else {
+		endpoints = append(endpoints,
+			checkEndpoint{
+				collectors: []string{"run_traces"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/actions/runs/{run_id}/jobs", repository),
+				skip:       "no run to check",
+			},
+			checkEndpoint{
+				collectors: []string{"flaky"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/actions/runs/{run_id}/attempts/{attempt_number}/jobs", repository),
+				skip:       "no run to check",
+			},
+			checkEndpoint{
+				collectors: []string{"runs"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/actions/runs/{run_id}/pending_deployments", repository),
+				skip:       "no run to check",
+			},
+		)
+	}
+
+	if ids.deploymentID != 0 {
+		endpoints = append(endpoints,
+			checkEndpoint{
+				collectors: []string{"deployments_api"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/deployments/%d/statuses?per_page=1", repository, ids.deploymentID),
+			},
+			checkEndpoint{
+				collectors: []string{"deployments_api"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/commits/%s", repository, ids.sha),
+			},
+		)
+	} else {
+		endpoints = append(endpoints,
+			checkEndpoint{
+				collectors: []string{"deployments_api"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/deployments/{deployment_id}/statuses", repository),
+				skip:       "no deployment to check",
+			},
+			checkEndpoint{
+				collectors: []string{"deployments_api"},
+				method:     "GET",
+				path:       fmt.Sprintf("/repos/%s/commits/{ref}", repository),
+				skip:       "no deployment to check",
+			},
+		)
+	}
+	if backend == "graphql" {
+		s := strings.SplitN(repository, "/", 2)
+		if len(s) == 2 {
+			body, _ := json.Marshal(map[string]interface{}{
+				"query": "query($owner: String!, $name: String!) { repository(owner: $owner, name: $name) { id } }",
+				"variables": map[string]string{
+					"owner": s[0],
+					"name":  s[1],
+				},
+			})
+			endpoints = append(endpoints, checkEndpoint{
+				collectors: []string{"runs"},
+				method:     "POST",
+				path:       "/graphql",
+				body:       body,
+			})
+		}
+	}
+	return endpoints
+}
+
+func checkRequest(httpClient IHTTPClient, method string, path string, requestBody []byte) CheckResult {
+	result := CheckResult{
+		Check:  "endpoint",
+		Target: fmt.Sprintf("%s %s", method, path),
+	}
+	response, body, err := doCheckRequest(httpClient, method, path, requestBody)
+	if err != nil {
+		result.Result = checkFail
+		result.Detail = err.Error()
+		return result
+	}
+	result.Detail = responseDetail(response, body)
+	if response.StatusCode/100 != 2 {
+		result.Result = checkFail
+		return result
+	}
+	var graphQLResponse struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &graphQLResponse); err == nil && len(graphQLResponse.Errors) > 0 {
+		result.Result = checkFail
+		result.Detail = graphQLResponse.Errors[0].Message
+		return result
+	}
+	result.Result = checkPass
+	return result
+}
+
+func doCheckRequest(httpClient IHTTPClient, method string, path string, requestBody []byte) (*http.Response, []byte, error) {
+	var reader io.Reader
+	if requestBody != nil {
+		reader = bytes.NewReader(requestBody)
+	}
+	request, err := http.NewRequest(method, "https://api.github.com"+path, reader)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+	return response, body, nil
+}
+
+func responseDetail(response *http.Response, body []byte) string {
+	var message struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &message); err == nil && message.Message != "" {
+		return fmt.Sprintf("%s: %s", response.Status, message.Message)
+	}
+	return response.Status
+}
+
+func credentialName(tokenSource ITokenSource) string {
+	if _, ok := tokenSource.(*client.AppTokenSource); ok {
+		return "github app"
+	}
+	return "token"
+}
+
+func isAnyEnabled(enabled map[string]bool, collectors []string) bool {
+	for _, c := range collectors {
+		if enabled[c] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github-actions-exporter/pkg/client"
+	"github-actions-exporter/pkg/server/collector"
+	"github-actions-exporter/pkg/server/shard"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"golang.org/x/xerrors"
+)
+
+type Series struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+func Collect(a *Args, w io.Writer) error {
+	switch a.OutputFormat {
+	case "text", "openmetrics", "json":
+	default:
+		return xerrors.Errorf("unknown output format: %s", a.OutputFormat)
+	}
+
+	i := NewInstance()
+	logger, err := newLogger(a, os.Stderr)
+	if err != nil {
+		return xerrors.Errorf("failed to create logger: %w", err)
+	}
+	i.SetLogger(logger)
+
+	shardIndex, err := resolveShardIndex(a)
+	if err != nil {
+		return xerrors.Errorf("failed to resolve shard index: %w", err)
+	}
+	repositories := shard.Filter(a.Repositories, shardIndex, a.ShardCount)
+
+	store, err := newStore(i, a)
+	if err != nil {
+		return xerrors.Errorf("failed to create state store: %w", err)
+	}
+	gitHubClient, err := newGitHubClient(i, a, shardIndex, store)
+	if err != nil {
+		return xerrors.Errorf("failed to create github client: %w", err)
+	}
+	backend, err := collector.NewBackend(a.Backend, backendCacheTTL(a), i.Logger(), gitHubClient)
+	if err != nil {
+		return xerrors.Errorf("failed to create backend: %w", err)
+	}
+	policy, err := newLabelPolicy(a)
+	if err != nil {
+		return xerrors.Errorf("failed to create label policy: %w", err)
+	}
+	deployments, err := newDeploymentMatcher(a)
+	if err != nil {
+		return xerrors.Errorf("failed to create deployment matcher: %w", err)
+	}
+	collectors, err := collector.NewCollectors(a.Collectors, repositories, backend, backendCacheTTL(a), store, nil, policy, deployments, a.RequiredWorkflowPattern, a.ActorTopN, i.Logger(), gitHubClient)
+	if err != nil {
+		return xerrors.Errorf("failed to create collectors: %w", err)
+	}
+
+	var failed []string
+	registry := prometheus.NewRegistry()
+	if policy != nil {
+		registry.MustRegister(policy)
+	}
+	for name, c := range collectors {
+		if err := c.Scrape(context.Background()); err != nil {
+			i.Logger().Errorw("Failed to scrape",
+				"collector", name,
+				"error", err.Error(),
+			)
+			failed = append(failed, name)
+		}
+		if err := registry.Register(c); err != nil {
+			return xerrors.Errorf("failed to register %s collector: %w", name, err)
+		}
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return xerrors.Errorf("failed to gather metrics: %w", err)
+	}
+	if err := writeMetricFamilies(w, families, a.OutputFormat); err != nil {
+		return xerrors.Errorf("failed to write metrics: %w", err)
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return xerrors.Errorf("failed to scrape %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func writeMetricFamilies(w io.Writer, families []*dto.MetricFamily, format string) error {
+	switch format {
+	case "text":
+		for _, family := range families {
+			if _, err := expfmt.MetricFamilyToText(w, family); err != nil {
+				return xerrors.Errorf("failed to encode %s: %w", family.GetName(), err)
+			}
+		}
+	case "openmetrics":
+		for _, family := range families {
+			if _, err := expfmt.MetricFamilyToOpenMetrics(w, family); err != nil {
+				return xerrors.Errorf("failed to encode %s: %w", family.GetName(), err)
+			}
+		}
+		if _, err := expfmt.FinalizeOpenMetrics(w); err != nil {
+			return xerrors.Errorf("failed to finalize: %w", err)
+		}
+	case "json":
+		series := []Series{}
+		for _, sample := range client.FlattenMetricFamilies(families, time.Now()) {
+			s := Series{
+				Labels: make(map[string]string),
+				Value:  sample.Value,
+			}
+			for _, label := range sample.Labels {
+				if label.Name == "__name__" {
+					s.Name = label.Value
+					continue
+				}
+				s.Labels[label.Name] = label.Value
+			}
+			series = append(series, s)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(series); err != nil {
+			return xerrors.Errorf("failed to encode: %w", err)
+		}
+	default:
+		return xerrors.Errorf("unknown output format: %s", format)
+	}
+	return nil
+}
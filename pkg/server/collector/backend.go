@@ -0,0 +1,244 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// IBackend fetches data of repositories, and returns the results of succeeded
+// repositories with the errors of failed ones keyed by repository.
+type IBackend interface {
+	FetchRunsCounts(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error)
+	FetchRuns(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]WorkflowRun, map[string]error)
+	FetchWorkflows(ctx context.Context, repositories []string) (map[string][]Workflow, map[string]error)
+}
+
+func wrapErrors(errs map[string]error, msg string) map[string]error {
+	wrapped := make(map[string]error)
+	for repository, err := range errs {
+		wrapped[repository] = xerrors.Errorf("%s: %w", msg, err)
+	}
+	return wrapped
+}
+
+type restRunsCacheEntry struct {
+	runs      []WorkflowRun
+	since     uint64
+	fetchedAt time.Time
+}
+
+// covers reports whether the cached runs include every run which a fetch
+// since the given ID would return.
+func (e restRunsCacheEntry) covers(since uint64) bool {
+	return since == 0 || (e.since != 0 && since >= e.since)
+}
+
+type restWorkflowsCacheEntry struct {
+	workflows []Workflow
+	fetchedAt time.Time
+}
+
+// RESTBackend caches the runs and workflows of each repository for cacheTTL,
+// so that the collectors scraping in the same cycle share the responses.
+type RESTBackend struct {
+	cacheTTL       time.Duration
+	logger         ILogger
+	httpClient     IHTTPClient
+	runsMutex      sync.Mutex
+	runsCache      map[string]restRunsCacheEntry
+	workflowsMutex sync.Mutex
+	workflowsCache map[string]restWorkflowsCacheEntry
+}
+
+func NewRESTBackend(
+	cacheTTL time.Duration,
+	logger ILogger,
+	httpClient IHTTPClient,
+) *RESTBackend {
+	return &RESTBackend{
+		cacheTTL:       cacheTTL,
+		logger:         logger,
+		httpClient:     httpClient,
+		runsCache:      make(map[string]restRunsCacheEntry),
+		workflowsCache: make(map[string]restWorkflowsCacheEntry),
+	}
+}
+
+func (b *RESTBackend) FetchRunsCounts(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+	counts := make(map[string]map[string]int)
+	errs := make(map[string]error)
+	for _, repository := range repositories {
+		m := make(map[string]int)
+		for _, status := range statuses {
+			count, err := b.fetchRunsCount(ctx, repository, status)
+			if err != nil {
+				errs[repository] = xerrors.Errorf("failed to execute fetchRunsCount: %w", err)
+				break
+			}
+			m[status] = *count
+		}
+		if _, ok := errs[repository]; !ok {
+			counts[repository] = m
+		}
+	}
+	return counts, errs
+}
+
+func (b *RESTBackend) fetchRunsCount(ctx context.Context, repository string, status string) (*int, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/runs?status=%s", repository, status), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, b.httpClient, b.logger, request, repository, "/repos/{repository}/actions/runs")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var workflowRunsResponse WorkflowRunsResponse
+	if err := json.Unmarshal(body, &workflowRunsResponse); err != nil {
+		return nil, xerrors.Errorf("failed to parse response: %w", err)
+	}
+
+	if workflowRunsResponse.TotalCount == nil {
+		return nil, xerrors.Errorf("bad response: %s", string(body))
+	}
+
+	return workflowRunsResponse.TotalCount, nil
+}
+
+func (b *RESTBackend) FetchRuns(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]WorkflowRun, map[string]error) {
+	b.runsMutex.Lock()
+	defer b.runsMutex.Unlock()
+
+	now := time.Now()
+	runs := make(map[string][]WorkflowRun)
+	errs := make(map[string]error)
+	for _, repository := range repositories {
+		if entry, ok := b.runsCache[repository]; ok && now.Sub(entry.fetchedAt) < b.cacheTTL && entry.covers(since[repository]) {
+			runs[repository] = append([]WorkflowRun(nil), entry.runs...)
+			continue
+		}
+		r, err := b.fetchRuns(ctx, repository, since[repository], 1)
+		if err != nil {
+			errs[repository] = xerrors.Errorf("failed to execute fetchRuns: %w", err)
+			continue
+		}
+		b.runsCache[repository] = restRunsCacheEntry{
+			runs:      r,
+			since:     since[repository],
+			fetchedAt: now,
+		}
+		runs[repository] = append([]WorkflowRun(nil), r...)
+	}
+	return runs, errs
+}
+
+func (b *RESTBackend) fetchRuns(ctx context.Context, repository string, since uint64, page int) ([]WorkflowRun, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/runs?per_page=%d&page=%d", repository, runsPerPage, page), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, b.httpClient, b.logger, request, repository, "/repos/{repository}/actions/runs")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var workflowRunsResponse WorkflowRunsResponse
+	if err := json.Unmarshal(body, &workflowRunsResponse); err != nil {
+		return nil, xerrors.Errorf("failed to parse response: %w", err)
+	}
+	if workflowRunsResponse.TotalCount == nil {
+		return nil, xerrors.Errorf("bad response: %s", string(body))
+	}
+
+	n := len(workflowRunsResponse.WorkflowRuns)
+	if since > 0 && page < runsMaxPage && n == runsPerPage && workflowRunsResponse.WorkflowRuns[n-1].ID >= since {
+		runs, err := b.fetchRuns(ctx, repository, since, page+1)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to execute fetchRuns: %w", err)
+		}
+		workflowRunsResponse.WorkflowRuns = append(workflowRunsResponse.WorkflowRuns, runs...)
+	}
+
+	return workflowRunsResponse.WorkflowRuns, nil
+}
+
+func (b *RESTBackend) FetchWorkflows(ctx context.Context, repositories []string) (map[string][]Workflow, map[string]error) {
+	b.workflowsMutex.Lock()
+	defer b.workflowsMutex.Unlock()
+
+	now := time.Now()
+	workflows := make(map[string][]Workflow)
+	errs := make(map[string]error)
+	for _, repository := range repositories {
+		if entry, ok := b.workflowsCache[repository]; ok && now.Sub(entry.fetchedAt) < b.cacheTTL {
+			workflows[repository] = append([]Workflow(nil), entry.workflows...)
+			continue
+		}
+		w, err := b.fetchWorkflows(ctx, repository, 1)
+		if err != nil {
+			errs[repository] = xerrors.Errorf("failed to execute fetchWorkflows: %w", err)
+			continue
+		}
+		b.workflowsCache[repository] = restWorkflowsCacheEntry{
+			workflows: w,
+			fetchedAt: now,
+		}
+		workflows[repository] = append([]Workflow(nil), w...)
+	}
+	return workflows, errs
+}
+
+func (b *RESTBackend) fetchWorkflows(ctx context.Context, repository string, page int) ([]Workflow, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/workflows?per_page=%d&page=%d", repository, workflowsPerPage, page), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, b.httpClient, b.logger, request, repository, "/repos/{repository}/actions/workflows")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var workflowsResponse WorkflowsResponse
+	if err := json.Unmarshal(body, &workflowsResponse); err != nil {
+		return nil, xerrors.Errorf("failed to parse response: %w", err)
+	}
+	if workflowsResponse.TotalCount == nil {
+		return nil, xerrors.Errorf("bad response: %s", string(body))
+	}
+
+	if *workflowsResponse.TotalCount > workflowsPerPage*page {
+		workflows, err := b.fetchWorkflows(ctx, repository, page+1)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to execute fetchWorkflows: %w", err)
+		}
+		workflowsResponse.Workflows = append(workflowsResponse.Workflows, workflows...)
+	}
+
+	return workflowsResponse.Workflows, nil
+}
@@ -0,0 +1,102 @@
+package collector_test
+
+import (
+	"context"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRESTBackendFetchRunsCache(t *testing.T) {
+	tests := []struct {
+		name         string
+		since        []uint64
+		wantRequests int32
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]uint64{0, 0},
+			1,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]uint64{5, 5},
+			1,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]uint64{5, 0},
+			1,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]uint64{0, 5},
+			2,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]uint64{5, 3},
+			2,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		since := tt.since
+		wantRequests := tt.wantRequests
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests int32
+			receiver := collector.NewRESTBackend(
+				time.Minute,
+				loggerMock{
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				httpClientMock{
+					fakeDo: func(request *http.Request) (*http.Response, error) {
+						atomic.AddInt32(&requests, 1)
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(`{"total_count": 2, "workflow_runs": [{"id": 10}, {"id": 9}]}`)),
+						}, nil
+					},
+				},
+			)
+			for _, s := range since {
+				got, errs := receiver.FetchRuns(context.Background(), []string{"fake/fake"}, map[string]uint64{"fake/fake": s})
+				if len(errs) > 0 {
+					t.Fatal(errs)
+				}
+				if diff := cmp.Diff([]uint64{10, 9}, []uint64{got["fake/fake"][0].ID, got["fake/fake"][1].ID}); diff != "" {
+					t.Errorf("(-want +got):\n%s", diff)
+				}
+			}
+			if got := atomic.LoadInt32(&requests); got != wantRequests {
+				t.Errorf("want %d requests, got %d", wantRequests, got)
+			}
+		})
+	}
+}
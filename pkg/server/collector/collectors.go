@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/xerrors"
+)
+
+var (
+	DefaultCollectors = []string{
+		"runs",
+		"runners",
+		"workflows",
+	}
+)
+
+type ICollector interface {
+	prometheus.Collector
+	Scrape(ctx context.Context) error
+	StartLoop(ctx context.Context, interval time.Duration)
+}
+
+func NewBackend(name string, cacheTTL time.Duration, logger ILogger, httpClient IHTTPClient) (IBackend, error) {
+	switch name {
+	case "rest":
+		return NewRESTBackend(
+			cacheTTL,
+			logger,
+			httpClient,
+		), nil
+	case "graphql":
+		return NewGraphQLBackend(
+			cacheTTL,
+			logger,
+			httpClient,
+		), nil
+	default:
+		return nil, xerrors.Errorf("unknown backend: %s", name)
+	}
+}
+
+func NewCollectors(
+	names []string,
+	repositories []string,
+	backend IBackend,
+	cacheTTL time.Duration,
+	store IStore,
+	status *StatusRecorder,
+	policy *LabelPolicy,
+	deployments *DeploymentMatcher,
+	requiredWorkflowPattern string,
+	actorTopN int,
+	logger ILogger,
+	httpClient IHTTPClient,
+) (map[string]ICollector, error) {
+	var requiredWorkflows *regexp.Regexp
+	if requiredWorkflowPattern != "" {
+		r, err := regexp.Compile(requiredWorkflowPattern)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to compile %s: %w", requiredWorkflowPattern, err)
+		}
+		requiredWorkflows = r
+	}
+	// pull_requests and flaky need timestamps of runs, so they share a REST
+	// backend whose cache lets them fetch runs once per cycle.
+	var restBackend *RESTBackend
+	switch b := backend.(type) {
+	case *RESTBackend:
+		restBackend = b
+	case *GraphQLBackend:
+		restBackend = b.rest
+	default:
+		restBackend = NewRESTBackend(cacheTTL, logger, httpClient)
+	}
+	collectors := make(map[string]ICollector)
+	for _, name := range names {
+		status.Register(name, repositories)
+		switch name {
+		case "runs":
+			collectors[name] = NewRunsCollector(
+				repositories,
+				backend,
+				store,
+				status,
+				policy,
+				actorTopN,
+				logger,
+				httpClient,
+			)
+		case "runners":
+			collectors[name] = NewRunnersCollector(
+				repositories,
+				status,
+				policy,
+				logger,
+				httpClient,
+			)
+		case "workflows":
+			collectors[name] = NewWorkflowsCollector(
+				repositories,
+				backend,
+				store,
+				status,
+				policy,
+				deployments,
+				logger,
+				httpClient,
+			)
+		case "pull_requests":
+			collectors[name] = NewPullRequestsCollector(
+				repositories,
+				restBackend,
+				store,
+				status,
+				policy,
+				requiredWorkflows,
+				logger,
+				httpClient,
+			)
+		case "flaky":
+			collectors[name] = NewFlakyCollector(
+				repositories,
+				restBackend,
+				store,
+				status,
+				policy,
+				logger,
+				httpClient,
+			)
+		case "security":
+			collectors[name] = NewSecurityCollector(
+				repositories,
+				status,
+				policy,
+				logger,
+				httpClient,
+			)
+		case "workflow_files":
+			collectors[name] = NewWorkflowFilesCollector(
+				repositories,
+				backend,
+				status,
+				policy,
+				logger,
+				httpClient,
+			)
+		default:
+			return nil, xerrors.Errorf("unknown collector: %s", name)
+		}
+	}
+	return collectors, nil
+}
@@ -0,0 +1,629 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	deploymentsLabelNames       = []string{"repository", "environment", "conclusion"}
+	changeFailureRateLabelNames = []string{"repository", "environment"}
+	leadTimeLabelNames          = []string{"repository", "environment"}
+)
+
+var (
+	deploymentsPerPage        = 100
+	deploymentsMaxPage        = 10
+	deploymentStatusesPerPage = 10
+	deploymentsSource         = "deployments"
+	deploymentLeadTimes       = []float64{300, 900, 1800, 3600, 7200, 14400, 43200, 86400, 172800, 604800}
+	// deploymentWindow is the period over which the change failure rate is computed.
+	deploymentWindow = 7 * 24 * time.Hour
+	// deploymentPendingMaxAge is how long an unconcluded deployment holds
+	// back the watermark, so that an abandoned one does not pin it forever.
+	deploymentPendingMaxAge = 24 * time.Hour
+)
+
+type DeploymentMatcher struct {
+	workflow       *regexp.Regexp
+	deploymentsAPI bool
+}
+
+func NewDeploymentMatcher(workflowPattern string, deploymentsAPI bool) (*DeploymentMatcher, error) {
+	if workflowPattern == "" && !deploymentsAPI {
+		return nil, nil
+	}
+	m := &DeploymentMatcher{
+		deploymentsAPI: deploymentsAPI,
+	}
+	if workflowPattern != "" {
+		r, err := regexp.Compile(workflowPattern)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to compile %s: %w", workflowPattern, err)
+		}
+		m.workflow = r
+	}
+	return m, nil
+}
+
+func (m *DeploymentMatcher) environment(workflow Workflow) (string, bool) {
+	if m == nil || m.workflow == nil {
+		return "", false
+	}
+	for _, s := range []string{workflow.Name, workflow.Path} {
+		match := m.workflow.FindStringSubmatch(s)
+		if match == nil {
+			continue
+		}
+		for i, name := range m.workflow.SubexpNames() {
+			if name == "environment" && match[i] != "" {
+				return match[i], true
+			}
+		}
+		return workflow.Name, true
+	}
+	return "", false
+}
+
+type Deployment struct {
+	ID          uint64
+	Environment string
+	Conclusion  string
+	CreatedAt   string
+	CommittedAt string
+	DeployedAt  string
+}
+
+func (d Deployment) expired(now time.Time) bool {
+	createdAt, err := time.Parse(time.RFC3339, d.CreatedAt)
+	return err == nil && now.Sub(createdAt) > deploymentPendingMaxAge
+}
+
+func (d Deployment) leadTime() (time.Duration, bool) {
+	committedAt, err := time.Parse(time.RFC3339, d.CommittedAt)
+	if err != nil {
+		return 0, false
+	}
+	deployedAt, err := time.Parse(time.RFC3339, d.DeployedAt)
+	if err != nil {
+		return 0, false
+	}
+	return deployedAt.Sub(committedAt), true
+}
+
+type GitHubDeployment struct {
+	ID          uint64 `json:"id"`
+	SHA         string `json:"sha"`
+	Ref         string `json:"ref"`
+	Environment string `json:"environment"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type GitHubDeploymentStatus struct {
+	ID        uint64 `json:"id"`
+	State     string `json:"state"`
+	CreatedAt string `json:"created_at"`
+}
+
+// LeadTimes holds a histogram of lead times with cumulative bucket counts.
+type LeadTimes struct {
+	Buckets []uint64 `json:"buckets"`
+	Sum     float64  `json:"sum"`
+	Count   uint64   `json:"count"`
+}
+
+func (l *LeadTimes) observe(seconds float64) {
+	if len(l.Buckets) != len(deploymentLeadTimes) {
+		l.Buckets = make([]uint64, len(deploymentLeadTimes))
+	}
+	for i, upperBound := range deploymentLeadTimes {
+		if seconds <= upperBound {
+			l.Buckets[i]++
+		}
+	}
+	l.Sum += seconds
+	l.Count++
+}
+
+type DeploymentsState struct {
+	Watermarks  map[string]uint64            `json:"watermarks"`
+	Counted     map[string][]uint64          `json:"counted"`
+	Deployments map[string]map[string]uint64 `json:"deployments"`
+	// Days holds the counts by environment, day and conclusion within the
+	// deployment window.
+	Days      map[string]map[string]map[string]uint64 `json:"days"`
+	LeadTimes map[string]*LeadTimes                   `json:"lead_times"`
+}
+
+// changeFailureRates returns the ratio of failed deployments by environment within the deployment window.
+func (s *DeploymentsState) changeFailureRates() map[string]float64 {
+	rates := make(map[string]float64)
+	for environment, days := range s.Days {
+		var success, failure uint64
+		for _, m := range days {
+			success += m["success"]
+			failure += m["failure"]
+		}
+		if success+failure == 0 {
+			continue
+		}
+		rates[environment] = float64(failure) / float64(success+failure)
+	}
+	return rates
+}
+
+func (s *DeploymentsState) pruneDays(now time.Time) {
+	oldest := now.Add(-deploymentWindow).UTC().Format(dayLayout)
+	for environment, days := range s.Days {
+		for day := range days {
+			if day < oldest {
+				delete(days, day)
+			}
+		}
+		if len(days) == 0 {
+			delete(s.Days, environment)
+		}
+	}
+}
+
+func (s *DeploymentsState) counted(source string, id uint64) bool {
+	if id < s.Watermarks[source] {
+		return true
+	}
+	for _, counted := range s.Counted[source] {
+		if counted == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *DeploymentsState) update(source string, deployments []Deployment, now time.Time) []Deployment {
+	if s.Watermarks == nil {
+		s.Watermarks = make(map[string]uint64)
+	}
+	if s.Counted == nil {
+		s.Counted = make(map[string][]uint64)
+	}
+	if s.Deployments == nil {
+		s.Deployments = make(map[string]map[string]uint64)
+	}
+	if s.Days == nil {
+		s.Days = make(map[string]map[string]map[string]uint64)
+	}
+	if s.LeadTimes == nil {
+		s.LeadTimes = make(map[string]*LeadTimes)
+	}
+	initialized := s.Watermarks[source] != 0
+	counted := make(map[uint64]struct{})
+	for _, id := range s.Counted[source] {
+		counted[id] = struct{}{}
+	}
+
+	var completed []Deployment
+	watermark := s.Watermarks[source]
+	if watermark == 0 {
+		watermark = 1
+	}
+	for _, deployment := range deployments {
+		if deployment.ID >= watermark {
+			watermark = deployment.ID + 1
+		}
+	}
+	for _, deployment := range deployments {
+		if deployment.ID < s.Watermarks[source] {
+			continue
+		}
+		if deployment.Conclusion == "" {
+			if deployment.expired(now) {
+				continue
+			}
+			if deployment.ID < watermark {
+				watermark = deployment.ID
+			}
+			continue
+		}
+		if _, ok := counted[deployment.ID]; ok {
+			continue
+		}
+		counted[deployment.ID] = struct{}{}
+		if !initialized {
+			continue
+		}
+		completed = append(completed, deployment)
+		if s.Deployments[deployment.Environment] == nil {
+			s.Deployments[deployment.Environment] = make(map[string]uint64)
+		}
+		s.Deployments[deployment.Environment][deployment.Conclusion]++
+
+		day := now.UTC().Format(dayLayout)
+		if s.Days[deployment.Environment] == nil {
+			s.Days[deployment.Environment] = make(map[string]map[string]uint64)
+		}
+		if s.Days[deployment.Environment][day] == nil {
+			s.Days[deployment.Environment][day] = make(map[string]uint64)
+		}
+		s.Days[deployment.Environment][day][deployment.Conclusion]++
+
+		if deployment.Conclusion != "success" {
+			continue
+		}
+		leadTime, ok := deployment.leadTime()
+		if !ok {
+			continue
+		}
+		if s.LeadTimes[deployment.Environment] == nil {
+			s.LeadTimes[deployment.Environment] = &LeadTimes{}
+		}
+		s.LeadTimes[deployment.Environment].observe(leadTime.Seconds())
+	}
+	s.pruneDays(now)
+
+	s.Watermarks[source] = watermark
+	ids := []uint64{}
+	for id := range counted {
+		if id >= watermark {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	s.Counted[source] = ids
+	return completed
+}
+
+func newDeploymentsCounterVec() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deployments_total",
+		Help:      "Total number of finished deployments",
+	}, deploymentsLabelNames)
+}
+
+func newChangeFailureRateGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "deployment_change_failure_rate",
+		Help:      "Ratio of failed deployments to deployments finished in the last 7 days",
+	}, changeFailureRateLabelNames)
+}
+
+// leadTimeHistograms exports the lead times of the states as const
+// histograms, so that they are rebuilt each cycle like the other vectors.
+// Series merged by the label policy are summed up.
+type leadTimeHistograms struct {
+	desc   *prometheus.Desc
+	series map[string]*leadTimeSeries
+}
+
+type leadTimeSeries struct {
+	labels []string
+	LeadTimes
+}
+
+func newLeadTimeHistograms() *leadTimeHistograms {
+	return &leadTimeHistograms{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "deployment_lead_time_seconds"),
+			"Time from commit until its successful deployment",
+			leadTimeLabelNames,
+			nil,
+		),
+		series: make(map[string]*leadTimeSeries),
+	}
+}
+
+func (h *leadTimeHistograms) add(labels []string, leadTimes *LeadTimes) {
+	key := strings.Join(labels, "\x00")
+	s, ok := h.series[key]
+	if !ok {
+		s = &leadTimeSeries{
+			labels: labels,
+			LeadTimes: LeadTimes{
+				Buckets: make([]uint64, len(deploymentLeadTimes)),
+			},
+		}
+		h.series[key] = s
+	}
+	for i := range s.Buckets {
+		if i < len(leadTimes.Buckets) {
+			s.Buckets[i] += leadTimes.Buckets[i]
+		}
+	}
+	s.Sum += leadTimes.Sum
+	s.Count += leadTimes.Count
+}
+
+func (h *leadTimeHistograms) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.desc
+}
+
+func (h *leadTimeHistograms) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range h.series {
+		buckets := make(map[float64]uint64)
+		for i, upperBound := range deploymentLeadTimes {
+			buckets[upperBound] = s.Buckets[i]
+		}
+		ch <- prometheus.MustNewConstHistogram(h.desc, s.Count, s.Sum, buckets, s.labels...)
+	}
+}
+
+func (c *WorkflowsCollector) stateKey(repository string) string {
+	return fmt.Sprintf("deployments/%s", repository)
+}
+
+func (c *WorkflowsCollector) loadState(repository string) (*DeploymentsState, error) {
+	c.mutex.RLock()
+	state, ok := c.states[repository]
+	c.mutex.RUnlock()
+	if ok {
+		return state, nil
+	}
+	state = &DeploymentsState{}
+	b, err := c.store.Get(c.stateKey(repository))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get state: %w", err)
+	}
+	if b != nil {
+		if err := json.Unmarshal(b, state); err != nil {
+			return nil, xerrors.Errorf("failed to parse state: %w", err)
+		}
+	}
+	c.mutex.Lock()
+	c.states[repository] = state
+	c.mutex.Unlock()
+	return state, nil
+}
+
+func (c *WorkflowsCollector) saveState(repository string, state *DeploymentsState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return xerrors.Errorf("failed to marshal state: %w", err)
+	}
+	if err := c.store.Put(c.stateKey(repository), b); err != nil {
+		return xerrors.Errorf("failed to put state: %w", err)
+	}
+	return nil
+}
+
+func (c *WorkflowsCollector) get(ctx context.Context, repository string, url string, endpoint string, v interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, endpoint)
+	if err != nil {
+		return xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return xerrors.Errorf("failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return xerrors.Errorf("failed to parse response: %s", string(body))
+	}
+	return nil
+}
+
+// fetchWorkflowDeployments fetches runs of the workflow, following the pages
+// until reaching the watermark once the state is initialized.
+func (c *WorkflowsCollector) fetchWorkflowDeployments(ctx context.Context, repository string, workflow Workflow, environment string, since uint64) ([]Deployment, error) {
+	var runs []WorkflowRun
+	for page := 1; ; page++ {
+		var workflowRunsResponse WorkflowRunsResponse
+		if err := c.get(ctx, repository,
+			fmt.Sprintf("https://api.github.com/repos/%s/actions/workflows/%d/runs?per_page=%d&page=%d", repository, workflow.ID, deploymentsPerPage, page),
+			"/repos/{repository}/actions/workflows/{workflow_id}/runs",
+			&workflowRunsResponse,
+		); err != nil {
+			return nil, xerrors.Errorf("failed to get workflow runs: %w", err)
+		}
+		r := workflowRunsResponse.WorkflowRuns
+		runs = append(runs, r...)
+		if since == 0 || page >= deploymentsMaxPage || len(r) < deploymentsPerPage || r[len(r)-1].ID < since {
+			break
+		}
+	}
+
+	var deployments []Deployment
+	for _, run := range runs {
+		deployment := Deployment{
+			ID:          run.ID,
+			Environment: environment,
+			CreatedAt:   run.CreatedAt,
+			DeployedAt:  run.UpdatedAt,
+		}
+		if run.HeadCommit != nil {
+			deployment.CommittedAt = run.HeadCommit.Timestamp
+		}
+		if run.Status == "completed" {
+			switch run.Conclusion {
+			case "success":
+				deployment.Conclusion = "success"
+			case "failure", "timed_out", "startup_failure":
+				deployment.Conclusion = "failure"
+			default:
+				continue
+			}
+		}
+		deployments = append(deployments, deployment)
+	}
+	return deployments, nil
+}
+
+func (c *WorkflowsCollector) fetchDeployments(ctx context.Context, repository string, state *DeploymentsState) ([]Deployment, error) {
+	since := state.Watermarks[deploymentsSource]
+	var gitHubDeployments []GitHubDeployment
+	for page := 1; ; page++ {
+		var d []GitHubDeployment
+		if err := c.get(ctx, repository,
+			fmt.Sprintf("https://api.github.com/repos/%s/deployments?per_page=%d&page=%d", repository, deploymentsPerPage, page),
+			"/repos/{repository}/deployments",
+			&d,
+		); err != nil {
+			return nil, xerrors.Errorf("failed to get deployments: %w", err)
+		}
+		gitHubDeployments = append(gitHubDeployments, d...)
+		if since == 0 || page >= deploymentsMaxPage || len(d) < deploymentsPerPage || d[len(d)-1].ID < since {
+			break
+		}
+	}
+
+	initialized := since != 0
+	var deployments []Deployment
+	for _, gitHubDeployment := range gitHubDeployments {
+		if state.counted(deploymentsSource, gitHubDeployment.ID) {
+			continue
+		}
+		var statuses []GitHubDeploymentStatus
+		if err := c.get(ctx, repository,
+			fmt.Sprintf("https://api.github.com/repos/%s/deployments/%d/statuses?per_page=%d", repository, gitHubDeployment.ID, deploymentStatusesPerPage),
+			"/repos/{repository}/deployments/{deployment_id}/statuses",
+			&statuses,
+		); err != nil {
+			return nil, xerrors.Errorf("failed to get statuses of deployment %d: %w", gitHubDeployment.ID, err)
+		}
+		deployment := Deployment{
+			ID:          gitHubDeployment.ID,
+			Environment: gitHubDeployment.Environment,
+			CreatedAt:   gitHubDeployment.CreatedAt,
+		}
+		// Statuses are listed from the latest. An inactive status is set
+		// when a later deployment supersedes this one, so the status before
+		// it tells the conclusion and the deploy time.
+		for _, status := range statuses {
+			if status.State == "inactive" {
+				continue
+			}
+			switch status.State {
+			case "success":
+				deployment.Conclusion = "success"
+				deployment.DeployedAt = status.CreatedAt
+			case "failure", "error":
+				deployment.Conclusion = "failure"
+				deployment.DeployedAt = status.CreatedAt
+			}
+			break
+		}
+		if initialized && deployment.Conclusion == "success" {
+			var commit struct {
+				Commit struct {
+					Committer struct {
+						Date string `json:"date"`
+					} `json:"committer"`
+				} `json:"commit"`
+			}
+			if err := c.get(ctx, repository,
+				fmt.Sprintf("https://api.github.com/repos/%s/commits/%s", repository, gitHubDeployment.SHA),
+				"/repos/{repository}/commits/{ref}",
+				&commit,
+			); err != nil {
+				return nil, xerrors.Errorf("failed to get commit %s: %w", gitHubDeployment.SHA, err)
+			}
+			deployment.CommittedAt = commit.Commit.Committer.Date
+		}
+		deployments = append(deployments, deployment)
+	}
+	return deployments, nil
+}
+
+func (c *WorkflowsCollector) scrapeRepositoryDeployments(
+	ctx context.Context,
+	repository string,
+	workflows []Workflow,
+	deploymentsCounterVec *prometheus.CounterVec,
+	changeFailureRateGaugeVec *prometheus.GaugeVec,
+	leadTime *leadTimeHistograms,
+	filter *LabelFilter,
+) error {
+	state, err := c.loadState(repository)
+	if err != nil {
+		return xerrors.Errorf("failed to load state: %w", err)
+	}
+
+	// The counts of the state are exported even if the fetch fails, so that
+	// the series of the repository are kept until the next success.
+	fetchErr := c.updateRepositoryDeployments(ctx, repository, workflows, state)
+
+	for environment, m := range state.Deployments {
+		for conclusion, count := range m {
+			labels, ok := filter.Apply("github_actions_deployments_total", deploymentsLabelNames,
+				repository,
+				environment,
+				conclusion,
+			)
+			if ok {
+				deploymentsCounterVec.WithLabelValues(labels...).Add(float64(count))
+			}
+		}
+	}
+	for environment, rate := range state.changeFailureRates() {
+		labels, ok := filter.Apply("github_actions_deployment_change_failure_rate", changeFailureRateLabelNames,
+			repository,
+			environment,
+		)
+		if ok {
+			changeFailureRateGaugeVec.WithLabelValues(labels...).Set(rate)
+		}
+	}
+	for environment, leadTimes := range state.LeadTimes {
+		labels, ok := filter.Apply("github_actions_deployment_lead_time_seconds", leadTimeLabelNames,
+			repository,
+			environment,
+		)
+		if ok {
+			leadTime.add(labels, leadTimes)
+		}
+	}
+	return fetchErr
+}
+
+func (c *WorkflowsCollector) updateRepositoryDeployments(
+	ctx context.Context,
+	repository string,
+	workflows []Workflow,
+	state *DeploymentsState,
+) error {
+	sources := make(map[string][]Deployment)
+	for _, workflow := range workflows {
+		environment, ok := c.deployments.environment(workflow)
+		if !ok {
+			continue
+		}
+		source := fmt.Sprintf("workflow/%d", workflow.ID)
+		deployments, err := c.fetchWorkflowDeployments(ctx, repository, workflow, environment, state.Watermarks[source])
+		if err != nil {
+			return xerrors.Errorf("failed to fetch deployments of workflow %d: %w", workflow.ID, err)
+		}
+		sources[source] = deployments
+	}
+	if c.deployments.deploymentsAPI {
+		deployments, err := c.fetchDeployments(ctx, repository, state)
+		if err != nil {
+			return xerrors.Errorf("failed to fetch deployments: %w", err)
+		}
+		sources[deploymentsSource] = deployments
+	}
+
+	for source, deployments := range sources {
+		state.update(source, deployments, time.Now())
+	}
+	if err := c.saveState(repository, state); err != nil {
+		return xerrors.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package collector_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWorkflowsCollectorScrapeDeployments(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		runs    []string
+		want    string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			`^Deploy (?P<environment>\w+)$`,
+			[]string{
+				`{"total_count": 1, "workflow_runs": [
+  {"id": 1, "status": "completed", "conclusion": "success"}
+]}`,
+				`{"total_count": 3, "workflow_runs": [
+  {"id": 3, "status": "in_progress"},
+  {"id": 2, "status": "completed", "conclusion": "failure"},
+  {"id": 1, "status": "completed", "conclusion": "success"}
+]}`,
+				`{"total_count": 4, "workflow_runs": [
+  {"id": 4, "status": "completed", "conclusion": "cancelled"},
+  {"id": 3, "status": "completed", "conclusion": "success", "updated_at": "2020-01-01T01:00:00Z", "head_commit": {"id": "a", "timestamp": "2020-01-01T00:00:00Z"}},
+  {"id": 2, "status": "completed", "conclusion": "failure"},
+  {"id": 1, "status": "completed", "conclusion": "success"}
+]}`,
+			},
+			`
+# HELP github_actions_deployment_change_failure_rate Ratio of failed deployments to deployments finished in the last 7 days
+# TYPE github_actions_deployment_change_failure_rate gauge
+github_actions_deployment_change_failure_rate{environment="production",repository="fake/fake"} 0.5
+# HELP github_actions_deployment_lead_time_seconds Time from commit until its successful deployment
+# TYPE github_actions_deployment_lead_time_seconds histogram
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="300"} 0
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="900"} 0
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="1800"} 0
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="3600"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="7200"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="14400"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="43200"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="86400"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="172800"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="604800"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="+Inf"} 1
+github_actions_deployment_lead_time_seconds_sum{environment="production",repository="fake/fake"} 3600
+github_actions_deployment_lead_time_seconds_count{environment="production",repository="fake/fake"} 1
+# HELP github_actions_deployments_total Total number of finished deployments
+# TYPE github_actions_deployments_total counter
+github_actions_deployments_total{conclusion="failure",environment="production",repository="fake/fake"} 1
+github_actions_deployments_total{conclusion="success",environment="production",repository="fake/fake"} 1
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			`deploy\.yml$`,
+			[]string{
+				`{"total_count": 0, "workflow_runs": []}`,
+				`{"total_count": 1, "workflow_runs": [
+  {"id": 1, "status": "completed", "conclusion": "timed_out"}
+]}`,
+			},
+			`
+# HELP github_actions_deployment_change_failure_rate Ratio of failed deployments to deployments finished in the last 7 days
+# TYPE github_actions_deployment_change_failure_rate gauge
+github_actions_deployment_change_failure_rate{environment="Deploy production",repository="fake/fake"} 1
+# HELP github_actions_deployments_total Total number of finished deployments
+# TYPE github_actions_deployments_total counter
+github_actions_deployments_total{conclusion="failure",environment="Deploy production",repository="fake/fake"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		pattern := tt.pattern
+		runs := tt.runs
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			deployments, err := collector.NewDeploymentMatcher(pattern, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			calls := 0
+			receiver := collector.NewWorkflowsCollector(
+				[]string{"fake/fake"},
+				&backendMock{
+					fakeFetchWorkflows: func(ctx context.Context, repositories []string) (map[string][]collector.Workflow, map[string]error) {
+						return map[string][]collector.Workflow{
+							"fake/fake": {
+								{ID: 1, Name: "Deploy production", Path: ".github/workflows/deploy.yml", State: "active"},
+								{ID: 2, Name: "CI", Path: ".github/workflows/ci.yml", State: "active"},
+							},
+						}, nil
+					},
+				},
+				&storeMock{
+					m: make(map[string][]byte),
+				},
+				nil,
+				nil,
+				deployments,
+				loggerMock{
+					fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+						t.Errorf("%s: %v", msg, keysAndValues)
+					},
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				httpClientMock{
+					fakeDo: func(request *http.Request) (*http.Response, error) {
+						body := `{"billable": {}}`
+						if request.URL.Path == "/repos/fake/fake/actions/workflows/1/runs" {
+							body = runs[calls]
+							calls++
+						} else if !strings.HasSuffix(request.URL.Path, "/timing") {
+							t.Errorf("unexpected request: %s", request.URL.Path)
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(body)),
+						}, nil
+					},
+				},
+			)
+			for range runs {
+				if err := receiver.Scrape(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+				"github_actions_deployments_total",
+				"github_actions_deployment_change_failure_rate",
+				"github_actions_deployment_lead_time_seconds",
+			); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestWorkflowsCollectorScrapeDeploymentsPages(t *testing.T) {
+	page := func(runs []string, from uint64, to uint64) string {
+		for id := from; id >= to; id-- {
+			runs = append(runs, fmt.Sprintf(`{"id": %d, "status": "completed", "conclusion": "success"}`, id))
+		}
+		return fmt.Sprintf(`{"workflow_runs": [%s]}`, strings.Join(runs, ","))
+	}
+	// The second scrape reads 3 pages, and the abandoned run 202 does not hold
+	// back the watermark.
+	responses := []map[string]string{
+		{
+			"1": page(nil, 1, 1),
+		},
+		{
+			"1": page([]string{`{"id": 202, "status": "in_progress", "created_at": "2020-01-01T00:00:00Z"}`}, 201, 103),
+			"2": page(nil, 102, 3),
+			"3": page(nil, 2, 1),
+		},
+	}
+
+	deployments, err := collector.NewDeploymentMatcher(`^Deploy (?P<environment>\w+)$`, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &storeMock{
+		m: make(map[string][]byte),
+	}
+	calls := 0
+	receiver := collector.NewWorkflowsCollector(
+		[]string{"fake/fake"},
+		&backendMock{
+			fakeFetchWorkflows: func(ctx context.Context, repositories []string) (map[string][]collector.Workflow, map[string]error) {
+				return map[string][]collector.Workflow{
+					"fake/fake": {
+						{ID: 1, Name: "Deploy production", Path: ".github/workflows/deploy.yml", State: "active"},
+					},
+				}, nil
+			},
+		},
+		store,
+		nil,
+		nil,
+		deployments,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+				t.Errorf("%s: %v", msg, keysAndValues)
+			},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				body := `{"billable": {}}`
+				if request.URL.Path == "/repos/fake/fake/actions/workflows/1/runs" {
+					var ok bool
+					body, ok = responses[calls][request.URL.Query().Get("page")]
+					if !ok {
+						t.Errorf("unexpected page: %s", request.URL.RawQuery)
+					}
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		},
+	)
+	for calls = range responses {
+		if err := receiver.Scrape(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := `
+# HELP github_actions_deployments_total Total number of finished deployments
+# TYPE github_actions_deployments_total counter
+github_actions_deployments_total{conclusion="success",environment="production",repository="fake/fake"} 200
+`
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want), "github_actions_deployments_total"); err != nil {
+		t.Error(err)
+	}
+	var state collector.DeploymentsState
+	if err := json.Unmarshal(store.m["deployments/fake/fake"], &state); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(uint64(203), state.Watermarks["workflow/1"]); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func TestWorkflowsCollectorScrapeDeploymentsAPI(t *testing.T) {
+	now := time.Now().UTC()
+	deployments, err := collector.NewDeploymentMatcher("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The failure counted in 2020 is out of the window of the change failure rate.
+	store := &storeMock{
+		m: map[string][]byte{
+			"deployments/fake/fake": []byte(`{
+  "watermarks": {"deployments": 5},
+  "deployments": {"production": {"failure": 1}},
+  "days": {"production": {"2020-01-01": {"failure": 1}}}
+}`),
+		},
+	}
+	responses := map[string]string{
+		"/repos/fake/fake/deployments": fmt.Sprintf(`[
+  {"id": 6, "sha": "b", "environment": "production", "created_at": "%s"},
+  {"id": 5, "sha": "a", "environment": "production", "created_at": "2020-01-01T00:00:00Z"}
+]`, now.Format(time.RFC3339)),
+		"/repos/fake/fake/deployments/6/statuses": `[{"id": 3, "state": "inactive", "created_at": "2020-01-01T00:00:00Z"}]`,
+		"/repos/fake/fake/deployments/5/statuses": `[
+  {"id": 2, "state": "inactive", "created_at": "2020-01-01T02:00:00Z"},
+  {"id": 1, "state": "success", "created_at": "2020-01-01T01:00:00Z"}
+]`,
+		"/repos/fake/fake/commits/a": `{"commit": {"committer": {"date": "2020-01-01T00:00:00Z"}}}`,
+	}
+	receiver := collector.NewWorkflowsCollector(
+		[]string{"fake/fake"},
+		&backendMock{
+			fakeFetchWorkflows: func(ctx context.Context, repositories []string) (map[string][]collector.Workflow, map[string]error) {
+				return map[string][]collector.Workflow{"fake/fake": {}}, nil
+			},
+		},
+		store,
+		nil,
+		nil,
+		deployments,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+				t.Errorf("%s: %v", msg, keysAndValues)
+			},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				body, ok := responses[request.URL.Path]
+				if !ok {
+					t.Errorf("unexpected request: %s", request.URL.Path)
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		},
+	)
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+# HELP github_actions_deployment_change_failure_rate Ratio of failed deployments to deployments finished in the last 7 days
+# TYPE github_actions_deployment_change_failure_rate gauge
+github_actions_deployment_change_failure_rate{environment="production",repository="fake/fake"} 0
+# HELP github_actions_deployment_lead_time_seconds Time from commit until its successful deployment
+# TYPE github_actions_deployment_lead_time_seconds histogram
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="300"} 0
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="900"} 0
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="1800"} 0
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="3600"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="7200"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="14400"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="43200"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="86400"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="172800"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="604800"} 1
+github_actions_deployment_lead_time_seconds_bucket{environment="production",repository="fake/fake",le="+Inf"} 1
+github_actions_deployment_lead_time_seconds_sum{environment="production",repository="fake/fake"} 3600
+github_actions_deployment_lead_time_seconds_count{environment="production",repository="fake/fake"} 1
+# HELP github_actions_deployments_total Total number of finished deployments
+# TYPE github_actions_deployments_total counter
+github_actions_deployments_total{conclusion="failure",environment="production",repository="fake/fake"} 1
+github_actions_deployments_total{conclusion="success",environment="production",repository="fake/fake"} 1
+`
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+		"github_actions_deployments_total",
+		"github_actions_deployment_change_failure_rate",
+		"github_actions_deployment_lead_time_seconds",
+	); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,455 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+	"golang.org/x/xerrors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	rerunsLabelNames          = []string{"repository", "workflow"}
+	jobFlakinessLabelNames    = []string{"repository", "workflow", "job"}
+	flakyWindow               = 7 * 24 * time.Hour
+	flakyFailedConclusions    = map[string]bool{"failure": true, "timed_out": true}
+	flakySucceededConclusions = map[string]bool{"success": true}
+)
+
+type FlakyJob struct {
+	Workflow    string   `json:"workflow"`
+	Job         string   `json:"job"`
+	SHA         string   `json:"sha"`
+	Conclusions []string `json:"conclusions"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+func (j *FlakyJob) key() string {
+	return strings.Join([]string{j.Workflow, j.Job, j.SHA}, "\n")
+}
+
+func (j *FlakyJob) flaky() bool {
+	failed := false
+	for _, conclusion := range j.Conclusions {
+		if flakyFailedConclusions[conclusion] {
+			failed = true
+		} else if failed && flakySucceededConclusions[conclusion] {
+			return true
+		}
+	}
+	return false
+}
+
+type FlakyRun struct {
+	Attempt   uint64 `json:"attempt"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// FlakyState tracks the attempts of the runs updated in the flaky window by
+// run ID, so that a re-run of a run below the watermark is still counted.
+type FlakyState struct {
+	Watermark uint64              `json:"watermark"`
+	Runs      map[string]FlakyRun `json:"runs"`
+	Reruns    map[string]uint64   `json:"reruns"`
+	Jobs      []*FlakyJob         `json:"jobs"`
+}
+
+// since returns the ID from which runs are fetched, which is the oldest
+// tracked run so that its re-runs are seen.
+func (s *FlakyState) since() uint64 {
+	since := s.Watermark
+	for id := range s.Runs {
+		if n, err := strconv.ParseUint(id, 10, 64); err == nil && n < since {
+			since = n
+		}
+	}
+	return since
+}
+
+func (s *FlakyState) record(run WorkflowRun, jobs []WorkflowJob) {
+	index := make(map[string]*FlakyJob)
+	for _, job := range s.Jobs {
+		index[job.key()] = job
+	}
+	for _, job := range jobs {
+		if job.Status != "completed" {
+			continue
+		}
+		j := &FlakyJob{
+			Workflow: run.Name,
+			Job:      job.Name,
+			SHA:      run.HeadSHA,
+		}
+		if existing, ok := index[j.key()]; ok {
+			j = existing
+		} else {
+			index[j.key()] = j
+			s.Jobs = append(s.Jobs, j)
+		}
+		j.Conclusions = append(j.Conclusions, job.Conclusion)
+		j.UpdatedAt = run.UpdatedAt
+	}
+}
+
+func (s *FlakyState) prune(now time.Time) {
+	for id, run := range s.Runs {
+		updatedAt, err := time.Parse(time.RFC3339, run.UpdatedAt)
+		if err != nil || now.Sub(updatedAt) > flakyWindow {
+			delete(s.Runs, id)
+		}
+	}
+
+	var jobs []*FlakyJob
+	for _, job := range s.Jobs {
+		updatedAt, err := time.Parse(time.RFC3339, job.UpdatedAt)
+		if err != nil || now.Sub(updatedAt) > flakyWindow {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	s.Jobs = jobs
+}
+
+type FlakyCollector struct {
+	repositories []string
+	backend      IBackend
+	store        IStore
+	status       *StatusRecorder
+	policy       *LabelPolicy
+	logger       ILogger
+	httpClient   IHTTPClient
+	states       map[string]*FlakyState
+	loop         loop
+	mutex        sync.RWMutex
+	reruns       *prometheus.CounterVec
+	jobFlakiness *prometheus.GaugeVec
+}
+
+func NewFlakyCollector(
+	repositories []string,
+	backend IBackend,
+	store IStore,
+	status *StatusRecorder,
+	policy *LabelPolicy,
+	logger ILogger,
+	httpClient IHTTPClient,
+) *FlakyCollector {
+	return &FlakyCollector{
+		repositories: repositories,
+		backend:      backend,
+		store:        store,
+		status:       status,
+		policy:       policy,
+		logger:       logger,
+		httpClient:   httpClient,
+		states:       make(map[string]*FlakyState),
+		reruns:       newRerunsCounterVec(),
+		jobFlakiness: newJobFlakinessGaugeVec(),
+	}
+}
+
+func newRerunsCounterVec() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "workflow_reruns_total",
+		Help:      "Total number of re-run attempts of workflow runs",
+	}, rerunsLabelNames)
+}
+
+func newJobFlakinessGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "job_flakiness_ratio",
+		Help:      "Ratio of commits on which a job failed and then passed to commits on which it ran in the last 7 days",
+	}, jobFlakinessLabelNames)
+}
+
+func (c *FlakyCollector) stateKey(repository string) string {
+	return fmt.Sprintf("flaky/%s", repository)
+}
+
+func (c *FlakyCollector) loadState(repository string) (*FlakyState, error) {
+	c.mutex.RLock()
+	state, ok := c.states[repository]
+	c.mutex.RUnlock()
+	if ok {
+		return state, nil
+	}
+	state = &FlakyState{}
+	b, err := c.store.Get(c.stateKey(repository))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get state: %w", err)
+	}
+	if b != nil {
+		if err := json.Unmarshal(b, state); err != nil {
+			return nil, xerrors.Errorf("failed to parse state: %w", err)
+		}
+	}
+	c.mutex.Lock()
+	c.states[repository] = state
+	c.mutex.Unlock()
+	return state, nil
+}
+
+func (c *FlakyCollector) saveState(repository string, state *FlakyState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return xerrors.Errorf("failed to marshal state: %w", err)
+	}
+	if err := c.store.Put(c.stateKey(repository), b); err != nil {
+		return xerrors.Errorf("failed to put state: %w", err)
+	}
+	return nil
+}
+
+func (c *FlakyCollector) fetchAttemptJobs(ctx context.Context, repository string, runID uint64, attempt uint64, page int) ([]WorkflowJob, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/runs/%d/attempts/%d/jobs?per_page=%d&page=%d", repository, runID, attempt, jobsPerPage, page), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, "/repos/{repository}/actions/runs/{run_id}/attempts/{attempt_number}/jobs")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var jobsResponse WorkflowJobsResponse
+	if err := json.Unmarshal(body, &jobsResponse); err != nil {
+		return nil, xerrors.Errorf("failed to parse response: %w", err)
+	}
+	if jobsResponse.TotalCount == nil {
+		return nil, xerrors.Errorf("bad response: %s", string(body))
+	}
+
+	if *jobsResponse.TotalCount > jobsPerPage*page {
+		jobs, err := c.fetchAttemptJobs(ctx, repository, runID, attempt, page+1)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to execute fetchAttemptJobs: %w", err)
+		}
+		jobsResponse.Jobs = append(jobsResponse.Jobs, jobs...)
+	}
+
+	return jobsResponse.Jobs, nil
+}
+
+func (c *FlakyCollector) Scrape(ctx context.Context) error {
+	ctx = withCollector(ctx, "flaky")
+	ctx, span := trace.StartSpan(ctx, "FlakyCollector.Scrape")
+	defer span.End()
+	span.AddAttributes(trace.Int64Attribute("github.repositories", int64(len(c.repositories))))
+
+	var failed []string
+	var repositories []string
+	states := make(map[string]*FlakyState)
+	since := make(map[string]uint64)
+	for _, repository := range c.repositories {
+		state, err := c.loadState(repository)
+		if err != nil {
+			c.logger.Errorw("Failed to load state",
+				"collector", "flaky",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+			continue
+		}
+		repositories = append(repositories, repository)
+		states[repository] = state
+		since[repository] = state.since()
+	}
+	runs, errs := c.backend.FetchRuns(ctx, repositories, since)
+
+	filter := c.policy.NewFilter()
+	reruns := newRerunsCounterVec()
+	jobFlakiness := newJobFlakinessGaugeVec()
+	for _, repository := range repositories {
+		if err, ok := errs[repository]; ok {
+			c.logger.Errorw("Failed to fetch runs",
+				"collector", "flaky",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+			continue
+		}
+		if err := c.scrapeRepositoryFlaky(ctx, repository, states[repository], runs[repository], reruns, jobFlakiness, filter); err != nil {
+			c.logger.Errorw("Failed to scrape repository",
+				"collector", "flaky",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reruns = reruns
+	c.jobFlakiness = jobFlakiness
+
+	if len(failed) > 0 {
+		return newRepositoriesError("failed to scrape flaky jobs", failed)
+	}
+	return nil
+}
+
+func (c *FlakyCollector) scrapeRepositoryFlaky(
+	ctx context.Context,
+	repository string,
+	state *FlakyState,
+	runs []WorkflowRun,
+	rerunsCounterVec *prometheus.CounterVec,
+	jobFlakinessGaugeVec *prometheus.GaugeVec,
+	filter *LabelFilter,
+) error {
+	initialized := state.Watermark != 0
+	if state.Runs == nil {
+		state.Runs = make(map[string]FlakyRun)
+	}
+	if state.Reruns == nil {
+		state.Reruns = make(map[string]uint64)
+	}
+
+	watermark := state.Watermark
+	if watermark == 0 {
+		watermark = 1
+	}
+	for _, run := range runs {
+		if run.ID >= watermark {
+			watermark = run.ID + 1
+		}
+	}
+	for _, run := range runs {
+		if run.Status != "completed" {
+			if run.ID < watermark {
+				watermark = run.ID
+			}
+			continue
+		}
+		attempt := run.RunAttempt
+		if attempt == 0 {
+			attempt = 1
+		}
+		id := strconv.FormatUint(run.ID, 10)
+		tracked, ok := state.Runs[id]
+		previous := tracked.Attempt
+		if ok && attempt <= previous {
+			continue
+		}
+		state.Runs[id] = FlakyRun{
+			Attempt:   attempt,
+			UpdatedAt: run.UpdatedAt,
+		}
+		if !initialized || (!ok && run.ID < state.Watermark) {
+			continue
+		}
+		for a := previous + 1; a <= attempt; a++ {
+			if a > 1 {
+				state.Reruns[run.Name]++
+			}
+			jobs, err := c.fetchAttemptJobs(ctx, repository, run.ID, a, 1)
+			if err != nil {
+				return xerrors.Errorf("failed to fetch jobs of run %d attempt %d: %w", run.ID, a, err)
+			}
+			state.record(run, jobs)
+		}
+	}
+	state.Watermark = watermark
+	state.prune(time.Now())
+	if err := c.saveState(repository, state); err != nil {
+		return xerrors.Errorf("failed to save state: %w", err)
+	}
+
+	for workflow, count := range state.Reruns {
+		labels, ok := filter.Apply("github_actions_workflow_reruns_total", rerunsLabelNames,
+			repository,
+			workflow,
+		)
+		if ok {
+			rerunsCounterVec.WithLabelValues(labels...).Add(float64(count))
+		}
+	}
+	type counts struct {
+		flaky int
+		total int
+	}
+	m := make(map[[2]string]*counts)
+	for _, job := range state.Jobs {
+		k := [2]string{job.Workflow, job.Job}
+		if m[k] == nil {
+			m[k] = &counts{}
+		}
+		m[k].total++
+		if job.flaky() {
+			m[k].flaky++
+		}
+	}
+	for k, v := range m {
+		labels, ok := filter.Apply("github_actions_job_flakiness_ratio", jobFlakinessLabelNames,
+			repository,
+			k[0],
+			k[1],
+		)
+		if ok {
+			jobFlakinessGaugeVec.WithLabelValues(labels...).Set(float64(v.flaky) / float64(v.total))
+		}
+	}
+	return nil
+}
+
+func (c *FlakyCollector) scrape(ctx context.Context) {
+	err := c.Scrape(ctx)
+	c.status.Record("flaky", err)
+	if err != nil {
+		c.logger.Errorw("Failed to scrape",
+			"collector", "flaky",
+			"error", err.Error(),
+		)
+	}
+}
+
+func (c *FlakyCollector) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.states = make(map[string]*FlakyState)
+}
+
+func (c *FlakyCollector) StartLoop(ctx context.Context, interval time.Duration) {
+	c.status.Start("flaky", interval)
+	c.loop.start(ctx, interval, c.reset, c.scrape)
+}
+
+func (c *FlakyCollector) collectors() []prometheus.Collector {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return []prometheus.Collector{
+		c.reruns,
+		c.jobFlakiness,
+	}
+}
+
+func (c *FlakyCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+func (c *FlakyCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
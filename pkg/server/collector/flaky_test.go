@@ -0,0 +1,180 @@
+package collector_test
+
+import (
+	"context"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFlakyCollectorScrape(t *testing.T) {
+	updatedAt := time.Now().UTC().Format(time.RFC3339)
+	tests := []struct {
+		name      string
+		runs      [][]collector.WorkflowRun
+		wantSince []uint64
+		jobs      map[string]string
+		want      string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[][]collector.WorkflowRun{
+				{
+					{ID: 1, Name: "CI", HeadSHA: "a", Status: "completed", Conclusion: "failure", RunAttempt: 1, UpdatedAt: updatedAt},
+				},
+				{
+					{ID: 2, Name: "CI", HeadSHA: "b", Status: "completed", Conclusion: "failure", RunAttempt: 1, UpdatedAt: updatedAt},
+					{ID: 1, Name: "CI", HeadSHA: "a", Status: "completed", Conclusion: "failure", RunAttempt: 1, UpdatedAt: updatedAt},
+				},
+				{
+					{ID: 3, Name: "CI", HeadSHA: "c", Status: "in_progress", RunAttempt: 1, UpdatedAt: updatedAt},
+					{ID: 2, Name: "CI", HeadSHA: "b", Status: "completed", Conclusion: "success", RunAttempt: 3, UpdatedAt: updatedAt},
+					{ID: 1, Name: "CI", HeadSHA: "a", Status: "completed", Conclusion: "failure", RunAttempt: 1, UpdatedAt: updatedAt},
+				},
+			},
+			[]uint64{0, 1, 1},
+			map[string]string{
+				"/repos/fake/fake/actions/runs/2/attempts/1/jobs": `{"total_count": 2, "jobs": [
+  {"id": 1, "name": "build", "status": "completed", "conclusion": "failure"},
+  {"id": 2, "name": "test", "status": "completed", "conclusion": "success"}
+]}`,
+				"/repos/fake/fake/actions/runs/2/attempts/2/jobs": `{"total_count": 1, "jobs": [
+  {"id": 3, "name": "build", "status": "completed", "conclusion": "failure"}
+]}`,
+				"/repos/fake/fake/actions/runs/2/attempts/3/jobs": `{"total_count": 1, "jobs": [
+  {"id": 4, "name": "build", "status": "completed", "conclusion": "success"}
+]}`,
+			},
+			`
+# HELP github_actions_job_flakiness_ratio Ratio of commits on which a job failed and then passed to commits on which it ran in the last 7 days
+# TYPE github_actions_job_flakiness_ratio gauge
+github_actions_job_flakiness_ratio{job="build",repository="fake/fake",workflow="CI"} 1
+github_actions_job_flakiness_ratio{job="test",repository="fake/fake",workflow="CI"} 0
+# HELP github_actions_workflow_reruns_total Total number of re-run attempts of workflow runs
+# TYPE github_actions_workflow_reruns_total counter
+github_actions_workflow_reruns_total{repository="fake/fake",workflow="CI"} 2
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[][]collector.WorkflowRun{
+				{
+					{ID: 1, Name: "CI", HeadSHA: "a", Status: "completed", Conclusion: "success", RunAttempt: 2, UpdatedAt: updatedAt},
+				},
+				{
+					{ID: 1, Name: "CI", HeadSHA: "a", Status: "completed", Conclusion: "success", RunAttempt: 2, UpdatedAt: updatedAt},
+				},
+			},
+			[]uint64{0, 1},
+			map[string]string{},
+			``,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			// Run 1 is out of the second fetch and re-run below the watermark.
+			[][]collector.WorkflowRun{
+				{
+					{ID: 1, Name: "CI", HeadSHA: "a", Status: "completed", Conclusion: "failure", RunAttempt: 1, UpdatedAt: updatedAt},
+				},
+				{
+					{ID: 2, Name: "CI", HeadSHA: "b", Status: "completed", Conclusion: "success", RunAttempt: 1, UpdatedAt: updatedAt},
+				},
+				{
+					{ID: 2, Name: "CI", HeadSHA: "b", Status: "completed", Conclusion: "success", RunAttempt: 1, UpdatedAt: updatedAt},
+					{ID: 1, Name: "CI", HeadSHA: "a", Status: "completed", Conclusion: "success", RunAttempt: 2, UpdatedAt: updatedAt},
+				},
+			},
+			[]uint64{0, 1, 1},
+			map[string]string{
+				"/repos/fake/fake/actions/runs/2/attempts/1/jobs": `{"total_count": 1, "jobs": [
+  {"id": 1, "name": "build", "status": "completed", "conclusion": "success"}
+]}`,
+				"/repos/fake/fake/actions/runs/1/attempts/2/jobs": `{"total_count": 1, "jobs": [
+  {"id": 2, "name": "build", "status": "completed", "conclusion": "success"}
+]}`,
+			},
+			`
+# HELP github_actions_job_flakiness_ratio Ratio of commits on which a job failed and then passed to commits on which it ran in the last 7 days
+# TYPE github_actions_job_flakiness_ratio gauge
+github_actions_job_flakiness_ratio{job="build",repository="fake/fake",workflow="CI"} 0
+# HELP github_actions_workflow_reruns_total Total number of re-run attempts of workflow runs
+# TYPE github_actions_workflow_reruns_total counter
+github_actions_workflow_reruns_total{repository="fake/fake",workflow="CI"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		runs := tt.runs
+		wantSince := tt.wantSince
+		jobs := tt.jobs
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			receiver := collector.NewFlakyCollector(
+				[]string{"fake/fake"},
+				&backendMock{
+					fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+						i := calls
+						calls++
+						if since["fake/fake"] != wantSince[i] {
+							t.Errorf("want since %d, got %d", wantSince[i], since["fake/fake"])
+						}
+						return map[string][]collector.WorkflowRun{
+							"fake/fake": runs[i],
+						}, nil
+					},
+				},
+				&storeMock{
+					m: make(map[string][]byte),
+				},
+				nil,
+				nil,
+				loggerMock{
+					fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+						t.Errorf("%s: %v", msg, keysAndValues)
+					},
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				httpClientMock{
+					fakeDo: func(request *http.Request) (*http.Response, error) {
+						body, ok := jobs[request.URL.Path]
+						if !ok {
+							t.Errorf("unexpected request: %s", request.URL.Path)
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(body)),
+						}, nil
+					},
+				},
+			)
+			for range runs {
+				if err := receiver.Scrape(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := testutil.CollectAndCompare(receiver, strings.NewReader(want)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
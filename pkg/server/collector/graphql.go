@@ -0,0 +1,381 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	graphQLEndpoint = "https://api.github.com/graphql"
+)
+
+var (
+	graphQLBatchSize       = 20
+	graphQLCommitsPerQuery = 10
+	graphQLSuitesPerCommit = 20
+)
+
+const graphQLRepositoryFragment = `
+fragment repositoryFields on Repository {
+  nameWithOwner  defaultBranchRef {
+    name
+    target {
+      ... on Commit {
+        history(first: %d) {
+          nodes {
+            checkSuites(first: %d) {
+              nodes {                status
+                conclusion
+                commit {
+                  oid
+                }
+                workflowRun {
+                  databaseId
+                  event
+                  createdAt
+                  updatedAt
+                  workflow {
+                    id                    databaseId
+                    name
+                  }
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+type GraphQLRequest struct {
+	Query     string            `json:"query"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+type GraphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+type GraphQLWorkflow struct {
+	ID         string `json:"id"`
+	DatabaseID uint64 `json:"databaseId"`
+	Name       string `json:"name"`
+}
+
+type GraphQLCheckSuite struct {
+	Status     string  `json:"status"`
+	Conclusion *string `json:"conclusion"`
+	Commit     struct {
+		OID string `json:"oid"`
+	} `json:"commit"`
+	WorkflowRun *struct {
+		DatabaseID uint64           `json:"databaseId"`
+		Event      string           `json:"event"`
+		CreatedAt  string           `json:"createdAt"`
+		UpdatedAt  string           `json:"updatedAt"`
+		Workflow   *GraphQLWorkflow `json:"workflow"`
+	} `json:"workflowRun"`
+}
+
+type GraphQLRepository struct {
+	NameWithOwner    string `json:"nameWithOwner"`
+	DefaultBranchRef *struct {
+		Name   string `json:"name"`
+		Target struct {
+			History struct {
+				Nodes []struct {
+					CheckSuites struct {
+						Nodes []GraphQLCheckSuite `json:"nodes"`
+					} `json:"checkSuites"`
+				} `json:"nodes"`
+			} `json:"history"`
+		} `json:"target"`
+	} `json:"defaultBranchRef"`
+}
+
+func (r *GraphQLRepository) checkSuites() []GraphQLCheckSuite {
+	if r.DefaultBranchRef == nil {
+		return nil
+	}
+	var suites []GraphQLCheckSuite
+	for _, commit := range r.DefaultBranchRef.Target.History.Nodes {
+		for _, suite := range commit.CheckSuites.Nodes {
+			if suite.WorkflowRun == nil {
+				continue
+			}
+			suites = append(suites, suite)
+		}
+	}
+	return suites
+}
+
+type GraphQLRateLimit struct {
+	Cost      int    `json:"cost"`
+	Remaining int    `json:"remaining"`
+	ResetAt   string `json:"resetAt"`
+}
+
+type graphQLCacheEntry struct {
+	repository *GraphQLRepository
+	fetchedAt  time.Time
+}
+
+type GraphQLBackend struct {
+	cacheTTL   time.Duration
+	logger     ILogger
+	httpClient IHTTPClient
+	rest       *RESTBackend
+	mutex      sync.Mutex
+	cache      map[string]graphQLCacheEntry
+}
+
+func NewGraphQLBackend(
+	cacheTTL time.Duration,
+	logger ILogger,
+	httpClient IHTTPClient,
+) *GraphQLBackend {
+	return &GraphQLBackend{
+		cacheTTL:   cacheTTL,
+		logger:     logger,
+		httpClient: httpClient,
+		rest:       NewRESTBackend(cacheTTL, logger, httpClient),
+		cache:      make(map[string]graphQLCacheEntry),
+	}
+}
+
+func (b *GraphQLBackend) buildQuery(repositories []string) (*GraphQLRequest, error) {
+	var declarations []string
+	var selections []string
+	variables := make(map[string]string)
+	for i, repository := range repositories {
+		s := strings.SplitN(repository, "/", 2)
+		if len(s) != 2 || s[0] == "" || s[1] == "" {
+			return nil, xerrors.Errorf("invalid repository name: %s", repository)
+		}
+		declarations = append(declarations, fmt.Sprintf("$o%d: String!, $n%d: String!", i, i))
+		selections = append(selections, fmt.Sprintf("  r%d: repository(owner: $o%d, name: $n%d) { ...repositoryFields }", i, i, i))
+		variables[fmt.Sprintf("o%d", i)] = s[0]
+		variables[fmt.Sprintf("n%d", i)] = s[1]
+	}
+
+	query := fmt.Sprintf(
+		"query(%s) {\n  rateLimit { cost remaining resetAt }\n%s\n}\n%s",
+		strings.Join(declarations, ", "),
+		strings.Join(selections, "\n"),
+		fmt.Sprintf(graphQLRepositoryFragment, graphQLCommitsPerQuery, graphQLSuitesPerCommit),
+	)
+	return &GraphQLRequest{
+		Query:     query,
+		Variables: variables,
+	}, nil
+}
+
+func (b *GraphQLBackend) query(ctx context.Context, repositories []string) (map[string]*GraphQLRepository, map[string]error) {
+	result, errs, err := b.doQuery(ctx, repositories)
+	if err != nil {
+		errs = make(map[string]error)
+		for _, repository := range repositories {
+			errs[repository] = err
+		}
+		return nil, errs
+	}
+	return result, errs
+}
+
+func (b *GraphQLBackend) doQuery(ctx context.Context, repositories []string) (map[string]*GraphQLRepository, map[string]error, error) {
+	graphQLRequest, err := b.buildQuery(repositories)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to build query: %w", err)
+	}
+	requestBody, err := json.Marshal(graphQLRequest)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to marshal query: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", graphQLEndpoint, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := doRequest(ctx, b.httpClient, b.logger, request, strings.Join(repositories, ","), "/graphql")
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var graphQLResponse struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []GraphQLError             `json:"errors,omitempty"`
+	}
+	if err := json.Unmarshal(body, &graphQLResponse); err != nil {
+		return nil, nil, xerrors.Errorf("failed to parse response: %w", err)
+	}
+
+	aliases := make(map[string]string)
+	for i, repository := range repositories {
+		aliases[fmt.Sprintf("r%d", i)] = repository
+	}
+	messages := make(map[string][]string)
+	var batchMessages []string
+	for _, e := range graphQLResponse.Errors {
+		if len(e.Path) > 0 {
+			if alias, ok := e.Path[0].(string); ok && aliases[alias] != "" {
+				messages[aliases[alias]] = append(messages[aliases[alias]], e.Message)
+				continue
+			}
+		}
+		batchMessages = append(batchMessages, e.Message)
+	}
+	if len(batchMessages) > 0 {
+		return nil, nil, xerrors.Errorf("bad response: %s", strings.Join(batchMessages, ", "))
+	}
+	if graphQLResponse.Data == nil {
+		return nil, nil, xerrors.Errorf("bad response: %s", string(body))
+	}
+
+	if raw, ok := graphQLResponse.Data["rateLimit"]; ok {
+		var rateLimit GraphQLRateLimit
+		if err := json.Unmarshal(raw, &rateLimit); err == nil {
+			b.logger.Debugw("GraphQL rate limit",
+				"collector", collectorFromContext(ctx),
+				"cost", rateLimit.Cost,
+				"remaining", rateLimit.Remaining,
+				"reset_at", rateLimit.ResetAt,
+			)
+		}
+	}
+
+	result := make(map[string]*GraphQLRepository)
+	errs := make(map[string]error)
+	for i, repository := range repositories {
+		if m, ok := messages[repository]; ok {
+			errs[repository] = xerrors.Errorf("bad response: %s", strings.Join(m, ", "))
+			continue
+		}
+		raw, ok := graphQLResponse.Data[fmt.Sprintf("r%d", i)]
+		if !ok {
+			errs[repository] = xerrors.Errorf("bad response: %s is missing", repository)
+			continue
+		}
+		var graphQLRepository *GraphQLRepository
+		if err := json.Unmarshal(raw, &graphQLRepository); err != nil {
+			errs[repository] = xerrors.Errorf("failed to parse response: %w", err)
+			continue
+		}
+		if graphQLRepository == nil {
+			errs[repository] = xerrors.Errorf("bad response: %s is not found", repository)
+			continue
+		}
+		result[repository] = graphQLRepository
+	}
+	return result, errs, nil
+}
+
+func (b *GraphQLBackend) queryAll(ctx context.Context, repositories []string) (map[string]*GraphQLRepository, map[string]error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	result := make(map[string]*GraphQLRepository)
+	errs := make(map[string]error)
+	var stale []string
+	for _, repository := range repositories {
+		if entry, ok := b.cache[repository]; ok && now.Sub(entry.fetchedAt) < b.cacheTTL {
+			result[repository] = entry.repository
+			continue
+		}
+		stale = append(stale, repository)
+	}
+	for i := 0; i < len(stale); i += graphQLBatchSize {
+		end := i + graphQLBatchSize
+		if end > len(stale) {
+			end = len(stale)
+		}
+		batch, batchErrs := b.query(ctx, stale[i:end])
+		for repository, graphQLRepository := range batch {
+			result[repository] = graphQLRepository
+			b.cache[repository] = graphQLCacheEntry{
+				repository: graphQLRepository,
+				fetchedAt:  now,
+			}
+		}
+		for repository, err := range batchErrs {
+			errs[repository] = xerrors.Errorf("failed to execute query: %w", err)
+		}
+	}
+	return result, errs
+}
+
+func (b *GraphQLBackend) FetchRunsCounts(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+	graphQLRepositories, errs := b.queryAll(ctx, repositories)
+
+	counts := make(map[string]map[string]int)
+	for repository, graphQLRepository := range graphQLRepositories {
+		counts[repository] = make(map[string]int)
+		for _, status := range statuses {
+			counts[repository][status] = 0
+		}
+		for _, suite := range graphQLRepository.checkSuites() {
+			status := strings.ToLower(suite.Status)
+			if _, ok := counts[repository][status]; ok {
+				counts[repository][status]++
+			}
+		}
+	}
+	return counts, wrapErrors(errs, "failed to execute queryAll")
+}
+
+func (b *GraphQLBackend) FetchRuns(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]WorkflowRun, map[string]error) {
+	graphQLRepositories, errs := b.queryAll(ctx, repositories)
+
+	runs := make(map[string][]WorkflowRun)
+	for repository, graphQLRepository := range graphQLRepositories {
+		runs[repository] = []WorkflowRun{}
+		for _, suite := range graphQLRepository.checkSuites() {
+			if suite.WorkflowRun.DatabaseID < since[repository] {
+				continue
+			}
+			run := WorkflowRun{
+				ID:         suite.WorkflowRun.DatabaseID,
+				HeadBranch: graphQLRepository.DefaultBranchRef.Name,
+				HeadSHA:    suite.Commit.OID,
+				Event:      suite.WorkflowRun.Event,
+				Status:     strings.ToLower(suite.Status),
+				CreatedAt:  suite.WorkflowRun.CreatedAt,
+				UpdatedAt:  suite.WorkflowRun.UpdatedAt,
+			}
+			if suite.Conclusion != nil {
+				run.Conclusion = strings.ToLower(*suite.Conclusion)
+			}
+			if suite.WorkflowRun.Workflow != nil {
+				run.WorkflowID = suite.WorkflowRun.Workflow.DatabaseID
+				run.Name = suite.WorkflowRun.Workflow.Name
+			}
+			runs[repository] = append(runs[repository], run)
+		}
+	}
+	return runs, wrapErrors(errs, "failed to execute queryAll")
+}
+
+// FetchWorkflows fetches workflows through the REST API, since v4 has no list of
+// workflows and those derived from check suites would miss disabled or idle ones.
+func (b *GraphQLBackend) FetchWorkflows(ctx context.Context, repositories []string) (map[string][]Workflow, map[string]error) {
+	return b.rest.FetchWorkflows(ctx, repositories)
+}
@@ -0,0 +1,297 @@
+package collector_test
+
+import (
+	"context"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const fakeGraphQLResponse = `{
+  "data": {
+    "rateLimit": {"cost": 1, "remaining": 4999, "resetAt": "2020-01-01T00:00:00Z"},
+    "r0": {
+      "nameWithOwner": "fake/fake1",
+      "defaultBranchRef": {
+        "name": "main",
+        "target": {
+          "history": {
+            "nodes": [
+              {
+                "checkSuites": {
+                  "nodes": [
+                    {"status": "COMPLETED", "conclusion": "SUCCESS", "commit": {"oid": "sha1"}, "workflowRun": {"databaseId": 1, "event": "push", "createdAt": "2020-01-01T00:00:00Z", "updatedAt": "2020-01-01T00:01:00Z", "workflow": {"id": "W_1", "databaseId": 10, "name": "CI"}}},
+                    {"status": "COMPLETED", "conclusion": null, "workflowRun": null}
+                  ]
+                }
+              },
+              {
+                "checkSuites": {
+                  "nodes": [
+                    {"status": "IN_PROGRESS", "conclusion": null, "commit": {"oid": "sha2"}, "workflowRun": {"databaseId": 2, "event": "push", "createdAt": "2020-01-01T00:02:00Z", "updatedAt": "2020-01-01T00:02:00Z", "workflow": {"id": "W_1", "databaseId": 10, "name": "CI"}}},
+                    {"status": "QUEUED", "conclusion": null, "commit": {"oid": "sha2"}, "workflowRun": {"databaseId": 3, "event": "workflow_dispatch", "createdAt": "2020-01-01T00:03:00Z", "updatedAt": "2020-01-01T00:03:00Z", "workflow": {"id": "W_2", "databaseId": 20, "name": "Release"}}}
+                  ]
+                }
+              }
+            ]
+          }
+        }
+      }
+    },
+    "r1": {
+      "nameWithOwner": "fake/fake2",
+      "defaultBranchRef": null
+    }
+  }
+}`
+
+func newFakeGraphQLBackend(t *testing.T, response string) *collector.GraphQLBackend {
+	return newCountingFakeGraphQLBackend(t, response, new(int32))
+}
+
+func newCountingFakeGraphQLBackend(t *testing.T, response string, requests *int32) *collector.GraphQLBackend {
+	return collector.NewGraphQLBackend(
+		time.Minute,
+		loggerMock{
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				atomic.AddInt32(requests, 1)
+				body, err := ioutil.ReadAll(request.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, s := range []string{`"o0":"fake"`, `"n0":"fake1"`, `"o1":"fake"`, `"n1":"fake2"`} {
+					if !strings.Contains(string(body), s) {
+						t.Errorf("request body does not contain %s: %s", s, string(body))
+					}
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(response)),
+				}, nil
+			},
+		},
+	)
+}
+
+func TestGraphQLBackendFetchRunsCounts(t *testing.T) {
+	tests := []struct {
+		name             string
+		receiver         *collector.GraphQLBackend
+		in               []string
+		want             map[string]map[string]int
+		wantErrorStrings map[string]string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			newFakeGraphQLBackend(t, fakeGraphQLResponse),
+			[]string{"fake/fake1", "fake/fake2"},
+			map[string]map[string]int{
+				"fake/fake1": {
+					"queued":      1,
+					"in_progress": 1,
+					"completed":   1,
+					"waiting":     0,
+					"requested":   0,
+					"pending":     0,
+				},
+				"fake/fake2": {
+					"queued":      0,
+					"in_progress": 0,
+					"completed":   0,
+					"waiting":     0,
+					"requested":   0,
+					"pending":     0,
+				},
+			},
+			map[string]string{},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			newFakeGraphQLBackend(t, `{"data": {"r0": null, "r1": null}, "errors": [{"message": "fake"}]}`),
+			[]string{"fake/fake1", "fake/fake2"},
+			map[string]map[string]int{},
+			map[string]string{
+				"fake/fake1": "failed to execute queryAll: failed to execute query: bad response: fake",
+				"fake/fake2": "failed to execute queryAll: failed to execute query: bad response: fake",
+			},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			newFakeGraphQLBackend(t, `{"data": {"r0": {"nameWithOwner": "fake/fake1", "defaultBranchRef": null}, "r1": null}, "errors": [{"message": "fake", "path": ["r1"]}]}`),
+			[]string{"fake/fake1", "fake/fake2"},
+			map[string]map[string]int{
+				"fake/fake1": {
+					"queued":      0,
+					"in_progress": 0,
+					"completed":   0,
+					"waiting":     0,
+					"requested":   0,
+					"pending":     0,
+				},
+			},
+			map[string]string{
+				"fake/fake2": "failed to execute queryAll: failed to execute query: bad response: fake",
+			},
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		receiver := tt.receiver
+		in := tt.in
+		want := tt.want
+		wantErrorStrings := tt.wantErrorStrings
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, errs := receiver.FetchRunsCounts(context.Background(), in)
+			gotErrorStrings := make(map[string]string)
+			for repository, err := range errs {
+				gotErrorStrings[repository] = err.Error()
+			}
+			if diff := cmp.Diff(wantErrorStrings, gotErrorStrings); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGraphQLBackendFetchRuns(t *testing.T) {
+	tests := []struct {
+		name     string
+		receiver *collector.GraphQLBackend
+		in       []string
+		since    map[string]uint64
+		want     map[string][]collector.WorkflowRun
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			newFakeGraphQLBackend(t, fakeGraphQLResponse),
+			[]string{"fake/fake1", "fake/fake2"},
+			map[string]uint64{"fake/fake1": 2},
+			map[string][]collector.WorkflowRun{
+				"fake/fake1": {
+					{
+						ID:         2,
+						Name:       "CI",
+						WorkflowID: 10,
+						HeadBranch: "main",
+						HeadSHA:    "sha2",
+						Event:      "push",
+						Status:     "in_progress",
+						CreatedAt:  "2020-01-01T00:02:00Z",
+						UpdatedAt:  "2020-01-01T00:02:00Z",
+					},
+					{
+						ID:         3,
+						Name:       "Release",
+						WorkflowID: 20,
+						HeadBranch: "main",
+						HeadSHA:    "sha2",
+						Event:      "workflow_dispatch",
+						Status:     "queued",
+						CreatedAt:  "2020-01-01T00:03:00Z",
+						UpdatedAt:  "2020-01-01T00:03:00Z",
+					},
+				},
+				"fake/fake2": {},
+			},
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		receiver := tt.receiver
+		in := tt.in
+		since := tt.since
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, errs := receiver.FetchRuns(context.Background(), in, since)
+			for repository, err := range errs {
+				t.Errorf("%s: %v", repository, err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGraphQLBackendFetchWorkflows(t *testing.T) {
+	receiver := collector.NewGraphQLBackend(
+		time.Minute,
+		loggerMock{
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				if request.URL.Path != "/repos/fake/fake1/actions/workflows" {
+					t.Errorf("unexpected request: %s", request.URL)
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(`{"total_count": 1, "workflows": [{"id": 20, "name": "Release", "path": ".github/workflows/release.yml", "state": "disabled_manually"}]}`)),
+				}, nil
+			},
+		},
+	)
+	got, errs := receiver.FetchWorkflows(context.Background(), []string{"fake/fake1"})
+	for repository, err := range errs {
+		t.Errorf("%s: %v", repository, err)
+	}
+	want := map[string][]collector.Workflow{
+		"fake/fake1": {
+			{
+				ID:    20,
+				Name:  "Release",
+				Path:  ".github/workflows/release.yml",
+				State: "disabled_manually",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func TestGraphQLBackendQueryOncePerCycle(t *testing.T) {
+	var requests int32
+	receiver := newCountingFakeGraphQLBackend(t, fakeGraphQLResponse, &requests)
+	repositories := []string{"fake/fake1", "fake/fake2"}
+
+	if _, errs := receiver.FetchRunsCounts(context.Background(), repositories); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if _, errs := receiver.FetchRuns(context.Background(), repositories, nil); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("want 1 request, got %d", got)
+	}
+}
@@ -8,8 +8,16 @@ type ILogger interface {
 	Errorf(format string, v ...interface{})
 	Infof(format string, v ...interface{})
 	Debugf(format string, v ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Debugw(msg string, keysAndValues ...interface{})
 }
 
 type IHTTPClient interface {
 	Do(*http.Request) (*http.Response, error)
 }
+
+type IStore interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+}
@@ -12,6 +12,9 @@ type loggerMock struct {
 	fakeErrorf func(format string, v ...interface{})
 	fakeInfof  func(format string, v ...interface{})
 	fakeDebugf func(format string, v ...interface{})
+	fakeErrorw func(msg string, keysAndValues ...interface{})
+	fakeInfow  func(msg string, keysAndValues ...interface{})
+	fakeDebugw func(msg string, keysAndValues ...interface{})
 }
 
 func (l loggerMock) Errorf(format string, v ...interface{}) {
@@ -26,6 +29,18 @@ func (l loggerMock) Debugf(format string, v ...interface{}) {
 	l.fakeDebugf(format, v...)
 }
 
+func (l loggerMock) Errorw(msg string, keysAndValues ...interface{}) {
+	l.fakeErrorw(msg, keysAndValues...)
+}
+
+func (l loggerMock) Infow(msg string, keysAndValues ...interface{}) {
+	l.fakeInfow(msg, keysAndValues...)
+}
+
+func (l loggerMock) Debugw(msg string, keysAndValues ...interface{}) {
+	l.fakeDebugw(msg, keysAndValues...)
+}
+
 func getRecursiveStructReflectValue(rv reflect.Value) []reflect.Value {
 	var values []reflect.Value
 	switch rv.Kind() {
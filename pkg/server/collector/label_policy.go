@@ -0,0 +1,194 @@
+package collector
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	otherLabelValue = "other"
+)
+
+type LabelRule struct {
+	Action      string `yaml:"action"`
+	Metric      string `yaml:"metric"`
+	Label       string `yaml:"label"`
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+}
+
+type LabelPolicyConfig struct {
+	MaxSeriesPerMetric int         `yaml:"max_series_per_metric"`
+	Rules              []LabelRule `yaml:"rules"`
+}
+
+type labelRule struct {
+	action      string
+	metric      *regexp.Regexp
+	label       string
+	regex       *regexp.Regexp
+	replacement string
+}
+
+type LabelPolicy struct {
+	maxSeriesPerMetric int
+	rules              []labelRule
+	droppedSeries      *prometheus.CounterVec
+}
+
+func LoadLabelPolicy(path string) (*LabelPolicy, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", path, err)
+	}
+	var config LabelPolicyConfig
+	if err := yaml.UnmarshalStrict(b, &config); err != nil {
+		return nil, xerrors.Errorf("failed to parse %s: %w", path, err)
+	}
+	policy, err := NewLabelPolicy(config)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid label policy %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+func NewLabelPolicy(config LabelPolicyConfig) (*LabelPolicy, error) {
+	p := &LabelPolicy{
+		maxSeriesPerMetric: config.MaxSeriesPerMetric,
+		droppedSeries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "github_actions",
+			Name:      "dropped_series_total",
+			Help:      "Number of series dropped by label policy",
+		}, []string{"metric", "reason"}),
+	}
+	for i, rule := range config.Rules {
+		compiled := labelRule{
+			action:      rule.Action,
+			label:       rule.Label,
+			replacement: rule.Replacement,
+		}
+		switch rule.Action {
+		case "drop", "keep", "replace":
+		default:
+			return nil, xerrors.Errorf("unknown action of rules[%d]: %s", i, rule.Action)
+		}
+		if rule.Label == "" {
+			return nil, xerrors.Errorf("label of rules[%d] is required", i)
+		}
+		regex := rule.Regex
+		if regex == "" {
+			regex = "(.*)"
+		}
+		re, err := compileAnchored(regex)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid regex of rules[%d]: %w", i, err)
+		}
+		compiled.regex = re
+		if rule.Metric != "" {
+			re, err := compileAnchored(rule.Metric)
+			if err != nil {
+				return nil, xerrors.Errorf("invalid metric of rules[%d]: %w", i, err)
+			}
+			compiled.metric = re
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+func compileAnchored(s string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + s + ")$")
+}
+
+func (p *LabelPolicy) Describe(ch chan<- *prometheus.Desc) {
+	if p == nil {
+		return
+	}
+	p.droppedSeries.Describe(ch)
+}
+
+func (p *LabelPolicy) Collect(ch chan<- prometheus.Metric) {
+	if p == nil {
+		return
+	}
+	p.droppedSeries.Collect(ch)
+}
+
+func (p *LabelPolicy) NewFilter() *LabelFilter {
+	return &LabelFilter{
+		policy: p,
+		series: make(map[string]map[string]struct{}),
+	}
+}
+
+type LabelFilter struct {
+	policy *LabelPolicy
+	mutex  sync.Mutex
+	series map[string]map[string]struct{}
+}
+
+func (f *LabelFilter) Apply(metric string, labelNames []string, values ...string) ([]string, bool) {
+	p := f.policy
+	if p == nil {
+		return values, true
+	}
+	values = append([]string{}, values...)
+
+	for _, rule := range p.rules {
+		if rule.metric != nil && !rule.metric.MatchString(metric) {
+			continue
+		}
+		index := -1
+		for i, name := range labelNames {
+			if name == rule.label {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			continue
+		}
+		matched := rule.regex.FindStringSubmatchIndex(values[index])
+		switch rule.action {
+		case "drop":
+			if matched != nil {
+				p.droppedSeries.WithLabelValues(metric, "rule").Inc()
+				return nil, false
+			}
+		case "keep":
+			if matched == nil {
+				p.droppedSeries.WithLabelValues(metric, "rule").Inc()
+				return nil, false
+			}
+		case "replace":
+			if matched != nil {
+				values[index] = string(rule.regex.ExpandString(nil, rule.replacement, values[index], matched))
+			}
+		}
+	}
+
+	if p.maxSeriesPerMetric > 0 {
+		key := strings.Join(values, "\xff")
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		series, ok := f.series[metric]
+		if !ok {
+			series = make(map[string]struct{})
+			f.series[metric] = series
+		}
+		if _, ok := series[key]; !ok {
+			if len(series) >= p.maxSeriesPerMetric {
+				p.droppedSeries.WithLabelValues(metric, "limit").Inc()
+				return nil, false
+			}
+			series[key] = struct{}{}
+		}
+	}
+	return values, true
+}
@@ -0,0 +1,117 @@
+package collector_test
+
+import (
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLabelFilterApply(t *testing.T) {
+	type series struct {
+		Values []string
+		OK     bool
+	}
+
+	tests := []struct {
+		name        string
+		config      collector.LabelPolicyConfig
+		labelNames  []string
+		in          [][]string
+		want        []series
+		wantDropped string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			collector.LabelPolicyConfig{
+				Rules: []collector.LabelRule{
+					{Action: "drop", Label: "repository", Regex: "fake/ignored"},
+					{Action: "keep", Metric: "github_actions_fake", Label: "status", Regex: "queued|completed"},
+					{Action: "replace", Label: "repository", Regex: "fake/(.*)", Replacement: "$1"},
+				},
+			},
+			[]string{"repository", "status"},
+			[][]string{
+				{"fake/ignored", "queued"},
+				{"fake/fake", "in_progress"},
+				{"fake/fake", "queued"},
+				{"other/fake", "completed"},
+			},
+			[]series{
+				{nil, false},
+				{nil, false},
+				{[]string{"fake", "queued"}, true},
+				{[]string{"other/fake", "completed"}, true},
+			},
+			`
+# HELP github_actions_dropped_series_total Number of series dropped by label policy
+# TYPE github_actions_dropped_series_total counter
+github_actions_dropped_series_total{metric="github_actions_fake",reason="rule"} 2
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			collector.LabelPolicyConfig{
+				MaxSeriesPerMetric: 2,
+			},
+			[]string{"repository", "status"},
+			[][]string{
+				{"fake/fake", "queued"},
+				{"fake/fake", "completed"},
+				{"fake/fake", "queued"},
+				{"fake/fake", "in_progress"},
+			},
+			[]series{
+				{[]string{"fake/fake", "queued"}, true},
+				{[]string{"fake/fake", "completed"}, true},
+				{[]string{"fake/fake", "queued"}, true},
+				{nil, false},
+			},
+			`
+# HELP github_actions_dropped_series_total Number of series dropped by label policy
+# TYPE github_actions_dropped_series_total counter
+github_actions_dropped_series_total{metric="github_actions_fake",reason="limit"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		config := tt.config
+		labelNames := tt.labelNames
+		in := tt.in
+		want := tt.want
+		wantDropped := tt.wantDropped
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policy, err := collector.NewLabelPolicy(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter := policy.NewFilter()
+			var got []series
+			for _, values := range in {
+				values, ok := filter.Apply("github_actions_fake", labelNames, values...)
+				got = append(got, series{values, ok})
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+			if wantDropped != "" {
+				if err := testutil.CollectAndCompare(policy, strings.NewReader(wantDropped)); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// loop runs a scrape periodically until its context is done. Starting it
+// again, e.g. when the replica becomes the leader again, waits for the
+// previous loop to exit before resetting the state, so that two loops never
+// scrape at the same time.
+type loop struct {
+	mutex sync.Mutex
+	done  chan struct{}
+}
+
+func (l *loop) start(ctx context.Context, interval time.Duration, reset func(), scrape func(context.Context)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.done != nil {
+		<-l.done
+	}
+	if reset != nil {
+		reset()
+	}
+	done := make(chan struct{})
+	l.done = done
+	go func(ctx context.Context) {
+		defer close(done)
+		scrape(ctx)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				scrape(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(ctx)
+}
@@ -0,0 +1,208 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type PendingDeployment struct {
+	Environment struct {
+		ID   uint64 `json:"id"`
+		Name string `json:"name"`
+	} `json:"environment"`
+	WaitTimer             int64  `json:"wait_timer"`
+	WaitTimerStartedAt    string `json:"wait_timer_started_at"`
+	CurrentUserCanApprove bool   `json:"current_user_can_approve"`
+}
+
+// pendingEnvironment summarizes runs waiting for approval of deployment to an
+// environment.
+type pendingEnvironment struct {
+	count  int
+	oldest time.Time
+}
+
+func newPendingDeploymentsGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_deployments",
+		Help:      "Number of workflow runs waiting for approval of deployment to each environment",
+	}, pendingDeploymentsLabelNames)
+}
+
+func newPendingDeploymentAgeGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_deployment_oldest_age_seconds",
+		Help:      "Age of the oldest workflow run waiting for approval of deployment to each environment",
+	}, pendingDeploymentsLabelNames)
+}
+
+func (c *RunsCollector) fetchWaitingRuns(ctx context.Context, repository string, page int) ([]WorkflowRun, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/runs?status=waiting&per_page=%d&page=%d", repository, runsPerPage, page), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, "/repos/{repository}/actions/runs")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var workflowRunsResponse WorkflowRunsResponse
+	if err := json.Unmarshal(body, &workflowRunsResponse); err != nil {
+		return nil, xerrors.Errorf("failed to parse response: %w", err)
+	}
+	if workflowRunsResponse.TotalCount == nil {
+		return nil, xerrors.Errorf("bad response: %s", string(body))
+	}
+
+	runs := workflowRunsResponse.WorkflowRuns
+	if len(runs) == runsPerPage && page < runsMaxPage {
+		next, err := c.fetchWaitingRuns(ctx, repository, page+1)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to execute fetchWaitingRuns: %w", err)
+		}
+		runs = append(runs, next...)
+	}
+	return runs, nil
+}
+
+func (c *RunsCollector) fetchPendingDeployments(ctx context.Context, repository string, runID uint64) ([]PendingDeployment, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/runs/%d/pending_deployments", repository, runID), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, "/repos/{repository}/actions/runs/{run_id}/pending_deployments")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var pendingDeployments []PendingDeployment
+	if err := json.Unmarshal(body, &pendingDeployments); err != nil {
+		return nil, xerrors.Errorf("failed to parse response: %s", string(body))
+	}
+	return pendingDeployments, nil
+}
+
+func (c *RunsCollector) fetchRepositoryPendingDeployments(ctx context.Context, repository string) (map[string]*pendingEnvironment, error) {
+	runs, err := c.fetchWaitingRuns(ctx, repository, 1)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to fetch waiting runs: %w", err)
+	}
+	environments := make(map[string]*pendingEnvironment)
+	for _, run := range runs {
+		pendingDeployments, err := c.fetchPendingDeployments(ctx, repository, run.ID)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to fetch pending deployments of run %d: %w", run.ID, err)
+		}
+		for _, pendingDeployment := range pendingDeployments {
+			environment, ok := environments[pendingDeployment.Environment.Name]
+			if !ok {
+				environment = &pendingEnvironment{}
+				environments[pendingDeployment.Environment.Name] = environment
+			}
+			environment.count++
+			waitingSince, err := time.Parse(time.RFC3339, pendingDeployment.WaitTimerStartedAt)
+			if err != nil {
+				waitingSince, err = time.Parse(time.RFC3339, run.UpdatedAt)
+				if err != nil {
+					continue
+				}
+			}
+			if environment.oldest.IsZero() || waitingSince.Before(environment.oldest) {
+				environment.oldest = waitingSince
+			}
+		}
+	}
+	return environments, nil
+}
+
+func (c *RunsCollector) scrapeRepositoryPendingDeployments(
+	repository string,
+	environments map[string]*pendingEnvironment,
+	pendingDeploymentsGaugeVec *prometheus.GaugeVec,
+	pendingDeploymentAgeGaugeVec *prometheus.GaugeVec,
+	filter *LabelFilter,
+	now time.Time,
+) {
+	for name, environment := range environments {
+		labels, ok := filter.Apply("github_actions_pending_deployments", pendingDeploymentsLabelNames,
+			repository,
+			name,
+		)
+		if ok {
+			pendingDeploymentsGaugeVec.WithLabelValues(labels...).Add(float64(environment.count))
+		}
+		if environment.oldest.IsZero() {
+			continue
+		}
+		labels, ok = filter.Apply("github_actions_pending_deployment_oldest_age_seconds", pendingDeploymentsLabelNames,
+			repository,
+			name,
+		)
+		if ok {
+			pendingDeploymentAgeGaugeVec.WithLabelValues(labels...).Set(now.Sub(environment.oldest).Seconds())
+		}
+	}
+}
+
+func (c *RunsCollector) scrapePendingDeployments(
+	ctx context.Context,
+	counts map[string]map[string]int,
+	previous map[string]map[string]*pendingEnvironment,
+	filter *LabelFilter,
+) (map[string]map[string]*pendingEnvironment, *prometheus.GaugeVec, *prometheus.GaugeVec, error) {
+	var failed []string
+	now := time.Now()
+	last := make(map[string]map[string]*pendingEnvironment)
+	pendingDeployments := newPendingDeploymentsGaugeVec()
+	pendingDeploymentAge := newPendingDeploymentAgeGaugeVec()
+	for repository, m := range counts {
+		if m["waiting"] == 0 {
+			continue
+		}
+		environments, err := c.fetchRepositoryPendingDeployments(ctx, repository)
+		if err != nil {
+			c.logger.Errorw("Failed to scrape repository pending deployments",
+				"collector", "runs",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+			// Keep the series of the repository with the last fetched pending
+			// deployments instead of dropping them until the next successful
+			// fetch.
+			p, ok := previous[repository]
+			if !ok {
+				continue
+			}
+			environments = p
+		}
+		last[repository] = environments
+		c.scrapeRepositoryPendingDeployments(repository, environments, pendingDeployments, pendingDeploymentAge, filter, now)
+	}
+	if len(failed) > 0 {
+		return last, pendingDeployments, pendingDeploymentAge, newRepositoriesError("failed to scrape pending deployments", failed)
+	}
+	return last, pendingDeployments, pendingDeploymentAge, nil
+}
@@ -0,0 +1,239 @@
+package collector_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/xerrors"
+)
+
+func TestRunsCollectorScrapePendingDeployments(t *testing.T) {
+	tests := []struct {
+		name      string
+		counts    map[string]map[string]int
+		responses map[string]string
+		want      string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			map[string]map[string]int{
+				"fake/fake1": {"waiting": 2},
+				"fake/fake2": {"waiting": 0},
+			},
+			map[string]string{
+				"/repos/fake/fake1/actions/runs": `{"total_count": 2, "workflow_runs": [
+  {"id": 1, "status": "waiting", "updated_at": "2020-01-01T00:00:00Z"},
+  {"id": 2, "status": "waiting", "updated_at": "2020-01-01T00:00:00Z"}
+]}`,
+				"/repos/fake/fake1/actions/runs/1/pending_deployments": `[
+  {"environment": {"id": 1, "name": "production"}, "wait_timer_started_at": "2020-01-01T00:00:00Z"},
+  {"environment": {"id": 2, "name": "staging"}, "wait_timer_started_at": "2020-01-01T00:00:00Z"}
+]`,
+				"/repos/fake/fake1/actions/runs/2/pending_deployments": `[
+  {"environment": {"id": 1, "name": "production"}}
+]`,
+			},
+			`
+# HELP github_actions_pending_deployments Number of workflow runs waiting for approval of deployment to each environment
+# TYPE github_actions_pending_deployments gauge
+github_actions_pending_deployments{environment="production",repository="fake/fake1"} 2
+github_actions_pending_deployments{environment="staging",repository="fake/fake1"} 1
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			map[string]map[string]int{
+				"fake/fake1": {"queued": 1},
+			},
+			map[string]string{},
+			``,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		counts := tt.counts
+		responses := tt.responses
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver := collector.NewRunsCollector(
+				[]string{"fake/fake1", "fake/fake2"},
+				&backendMock{
+					fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+						return map[string][]collector.WorkflowRun{}, nil
+					},
+					fakeFetchRunsCounts: func(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+						return counts, nil
+					},
+				},
+				&storeMock{
+					m: make(map[string][]byte),
+				},
+				nil,
+				nil,
+				0,
+				loggerMock{
+					fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+						t.Errorf("%s: %v", msg, keysAndValues)
+					},
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				httpClientMock{
+					fakeDo: func(request *http.Request) (*http.Response, error) {
+						body, ok := responses[request.URL.Path]
+						if !ok {
+							t.Errorf("unexpected request: %s", request.URL.Path)
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(body)),
+						}, nil
+					},
+				},
+			)
+			if err := receiver.Scrape(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := testutil.CollectAndCompare(receiver, strings.NewReader(want), "github_actions_pending_deployments"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRunsCollectorScrapePendingDeploymentsPages(t *testing.T) {
+	var runs []string
+	for id := 1; id <= 100; id++ {
+		runs = append(runs, fmt.Sprintf(`{"id": %d, "status": "waiting"}`, id))
+	}
+	pages := map[string]string{
+		"1": fmt.Sprintf(`{"total_count": 101, "workflow_runs": [%s]}`, strings.Join(runs, ",")),
+		"2": `{"total_count": 101, "workflow_runs": [{"id": 101, "status": "waiting"}]}`,
+	}
+	receiver := collector.NewRunsCollector(
+		[]string{"fake/fake"},
+		&backendMock{
+			fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+				return map[string][]collector.WorkflowRun{}, nil
+			},
+			fakeFetchRunsCounts: func(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+				return map[string]map[string]int{"fake/fake": {"waiting": 101}}, nil
+			},
+		},
+		&storeMock{
+			m: make(map[string][]byte),
+		},
+		nil,
+		nil,
+		0,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+				t.Errorf("%s: %v", msg, keysAndValues)
+			},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				body := `[{"environment": {"id": 1, "name": "production"}}]`
+				if request.URL.Path == "/repos/fake/fake/actions/runs" {
+					var ok bool
+					body, ok = pages[request.URL.Query().Get("page")]
+					if !ok {
+						t.Errorf("unexpected page: %s", request.URL.RawQuery)
+					}
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		},
+	)
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+# HELP github_actions_pending_deployments Number of workflow runs waiting for approval of deployment to each environment
+# TYPE github_actions_pending_deployments gauge
+github_actions_pending_deployments{environment="production",repository="fake/fake"} 101
+`
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want), "github_actions_pending_deployments"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunsCollectorScrapePendingDeploymentsKeepsFailedSeries(t *testing.T) {
+	failing := false
+	receiver := collector.NewRunsCollector(
+		[]string{"fake/fake"},
+		&backendMock{
+			fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+				return map[string][]collector.WorkflowRun{}, nil
+			},
+			fakeFetchRunsCounts: func(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+				return map[string]map[string]int{"fake/fake": {"waiting": 1}}, nil
+			},
+		},
+		&storeMock{
+			m: make(map[string][]byte),
+		},
+		nil,
+		nil,
+		0,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				if failing && request.URL.Path == "/repos/fake/fake/actions/runs/1/pending_deployments" {
+					return nil, errors.New("fake")
+				}
+				body := `[{"environment": {"id": 1, "name": "production"}}]`
+				if request.URL.Path == "/repos/fake/fake/actions/runs" {
+					body = `{"total_count": 1, "workflow_runs": [{"id": 1, "status": "waiting"}]}`
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		},
+	)
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	failing = true
+	err := receiver.Scrape(context.Background())
+	var repositoriesErr *collector.RepositoriesError
+	if !xerrors.As(err, &repositoriesErr) {
+		t.Fatalf("want RepositoriesError, but got %v", err)
+	}
+	if diff := cmp.Diff([]string{"fake/fake"}, repositoriesErr.Repositories); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+	want := `
+# HELP github_actions_pending_deployments Number of workflow runs waiting for approval of deployment to each environment
+# TYPE github_actions_pending_deployments gauge
+github_actions_pending_deployments{environment="production",repository="fake/fake"} 1
+`
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want), "github_actions_pending_deployments"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,512 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+	"golang.org/x/xerrors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	pullRequestCIDurationLabelNames = []string{"repository", "conclusion"}
+	pullRequestsLabelNames          = []string{"repository", "checks"}
+)
+
+var (
+	pullRequestsPerPage    = 100
+	pullRequestsMaxPage    = 10
+	pullRequestCheckMaxAge = 7 * 24 * time.Hour
+	// pullRequestCheckSettleTime is how long checks of a head commit must stay
+	// completed before they are observed, since workflows can start after
+	// the others completed.
+	pullRequestCheckSettleTime = 5 * time.Minute
+	pullRequestEvents          = map[string]bool{
+		"pull_request":        true,
+		"pull_request_target": true,
+	}
+	failedConclusions = map[string]bool{
+		"failure":         true,
+		"timed_out":       true,
+		"cancelled":       true,
+		"startup_failure": true,
+		"action_required": true,
+	}
+	passedConclusions = map[string]bool{
+		"success": true,
+		"neutral": true,
+		"skipped": true,
+		"stale":   true,
+	}
+	pullRequestCIDurationBuckets = []float64{30, 60, 120, 300, 600, 900, 1200, 1800, 2700, 3600, 7200, 14400}
+)
+
+type PullRequest struct {
+	Number uint64 `json:"number"`
+	Head   struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+type PullRequestCheckRun struct {
+	RunID  uint64 `json:"run_id"`
+	Status string `json:"status"`
+}
+
+// PullRequestCheck holds the latest run of each workflow of a head commit of a pull request.
+type PullRequestCheck struct {
+	PushedAt    string                         `json:"pushed_at"`
+	CompletedAt string                         `json:"completed_at"`
+	Workflows   map[string]PullRequestCheckRun `json:"workflows"`
+	Observed    bool                           `json:"observed"`
+}
+
+func (c *PullRequestCheck) checks() string {
+	pending := false
+	for _, run := range c.Workflows {
+		status := run.Status
+		if failedConclusions[status] {
+			return "failing"
+		}
+		if !passedConclusions[status] {
+			pending = true
+		}
+	}
+	if pending {
+		return "pending"
+	}
+	return "passing"
+}
+
+func (c *PullRequestCheck) settled(now time.Time) bool {
+	if len(c.Workflows) == 0 || c.checks() == "pending" {
+		return false
+	}
+	completedAt, err := time.Parse(time.RFC3339, c.CompletedAt)
+	if err != nil {
+		return true
+	}
+	return now.Sub(completedAt) >= pullRequestCheckSettleTime
+}
+
+func (c *PullRequestCheck) duration() (time.Duration, bool) {
+	pushedAt, err := time.Parse(time.RFC3339, c.PushedAt)
+	if err != nil {
+		return 0, false
+	}
+	completedAt, err := time.Parse(time.RFC3339, c.CompletedAt)
+	if err != nil {
+		return 0, false
+	}
+	return completedAt.Sub(pushedAt), true
+}
+
+func pullRequestCheckKey(number uint64, sha string) string {
+	return fmt.Sprintf("%d/%s", number, sha)
+}
+
+// runPullRequests returns the numbers of the pull requests of the run. The
+// open pull requests of its head SHA are used when the run has none, which is
+// the case for pull requests from forks.
+func runPullRequests(run WorkflowRun, open map[string][]uint64) []uint64 {
+	if len(run.PullRequests) == 0 {
+		return open[run.HeadSHA]
+	}
+	numbers := make([]uint64, 0, len(run.PullRequests))
+	for _, pullRequest := range run.PullRequests {
+		numbers = append(numbers, pullRequest.Number)
+	}
+	return numbers
+}
+
+type PullRequestsState struct {
+	Watermark uint64                       `json:"watermark"`
+	Checks    map[string]*PullRequestCheck `json:"checks"`
+}
+
+// update records runs of required workflows, which are all workflows if
+// required is nil, by pull request number and head SHA, and returns checks
+// which have settled since the last update. open maps head SHAs to the
+// numbers of the open pull requests.
+func (s *PullRequestsState) update(runs []WorkflowRun, open map[string][]uint64, required *regexp.Regexp, now time.Time) []*PullRequestCheck {
+	initialized := s.Watermark != 0
+	if s.Checks == nil {
+		s.Checks = make(map[string]*PullRequestCheck)
+	}
+
+	watermark := s.Watermark
+	if watermark == 0 {
+		watermark = 1
+	}
+	for _, run := range runs {
+		if run.ID >= watermark {
+			watermark = run.ID + 1
+		}
+	}
+	for _, run := range runs {
+		if run.ID < s.Watermark || !pullRequestEvents[run.Event] || run.HeadSHA == "" {
+			continue
+		}
+		if required != nil && !required.MatchString(run.Name) {
+			continue
+		}
+		for _, number := range runPullRequests(run, open) {
+			key := pullRequestCheckKey(number, run.HeadSHA)
+			check, ok := s.Checks[key]
+			if !ok {
+				check = &PullRequestCheck{
+					Workflows: make(map[string]PullRequestCheckRun),
+				}
+				s.Checks[key] = check
+			}
+			if check.Workflows == nil {
+				check.Workflows = make(map[string]PullRequestCheckRun)
+			}
+			workflow := strconv.FormatUint(run.WorkflowID, 10)
+			if latest, ok := check.Workflows[workflow]; ok && latest.RunID > run.ID {
+				continue
+			}
+			status := run.Status
+			if run.Status == "completed" {
+				status = run.Conclusion
+				if run.UpdatedAt > check.CompletedAt {
+					check.CompletedAt = run.UpdatedAt
+				}
+			} else if run.ID < watermark {
+				watermark = run.ID
+			}
+			check.Workflows[workflow] = PullRequestCheckRun{
+				RunID:  run.ID,
+				Status: status,
+			}
+			if check.PushedAt == "" || run.CreatedAt < check.PushedAt {
+				check.PushedAt = run.CreatedAt
+			}
+		}
+	}
+	s.Watermark = watermark
+
+	var completed []*PullRequestCheck
+	for _, check := range s.Checks {
+		if check.Observed || !check.settled(now) {
+			continue
+		}
+		check.Observed = true
+		if initialized {
+			completed = append(completed, check)
+		}
+	}
+	return completed
+}
+
+func (s *PullRequestsState) prune(heads map[string]bool, now time.Time) {
+	for key, check := range s.Checks {
+		if heads[key] {
+			continue
+		}
+		pushedAt, err := time.Parse(time.RFC3339, check.PushedAt)
+		if check.Observed || err != nil || now.Sub(pushedAt) > pullRequestCheckMaxAge {
+			delete(s.Checks, key)
+		}
+	}
+}
+
+type PullRequestsCollector struct {
+	repositories      []string
+	backend           IBackend
+	store             IStore
+	status            *StatusRecorder
+	policy            *LabelPolicy
+	requiredWorkflows *regexp.Regexp
+	logger            ILogger
+	httpClient        IHTTPClient
+	states            map[string]*PullRequestsState
+	loop              loop
+	mutex             sync.RWMutex
+	ciDuration        *prometheus.HistogramVec
+	pullRequests      *prometheus.GaugeVec
+}
+
+func NewPullRequestsCollector(
+	repositories []string,
+	backend IBackend,
+	store IStore,
+	status *StatusRecorder,
+	policy *LabelPolicy,
+	requiredWorkflows *regexp.Regexp,
+	logger ILogger,
+	httpClient IHTTPClient,
+) *PullRequestsCollector {
+	return &PullRequestsCollector{
+		repositories:      repositories,
+		backend:           backend,
+		store:             store,
+		status:            status,
+		policy:            policy,
+		requiredWorkflows: requiredWorkflows,
+		logger:            logger,
+		httpClient:        httpClient,
+		states:            make(map[string]*PullRequestsState),
+		ciDuration:        newPullRequestCIDurationHistogramVec(),
+		pullRequests:      newPullRequestsGaugeVec(),
+	}
+}
+
+func newPullRequestCIDurationHistogramVec() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pull_request_ci_duration_seconds",
+		Help:      "Time from push to pull request until the runs of all of its required workflows completed",
+		Buckets:   pullRequestCIDurationBuckets,
+	}, pullRequestCIDurationLabelNames)
+}
+
+func newPullRequestsGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "open_pull_requests",
+		Help:      "Number of open pull requests by state of workflow runs of their head commit",
+	}, pullRequestsLabelNames)
+}
+
+func (c *PullRequestsCollector) stateKey(repository string) string {
+	return fmt.Sprintf("pull_requests/%s", repository)
+}
+
+func (c *PullRequestsCollector) loadState(repository string) (*PullRequestsState, error) {
+	c.mutex.RLock()
+	state, ok := c.states[repository]
+	c.mutex.RUnlock()
+	if ok {
+		return state, nil
+	}
+	state = &PullRequestsState{}
+	b, err := c.store.Get(c.stateKey(repository))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get state: %w", err)
+	}
+	if b != nil {
+		if err := json.Unmarshal(b, state); err != nil {
+			return nil, xerrors.Errorf("failed to parse state: %w", err)
+		}
+	}
+	c.mutex.Lock()
+	c.states[repository] = state
+	c.mutex.Unlock()
+	return state, nil
+}
+
+func (c *PullRequestsCollector) saveState(repository string, state *PullRequestsState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return xerrors.Errorf("failed to marshal state: %w", err)
+	}
+	if err := c.store.Put(c.stateKey(repository), b); err != nil {
+		return xerrors.Errorf("failed to put state: %w", err)
+	}
+	return nil
+}
+
+func (c *PullRequestsCollector) fetchPullRequests(ctx context.Context, repository string, page int) ([]PullRequest, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/pulls?state=open&per_page=%d&page=%d", repository, pullRequestsPerPage, page), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, "/repos/{repository}/pulls")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var pullRequests []PullRequest
+	if err := json.Unmarshal(body, &pullRequests); err != nil {
+		return nil, xerrors.Errorf("failed to parse response: %s", string(body))
+	}
+
+	if len(pullRequests) == pullRequestsPerPage && page < pullRequestsMaxPage {
+		next, err := c.fetchPullRequests(ctx, repository, page+1)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to execute fetchPullRequests: %w", err)
+		}
+		pullRequests = append(pullRequests, next...)
+	}
+
+	return pullRequests, nil
+}
+
+func (c *PullRequestsCollector) Scrape(ctx context.Context) error {
+	ctx = withCollector(ctx, "pull_requests")
+	ctx, span := trace.StartSpan(ctx, "PullRequestsCollector.Scrape")
+	defer span.End()
+	span.AddAttributes(trace.Int64Attribute("github.repositories", int64(len(c.repositories))))
+
+	var failed []string
+	var repositories []string
+	states := make(map[string]*PullRequestsState)
+	since := make(map[string]uint64)
+	for _, repository := range c.repositories {
+		state, err := c.loadState(repository)
+		if err != nil {
+			c.logger.Errorw("Failed to load state",
+				"collector", "pull_requests",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+			continue
+		}
+		repositories = append(repositories, repository)
+		states[repository] = state
+		since[repository] = state.Watermark
+	}
+	runs, errs := c.backend.FetchRuns(ctx, repositories, since)
+
+	filter := c.policy.NewFilter()
+	pullRequests := newPullRequestsGaugeVec()
+	for _, repository := range repositories {
+		if err, ok := errs[repository]; ok {
+			c.logger.Errorw("Failed to fetch runs",
+				"collector", "pull_requests",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+			continue
+		}
+		if err := c.scrapeRepositoryPullRequests(ctx, repository, states[repository], runs[repository], pullRequests, filter); err != nil {
+			c.logger.Errorw("Failed to scrape repository",
+				"collector", "pull_requests",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.pullRequests = pullRequests
+
+	if len(failed) > 0 {
+		return newRepositoriesError("failed to scrape pull requests", failed)
+	}
+	return nil
+}
+
+func (c *PullRequestsCollector) scrapeRepositoryPullRequests(
+	ctx context.Context,
+	repository string,
+	state *PullRequestsState,
+	runs []WorkflowRun,
+	pullRequestsGaugeVec *prometheus.GaugeVec,
+	filter *LabelFilter,
+) error {
+	pullRequests, err := c.fetchPullRequests(ctx, repository, 1)
+	if err != nil {
+		return xerrors.Errorf("failed to fetch pull requests: %w", err)
+	}
+	open := make(map[string][]uint64)
+	for _, pullRequest := range pullRequests {
+		open[pullRequest.Head.SHA] = append(open[pullRequest.Head.SHA], pullRequest.Number)
+	}
+
+	for _, check := range state.update(runs, open, c.requiredWorkflows, time.Now()) {
+		duration, ok := check.duration()
+		if !ok {
+			continue
+		}
+		conclusion := "success"
+		if check.checks() == "failing" {
+			conclusion = "failure"
+		}
+		labels, ok := filter.Apply("github_actions_pull_request_ci_duration_seconds", pullRequestCIDurationLabelNames,
+			repository,
+			conclusion,
+		)
+		if ok {
+			c.ciDuration.WithLabelValues(labels...).Observe(duration.Seconds())
+		}
+	}
+
+	heads := make(map[string]bool)
+	counts := make(map[string]int)
+	for _, pullRequest := range pullRequests {
+		key := pullRequestCheckKey(pullRequest.Number, pullRequest.Head.SHA)
+		heads[key] = true
+		if check, ok := state.Checks[key]; ok {
+			counts[check.checks()]++
+		}
+	}
+	for _, checks := range []string{"failing", "pending", "passing"} {
+		labels, ok := filter.Apply("github_actions_open_pull_requests", pullRequestsLabelNames,
+			repository,
+			checks,
+		)
+		if ok {
+			pullRequestsGaugeVec.WithLabelValues(labels...).Add(float64(counts[checks]))
+		}
+	}
+
+	state.prune(heads, time.Now())
+	if err := c.saveState(repository, state); err != nil {
+		return xerrors.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+func (c *PullRequestsCollector) scrape(ctx context.Context) {
+	err := c.Scrape(ctx)
+	c.status.Record("pull_requests", err)
+	if err != nil {
+		c.logger.Errorw("Failed to scrape",
+			"collector", "pull_requests",
+			"error", err.Error(),
+		)
+	}
+}
+
+func (c *PullRequestsCollector) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.states = make(map[string]*PullRequestsState)
+}
+
+func (c *PullRequestsCollector) StartLoop(ctx context.Context, interval time.Duration) {
+	c.status.Start("pull_requests", interval)
+	c.loop.start(ctx, interval, c.reset, c.scrape)
+}
+
+func (c *PullRequestsCollector) collectors() []prometheus.Collector {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return []prometheus.Collector{
+		c.ciDuration,
+		c.pullRequests,
+	}
+}
+
+func (c *PullRequestsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+func (c *PullRequestsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
@@ -0,0 +1,267 @@
+package collector_test
+
+import (
+	"context"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPullRequestsCollectorScrape(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name              string
+		requiredWorkflows *regexp.Regexp
+		runs              [][]collector.WorkflowRun
+		pullRequests      string
+		want              string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			nil,
+			[][]collector.WorkflowRun{
+				{
+					{ID: 1, WorkflowID: 1, Name: "CI", Event: "pull_request", HeadSHA: "a", Status: "in_progress", CreatedAt: "2020-01-01T00:00:00Z"},
+				},
+				{
+					{ID: 1, WorkflowID: 1, Name: "CI", Event: "pull_request", HeadSHA: "a", Status: "completed", Conclusion: "success", CreatedAt: "2020-01-01T00:00:00Z", UpdatedAt: "2020-01-01T00:05:00Z"},
+					{ID: 2, WorkflowID: 2, Name: "Lint", Event: "pull_request", HeadSHA: "a", Status: "completed", Conclusion: "failure", CreatedAt: "2020-01-01T00:00:10Z", UpdatedAt: "2020-01-01T00:03:00Z"},
+					{ID: 3, WorkflowID: 1, Name: "CI", Event: "push", HeadSHA: "b", Status: "in_progress", CreatedAt: "2020-01-01T00:01:00Z"},
+					{ID: 4, WorkflowID: 3, Name: "Label", Event: "pull_request_target", HeadSHA: "c", Status: "queued", CreatedAt: "2020-01-01T00:02:00Z"},
+				},
+			},
+			`[{"number": 1, "head": {"sha": "a"}}, {"number": 2, "head": {"sha": "c"}}, {"number": 3, "head": {"sha": "d"}}]`,
+			`
+# HELP github_actions_open_pull_requests Number of open pull requests by state of workflow runs of their head commit
+# TYPE github_actions_open_pull_requests gauge
+github_actions_open_pull_requests{checks="failing",repository="fake/fake"} 1
+github_actions_open_pull_requests{checks="passing",repository="fake/fake"} 0
+github_actions_open_pull_requests{checks="pending",repository="fake/fake"} 1
+# HELP github_actions_pull_request_ci_duration_seconds Time from push to pull request until the runs of all of its required workflows completed
+# TYPE github_actions_pull_request_ci_duration_seconds histogram
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="30"} 0
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="60"} 0
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="120"} 0
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="300"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="600"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="900"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="1200"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="1800"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="2700"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="3600"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="7200"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="14400"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="failure",repository="fake/fake",le="+Inf"} 1
+github_actions_pull_request_ci_duration_seconds_sum{conclusion="failure",repository="fake/fake"} 300
+github_actions_pull_request_ci_duration_seconds_count{conclusion="failure",repository="fake/fake"} 1
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			nil,
+			[][]collector.WorkflowRun{
+				{
+					{ID: 1, WorkflowID: 1, Name: "CI", Event: "pull_request", HeadSHA: "a", Status: "completed", Conclusion: "success", CreatedAt: "2020-01-01T00:00:00Z", UpdatedAt: "2020-01-01T00:05:00Z"},
+				},
+				{},
+			},
+			`[{"number": 1, "head": {"sha": "a"}}]`,
+			`
+# HELP github_actions_open_pull_requests Number of open pull requests by state of workflow runs of their head commit
+# TYPE github_actions_open_pull_requests gauge
+github_actions_open_pull_requests{checks="failing",repository="fake/fake"} 0
+github_actions_open_pull_requests{checks="passing",repository="fake/fake"} 1
+github_actions_open_pull_requests{checks="pending",repository="fake/fake"} 0
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			regexp.MustCompile("^CI$"),
+			[][]collector.WorkflowRun{
+				{},
+				{
+					{ID: 1, WorkflowID: 1, Name: "CI", Event: "pull_request", HeadSHA: "a", Status: "completed", Conclusion: "success", CreatedAt: "2020-01-01T00:00:00Z", UpdatedAt: "2020-01-01T00:05:00Z"},
+					{ID: 2, WorkflowID: 2, Name: "Lint", Event: "pull_request", HeadSHA: "a", Status: "completed", Conclusion: "failure", CreatedAt: "2020-01-01T00:00:00Z", UpdatedAt: "2020-01-01T00:10:00Z"},
+				},
+			},
+			`[{"number": 1, "head": {"sha": "a"}}]`,
+			`
+# HELP github_actions_open_pull_requests Number of open pull requests by state of workflow runs of their head commit
+# TYPE github_actions_open_pull_requests gauge
+github_actions_open_pull_requests{checks="failing",repository="fake/fake"} 0
+github_actions_open_pull_requests{checks="passing",repository="fake/fake"} 1
+github_actions_open_pull_requests{checks="pending",repository="fake/fake"} 0
+# HELP github_actions_pull_request_ci_duration_seconds Time from push to pull request until the runs of all of its required workflows completed
+# TYPE github_actions_pull_request_ci_duration_seconds histogram
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="30"} 0
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="60"} 0
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="120"} 0
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="300"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="600"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="900"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="1200"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="1800"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="2700"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="3600"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="7200"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="14400"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="+Inf"} 1
+github_actions_pull_request_ci_duration_seconds_sum{conclusion="success",repository="fake/fake"} 300
+github_actions_pull_request_ci_duration_seconds_count{conclusion="success",repository="fake/fake"} 1
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			nil,
+			[][]collector.WorkflowRun{
+				{},
+				{
+					{ID: 1, WorkflowID: 1, Name: "CI", Event: "pull_request", HeadSHA: "a", Status: "completed", Conclusion: "failure", CreatedAt: "2020-01-01T00:00:00Z", UpdatedAt: "2020-01-01T00:02:00Z"},
+					{ID: 2, WorkflowID: 1, Name: "CI", Event: "pull_request", HeadSHA: "a", Status: "completed", Conclusion: "success", CreatedAt: "2020-01-01T00:01:00Z", UpdatedAt: "2020-01-01T00:05:00Z"},
+				},
+			},
+			`[{"number": 1, "head": {"sha": "a"}}]`,
+			`
+# HELP github_actions_open_pull_requests Number of open pull requests by state of workflow runs of their head commit
+# TYPE github_actions_open_pull_requests gauge
+github_actions_open_pull_requests{checks="failing",repository="fake/fake"} 0
+github_actions_open_pull_requests{checks="passing",repository="fake/fake"} 1
+github_actions_open_pull_requests{checks="pending",repository="fake/fake"} 0
+# HELP github_actions_pull_request_ci_duration_seconds Time from push to pull request until the runs of all of its required workflows completed
+# TYPE github_actions_pull_request_ci_duration_seconds histogram
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="30"} 0
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="60"} 0
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="120"} 0
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="300"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="600"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="900"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="1200"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="1800"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="2700"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="3600"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="7200"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="14400"} 1
+github_actions_pull_request_ci_duration_seconds_bucket{conclusion="success",repository="fake/fake",le="+Inf"} 1
+github_actions_pull_request_ci_duration_seconds_sum{conclusion="success",repository="fake/fake"} 300
+github_actions_pull_request_ci_duration_seconds_count{conclusion="success",repository="fake/fake"} 1
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			nil,
+			[][]collector.WorkflowRun{
+				{},
+				{
+					{ID: 1, WorkflowID: 1, Name: "CI", Event: "pull_request", HeadSHA: "a", Status: "completed", Conclusion: "success", CreatedAt: now.Add(-2 * time.Minute).Format(time.RFC3339), UpdatedAt: now.Add(-time.Minute).Format(time.RFC3339)},
+				},
+			},
+			`[{"number": 1, "head": {"sha": "a"}}]`,
+			`
+# HELP github_actions_open_pull_requests Number of open pull requests by state of workflow runs of their head commit
+# TYPE github_actions_open_pull_requests gauge
+github_actions_open_pull_requests{checks="failing",repository="fake/fake"} 0
+github_actions_open_pull_requests{checks="passing",repository="fake/fake"} 1
+github_actions_open_pull_requests{checks="pending",repository="fake/fake"} 0
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			nil,
+			[][]collector.WorkflowRun{
+				{},
+				{
+					{ID: 1, WorkflowID: 1, Name: "CI", Event: "pull_request", HeadSHA: "a", Status: "in_progress", CreatedAt: "2020-01-01T00:00:00Z", PullRequests: []collector.WorkflowRunPullRequest{{Number: 2}}},
+				},
+			},
+			`[{"number": 1, "head": {"sha": "a"}}, {"number": 2, "head": {"sha": "a"}}]`,
+			`
+# HELP github_actions_open_pull_requests Number of open pull requests by state of workflow runs of their head commit
+# TYPE github_actions_open_pull_requests gauge
+github_actions_open_pull_requests{checks="failing",repository="fake/fake"} 0
+github_actions_open_pull_requests{checks="passing",repository="fake/fake"} 0
+github_actions_open_pull_requests{checks="pending",repository="fake/fake"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		requiredWorkflows := tt.requiredWorkflows
+		runs := tt.runs
+		pullRequests := tt.pullRequests
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			receiver := collector.NewPullRequestsCollector(
+				[]string{"fake/fake"},
+				&backendMock{
+					fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+						i := calls
+						calls++
+						return map[string][]collector.WorkflowRun{
+							"fake/fake": runs[i],
+						}, nil
+					},
+				},
+				&storeMock{
+					m: make(map[string][]byte),
+				},
+				nil,
+				nil,
+				requiredWorkflows,
+				loggerMock{
+					fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+						t.Errorf("%s: %v", msg, keysAndValues)
+					},
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				httpClientMock{
+					fakeDo: func(request *http.Request) (*http.Response, error) {
+						if request.URL.Path != "/repos/fake/fake/pulls" {
+							t.Errorf("unexpected request: %s", request.URL.Path)
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(pullRequests)),
+						}, nil
+					},
+				},
+			)
+			for range runs {
+				if err := receiver.Scrape(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := testutil.CollectAndCompare(receiver, strings.NewReader(want)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
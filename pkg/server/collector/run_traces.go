@@ -0,0 +1,361 @@
+package collector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+	"golang.org/x/xerrors"
+)
+
+var (
+	jobsPerPage = 100
+)
+
+type WorkflowStep struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Conclusion  string `json:"conclusion"`
+	Number      uint64 `json:"number"`
+	StartedAt   string `json:"started_at"`
+	CompletedAt string `json:"completed_at"`
+}
+
+type WorkflowJob struct {
+	ID              uint64         `json:"id"`
+	RunID           uint64         `json:"run_id"`
+	Name            string         `json:"name"`
+	Status          string         `json:"status"`
+	Conclusion      string         `json:"conclusion"`
+	CreatedAt       string         `json:"created_at"`
+	StartedAt       string         `json:"started_at"`
+	CompletedAt     string         `json:"completed_at"`
+	RunnerName      string         `json:"runner_name"`
+	RunnerGroupName string         `json:"runner_group_name"`
+	Labels          []string       `json:"labels"`
+	Steps           []WorkflowStep `json:"steps"`
+	HTMLURL         string         `json:"html_url"`
+}
+
+type WorkflowJobsResponse struct {
+	TotalCount *int          `json:"total_count,omitempty"`
+	Jobs       []WorkflowJob `json:"jobs,omitempty"`
+}
+
+type ISpanExporter interface {
+	ExportSpan(*trace.SpanData)
+}
+
+type RunTracesCollector struct {
+	repositories []string
+	backend      IBackend
+	store        IStore
+	exporter     ISpanExporter
+	status       *StatusRecorder
+	logger       ILogger
+	httpClient   IHTTPClient
+	states       map[string]*RunsWatermark
+	loop         loop
+	mutex        sync.RWMutex
+}
+
+func NewRunTracesCollector(
+	repositories []string,
+	backend IBackend,
+	store IStore,
+	exporter ISpanExporter,
+	status *StatusRecorder,
+	logger ILogger,
+	httpClient IHTTPClient,
+) *RunTracesCollector {
+	return &RunTracesCollector{
+		repositories: repositories,
+		backend:      backend,
+		store:        store,
+		exporter:     exporter,
+		status:       status,
+		logger:       logger,
+		httpClient:   httpClient,
+		states:       make(map[string]*RunsWatermark),
+	}
+}
+
+func (c *RunTracesCollector) stateKey(repository string) string {
+	return fmt.Sprintf("run_traces/%s", repository)
+}
+
+func (c *RunTracesCollector) loadState(repository string) (*RunsWatermark, error) {
+	c.mutex.RLock()
+	state, ok := c.states[repository]
+	c.mutex.RUnlock()
+	if ok {
+		return state, nil
+	}
+	state = &RunsWatermark{}
+	b, err := c.store.Get(c.stateKey(repository))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get state: %w", err)
+	}
+	if b != nil {
+		if err := json.Unmarshal(b, state); err != nil {
+			return nil, xerrors.Errorf("failed to parse state: %w", err)
+		}
+	}
+	c.mutex.Lock()
+	c.states[repository] = state
+	c.mutex.Unlock()
+	return state, nil
+}
+
+func (c *RunTracesCollector) saveState(repository string, state *RunsWatermark) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return xerrors.Errorf("failed to marshal state: %w", err)
+	}
+	if err := c.store.Put(c.stateKey(repository), b); err != nil {
+		return xerrors.Errorf("failed to put state: %w", err)
+	}
+	return nil
+}
+
+func (c *RunTracesCollector) fetchJobs(ctx context.Context, repository string, runID uint64, page int) ([]WorkflowJob, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/runs/%d/jobs?per_page=%d&page=%d", repository, runID, jobsPerPage, page), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, "/repos/{repository}/actions/runs/{run_id}/jobs")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var jobsResponse WorkflowJobsResponse
+	if err := json.Unmarshal(body, &jobsResponse); err != nil {
+		return nil, xerrors.Errorf("failed to parse response: %w", err)
+	}
+	if jobsResponse.TotalCount == nil {
+		return nil, xerrors.Errorf("bad response: %s", string(body))
+	}
+
+	if *jobsResponse.TotalCount > jobsPerPage*page {
+		jobs, err := c.fetchJobs(ctx, repository, runID, page+1)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to execute fetchJobs: %w", err)
+		}
+		jobsResponse.Jobs = append(jobsResponse.Jobs, jobs...)
+	}
+
+	return jobsResponse.Jobs, nil
+}
+
+func (c *RunTracesCollector) scrapeRunTraces(ctx context.Context) error {
+	ctx = withCollector(ctx, "run_traces")
+	ctx, span := trace.StartSpan(ctx, "RunTracesCollector.scrapeRunTraces")
+	defer span.End()
+	span.AddAttributes(trace.Int64Attribute("github.repositories", int64(len(c.repositories))))
+
+	var failed []string
+	var repositories []string
+	states := make(map[string]*RunsWatermark)
+	since := make(map[string]uint64)
+	for _, repository := range c.repositories {
+		state, err := c.loadState(repository)
+		if err != nil {
+			c.logger.Errorw("Failed to load state",
+				"collector", "run_traces",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+			continue
+		}
+		repositories = append(repositories, repository)
+		states[repository] = state
+		since[repository] = state.Watermark
+	}
+
+	runs, errs := c.backend.FetchRuns(ctx, repositories, since)
+	for _, repository := range repositories {
+		if err, ok := errs[repository]; ok {
+			c.logger.Errorw("Failed to fetch runs",
+				"collector", "run_traces",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+			continue
+		}
+		state := states[repository]
+		for _, run := range state.update(runs[repository]) {
+			jobs, err := c.fetchJobs(ctx, repository, run.ID, 1)
+			if err != nil {
+				c.logger.Errorw("Failed to fetch jobs",
+					"collector", "run_traces",
+					"repository", repository,
+					"run_id", run.ID,
+					"error", err.Error(),
+				)
+				continue
+			}
+			for _, spanData := range runToSpans(repository, run, jobs) {
+				c.exporter.ExportSpan(spanData)
+			}
+		}
+		if err := c.saveState(repository, state); err != nil {
+			c.logger.Errorw("Failed to save state",
+				"collector", "run_traces",
+				"repository", repository,
+				"error", err.Error(),
+			)
+		}
+	}
+
+	if len(failed) > 0 {
+		return newRepositoriesError("failed to scrape run traces", failed)
+	}
+	return nil
+}
+
+func (c *RunTracesCollector) scrape(ctx context.Context) {
+	err := c.scrapeRunTraces(ctx)
+	c.status.Record("run_traces", err)
+	if err != nil {
+		c.logger.Errorw("Failed to scrape",
+			"collector", "run_traces",
+			"error", err.Error(),
+		)
+	}
+}
+
+func (c *RunTracesCollector) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.states = make(map[string]*RunsWatermark)
+}
+
+func (c *RunTracesCollector) StartLoop(ctx context.Context, interval time.Duration) {
+	c.status.Start("run_traces", interval)
+	c.loop.start(ctx, interval, c.reset, c.scrape)
+}
+
+func runToSpans(repository string, run WorkflowRun, jobs []WorkflowJob) []*trace.SpanData {
+	key := fmt.Sprintf("%s/%d/%d", repository, run.ID, run.RunAttempt)
+	traceID := trace.TraceID{}
+	h := sha256.Sum256([]byte(key))
+	copy(traceID[:], h[:])
+	rootSpanID := newSpanID(key)
+
+	var spans []*trace.SpanData
+	var start, end time.Time
+	for _, job := range jobs {
+		jobKey := fmt.Sprintf("%s/job/%d", key, job.ID)
+		jobSpan := newSpanData(traceID, newSpanID(jobKey), rootSpanID, job.Name, job.StartedAt, job.CompletedAt, job.Conclusion)
+		if jobSpan == nil {
+			continue
+		}
+		jobSpan.Attributes["github.job_id"] = int64(job.ID)
+		jobSpan.Attributes["github.runner_name"] = job.RunnerName
+		jobSpan.Attributes["github.runner_group_name"] = job.RunnerGroupName
+		jobSpan.Attributes["github.labels"] = strings.Join(job.Labels, ",")
+		jobSpan.Attributes["github.html_url"] = job.HTMLURL
+		if createdAt, err := time.Parse(time.RFC3339, job.CreatedAt); err == nil {
+			jobSpan.Attributes["github.queued_seconds"] = int64(jobSpan.StartTime.Sub(createdAt) / time.Second)
+		}
+		spans = append(spans, jobSpan)
+		if start.IsZero() || jobSpan.StartTime.Before(start) {
+			start = jobSpan.StartTime
+		}
+		if jobSpan.EndTime.After(end) {
+			end = jobSpan.EndTime
+		}
+
+		for _, step := range job.Steps {
+			stepSpan := newSpanData(traceID, newSpanID(fmt.Sprintf("%s/step/%d", jobKey, step.Number)), jobSpan.SpanID, step.Name, step.StartedAt, step.CompletedAt, step.Conclusion)
+			if stepSpan == nil {
+				continue
+			}
+			stepSpan.Attributes["github.step_number"] = int64(step.Number)
+			spans = append(spans, stepSpan)
+		}
+	}
+
+	runStartedAt := run.RunStartedAt
+	if runStartedAt == "" {
+		runStartedAt = run.CreatedAt
+	}
+	rootSpan := newSpanData(traceID, rootSpanID, trace.SpanID{}, run.Name, runStartedAt, run.UpdatedAt, run.Conclusion)
+	if rootSpan == nil {
+		if start.IsZero() {
+			return nil
+		}
+		rootSpan = newSpanData(traceID, rootSpanID, trace.SpanID{}, run.Name, start.Format(time.RFC3339), end.Format(time.RFC3339), run.Conclusion)
+	}
+	rootSpan.SpanKind = trace.SpanKindServer
+	rootSpan.Attributes["github.repository"] = repository
+	rootSpan.Attributes["github.run_id"] = int64(run.ID)
+	rootSpan.Attributes["github.run_number"] = int64(run.RunNumber)
+	rootSpan.Attributes["github.run_attempt"] = int64(run.RunAttempt)
+	rootSpan.Attributes["github.workflow_id"] = int64(run.WorkflowID)
+	rootSpan.Attributes["github.head_branch"] = run.HeadBranch
+	rootSpan.Attributes["github.head_sha"] = run.HeadSHA
+	rootSpan.Attributes["github.event"] = run.Event
+	rootSpan.Attributes["github.html_url"] = run.HTMLURL
+	return append([]*trace.SpanData{rootSpan}, spans...)
+}
+
+func newSpanID(key string) trace.SpanID {
+	spanID := trace.SpanID{}
+	h := sha256.Sum256([]byte("span/" + key))
+	copy(spanID[:], h[:])
+	return spanID
+}
+
+func newSpanData(traceID trace.TraceID, spanID trace.SpanID, parentSpanID trace.SpanID, name string, startedAt string, completedAt string, conclusion string) *trace.SpanData {
+	start, err := time.Parse(time.RFC3339, startedAt)
+	if err != nil {
+		return nil
+	}
+	end, err := time.Parse(time.RFC3339, completedAt)
+	if err != nil {
+		return nil
+	}
+	spanData := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID:      traceID,
+			SpanID:       spanID,
+			TraceOptions: 1,
+		},
+		ParentSpanID: parentSpanID,
+		Name:         name,
+		StartTime:    start,
+		EndTime:      end,
+		Attributes: map[string]interface{}{
+			"github.conclusion": conclusion,
+		},
+	}
+	switch conclusion {
+	case "failure", "timed_out", "startup_failure":
+		spanData.Status = trace.Status{
+			Code:    trace.StatusCodeUnknown,
+			Message: conclusion,
+		}
+	case "cancelled":
+		spanData.Status = trace.Status{
+			Code:    trace.StatusCodeCancelled,
+			Message: conclusion,
+		}
+	}
+	return spanData
+}
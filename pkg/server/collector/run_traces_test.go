@@ -0,0 +1,260 @@
+package collector_test
+
+import (
+	"context"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/trace"
+)
+
+type backendMock struct {
+	collector.IBackend
+	fakeFetchRuns       func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error)
+	fakeFetchWorkflows  func(ctx context.Context, repositories []string) (map[string][]collector.Workflow, map[string]error)
+	fakeFetchRunsCounts func(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error)
+}
+
+func (b *backendMock) FetchRuns(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+	return b.fakeFetchRuns(ctx, repositories, since)
+}
+
+func (b *backendMock) FetchRunsCounts(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+	return b.fakeFetchRunsCounts(ctx, repositories)
+}
+
+func (b *backendMock) FetchWorkflows(ctx context.Context, repositories []string) (map[string][]collector.Workflow, map[string]error) {
+	return b.fakeFetchWorkflows(ctx, repositories)
+}
+
+type storeMock struct {
+	mutex sync.Mutex
+	m     map[string][]byte
+}
+
+func (s *storeMock) Get(key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.m[key], nil
+}
+
+func (s *storeMock) Put(key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.m[key] = value
+	return nil
+}
+
+type spanExporterMock struct {
+	ch chan *trace.SpanData
+}
+
+func (e *spanExporterMock) ExportSpan(data *trace.SpanData) {
+	e.ch <- data
+}
+
+const fakeJobsResponse = `{
+  "total_count": 1,
+  "jobs": [
+    {
+      "id": 10,
+      "run_id": 1,
+      "name": "build",
+      "status": "completed",
+      "conclusion": "failure",
+      "created_at": "2020-01-01T00:00:00Z",
+      "started_at": "2020-01-01T00:00:30Z",
+      "completed_at": "2020-01-01T00:02:00Z",
+      "runner_name": "fake",
+      "steps": [
+        {"name": "checkout", "status": "completed", "conclusion": "success", "number": 1, "started_at": "2020-01-01T00:00:30Z", "completed_at": "2020-01-01T00:00:40Z"},
+        {"name": "skipped", "status": "completed", "conclusion": "skipped", "number": 2, "started_at": null, "completed_at": null}
+      ]
+    }
+  ]
+}`
+
+func TestRunTracesCollectorStartLoop(t *testing.T) {
+	type span struct {
+		Name       string
+		Parent     string
+		Start      time.Time
+		End        time.Time
+		Code       int32
+		Conclusion interface{}
+	}
+
+	tests := []struct {
+		name string
+		runs [][]collector.WorkflowRun
+		want []span
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[][]collector.WorkflowRun{
+				{
+					{ID: 1, Name: "CI", Status: "in_progress"},
+				},
+				{
+					{ID: 1, Name: "CI", Status: "completed", Conclusion: "failure", RunAttempt: 1, RunStartedAt: "2020-01-01T00:00:00Z", UpdatedAt: "2020-01-01T00:03:00Z"},
+				},
+			},
+			[]span{
+				{
+					Name:       "CI",
+					Start:      time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+					End:        time.Date(2020, 1, 1, 0, 3, 0, 0, time.UTC),
+					Code:       trace.StatusCodeUnknown,
+					Conclusion: "failure",
+				},
+				{
+					Name:       "build",
+					Parent:     "CI",
+					Start:      time.Date(2020, 1, 1, 0, 0, 30, 0, time.UTC),
+					End:        time.Date(2020, 1, 1, 0, 2, 0, 0, time.UTC),
+					Code:       trace.StatusCodeUnknown,
+					Conclusion: "failure",
+				},
+				{
+					Name:       "checkout",
+					Parent:     "build",
+					Start:      time.Date(2020, 1, 1, 0, 0, 30, 0, time.UTC),
+					End:        time.Date(2020, 1, 1, 0, 0, 40, 0, time.UTC),
+					Conclusion: "success",
+				},
+			},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[][]collector.WorkflowRun{
+				{
+					{ID: 2, Name: "CI", Status: "in_progress"},
+				},
+				{
+					{ID: 1, Name: "CI", Status: "completed", Conclusion: "success"},
+					{ID: 2, Name: "CI", Status: "completed", Conclusion: "failure", RunAttempt: 1},
+				},
+			},
+			[]span{
+				{
+					Name:       "CI",
+					Start:      time.Date(2020, 1, 1, 0, 0, 30, 0, time.UTC),
+					End:        time.Date(2020, 1, 1, 0, 2, 0, 0, time.UTC),
+					Code:       trace.StatusCodeUnknown,
+					Conclusion: "failure",
+				},
+				{
+					Name:       "build",
+					Parent:     "CI",
+					Start:      time.Date(2020, 1, 1, 0, 0, 30, 0, time.UTC),
+					End:        time.Date(2020, 1, 1, 0, 2, 0, 0, time.UTC),
+					Code:       trace.StatusCodeUnknown,
+					Conclusion: "failure",
+				},
+				{
+					Name:       "checkout",
+					Parent:     "build",
+					Start:      time.Date(2020, 1, 1, 0, 0, 30, 0, time.UTC),
+					End:        time.Date(2020, 1, 1, 0, 0, 40, 0, time.UTC),
+					Conclusion: "success",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		runs := tt.runs
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var mutex sync.Mutex
+			calls := 0
+			exporter := &spanExporterMock{
+				ch: make(chan *trace.SpanData, len(want)),
+			}
+			receiver := collector.NewRunTracesCollector(
+				[]string{"fake/fake"},
+				&backendMock{
+					fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+						mutex.Lock()
+						defer mutex.Unlock()
+						i := calls
+						if i >= len(runs) {
+							i = len(runs) - 1
+						}
+						calls++
+						return map[string][]collector.WorkflowRun{
+							"fake/fake": runs[i],
+						}, nil
+					},
+				},
+				&storeMock{
+					m: make(map[string][]byte),
+				},
+				exporter,
+				nil,
+				loggerMock{
+					fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+						t.Errorf("%s: %v", msg, keysAndValues)
+					},
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				httpClientMock{
+					fakeDo: func(request *http.Request) (*http.Response, error) {
+						if request.URL.Path != "/repos/fake/fake/actions/runs/1/jobs" && request.URL.Path != "/repos/fake/fake/actions/runs/2/jobs" {
+							t.Errorf("unexpected request: %s", request.URL.Path)
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(fakeJobsResponse)),
+						}, nil
+					},
+				},
+			)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			receiver.StartLoop(ctx, 10*time.Millisecond)
+
+			names := make(map[trace.SpanID]string)
+			var got []span
+			var parents []trace.SpanID
+			for range want {
+				select {
+				case data := <-exporter.ch:
+					names[data.SpanID] = data.Name
+					parents = append(parents, data.ParentSpanID)
+					got = append(got, span{
+						Name:       data.Name,
+						Start:      data.StartTime,
+						End:        data.EndTime,
+						Code:       data.Code,
+						Conclusion: data.Attributes["github.conclusion"],
+					})
+				case <-time.After(5 * time.Second):
+					t.Fatal("timed out waiting for spans")
+				}
+			}
+			for i, parent := range parents {
+				got[i].Parent = names[parent]
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
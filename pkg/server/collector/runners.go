@@ -6,27 +6,74 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"go.opencensus.io/trace"
 	"golang.org/x/xerrors"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+var (
+	runnersLabelNames             = []string{"repository", "status"}
+	runnerBusyTimeLabelNames      = []string{"repository", "runner"}
+	runnerLabelSetLabelNames      = []string{"repository", "labels"}
+	runnerInfoLabelNames          = []string{"repository", "name", "os"}
+	runnerLatestVersionLabelNames = []string{"repository", "os", "architecture", "version"}
+)
+
 var (
 	runnerStatuses = []string{
 		"offline",
 		"online",
 	}
-	runnersPerPage = 100
+	runnersPerPage       = 100
+	runnerVersionPattern = regexp.MustCompile(`-(\d+(?:\.\d+)*)\.(?:tar\.gz|zip)$`)
 )
 
+// Runner is a runner of the runner list API, which does not report the version
+// of the runner application.
 type Runner struct {
-	ID     uint64 `json:"id"`
-	Name   string `json:"name"`
-	OS     string `json:"os"`
-	Status string `json:"status"`
-	Busy   bool   `json:"busy"`
+	ID     uint64        `json:"id"`
+	Name   string        `json:"name"`
+	OS     string        `json:"os"`
+	Status string        `json:"status"`
+	Busy   bool          `json:"busy"`
+	Labels []RunnerLabel `json:"labels"`
+}
+
+type RunnerApplication struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	DownloadURL  string `json:"download_url"`
+	Filename     string `json:"filename"`
+}
+
+func (a RunnerApplication) version() string {
+	m := runnerVersionPattern.FindStringSubmatch(a.Filename)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+type RunnerLabel struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (r Runner) labelSet() string {
+	names := make([]string, len(r.Labels))
+	for i, label := range r.Labels {
+		names[i] = label.Name
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
 }
 
 type RunnersResponse struct {
@@ -35,39 +82,139 @@ type RunnersResponse struct {
 }
 
 type RunnersCollector struct {
-	repository string
-	token      string
-	logger     ILogger
-	httpClient IHTTPClient
-	runners    *prometheus.GaugeVec
+	repositories        []string
+	status              *StatusRecorder
+	policy              *LabelPolicy
+	logger              ILogger
+	httpClient          IHTTPClient
+	interval            time.Duration
+	sampledAt           map[string]time.Time
+	busySeconds         map[string]map[string]float64
+	labelSetBusySeconds map[string]map[string]float64
+	lastRunners         map[string][]Runner
+	loop                loop
+	mutex               sync.RWMutex
+	runners             *prometheus.GaugeVec
+	runnerBusyTime      *prometheus.CounterVec
+	labelSetBusyTime    *prometheus.CounterVec
+	utilization         *prometheus.GaugeVec
+	idleRunners         *prometheus.GaugeVec
+	runnerInfo          *prometheus.GaugeVec
+	latestVersion       *prometheus.GaugeVec
 }
 
 func NewRunnersCollector(
-	repository string,
-	token string,
+	repositories []string,
+	status *StatusRecorder,
+	policy *LabelPolicy,
 	logger ILogger,
 	httpClient IHTTPClient,
 ) *RunnersCollector {
 	return &RunnersCollector{
-		repository: repository,
-		token:      token,
-		logger:     logger,
-		httpClient: httpClient,
-		runners: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "runners",
-			Help:      "List how many workflow runners each repository actions",
-		}, []string{"repository", "status"}),
+		repositories:        repositories,
+		status:              status,
+		policy:              policy,
+		logger:              logger,
+		httpClient:          httpClient,
+		sampledAt:           make(map[string]time.Time),
+		busySeconds:         make(map[string]map[string]float64),
+		labelSetBusySeconds: make(map[string]map[string]float64),
+		lastRunners:         make(map[string][]Runner),
+		runners:             newRunnersGaugeVec(),
+		runnerBusyTime:      newRunnerBusyTimeCounterVec(),
+		labelSetBusyTime:    newLabelSetBusyTimeCounterVec(),
+		utilization:         newRunnerUtilizationGaugeVec(),
+		idleRunners:         newIdleRunnersGaugeVec(),
+		runnerInfo:          newRunnerInfoGaugeVec(),
+		latestVersion:       newRunnerLatestVersionGaugeVec(),
+	}
+}
+
+func newRunnersGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "runners",
+		Help:      "List how many workflow runners each repository actions",
+	}, runnersLabelNames)
+}
+
+func newRunnerBusyTimeCounterVec() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runner_busy_seconds_total",
+		Help:      "Total time each runner was sampled busy",
+	}, runnerBusyTimeLabelNames)
+}
+
+func newLabelSetBusyTimeCounterVec() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runner_label_set_busy_seconds_total",
+		Help:      "Total time runners of each label set were sampled busy",
+	}, runnerLabelSetLabelNames)
+}
+
+func newRunnerUtilizationGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "runner_utilization_ratio",
+		Help:      "Ratio of busy runners to online runners of each label set",
+	}, runnerLabelSetLabelNames)
+}
+
+func newIdleRunnersGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "idle_runners",
+		Help:      "Number of online runners which are not busy of each label set",
+	}, runnerLabelSetLabelNames)
+}
+
+func newRunnerInfoGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "runner_info",
+		Help:      "Information about each runner",
+	}, runnerInfoLabelNames)
+}
+
+func newRunnerLatestVersionGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "runner_latest_version_info",
+		Help:      "Latest version of runner application available for each platform",
+	}, runnerLatestVersionLabelNames)
+}
+
+func (c *RunnersCollector) fetchRunnerApplications(ctx context.Context, repository string) ([]RunnerApplication, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/runners/downloads", repository), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, "/repos/{repository}/actions/runners/downloads")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
 	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var applications []RunnerApplication
+	if err := json.Unmarshal(body, &applications); err != nil {
+		return nil, xerrors.Errorf("failed to parse response: %s", string(body))
+	}
+	return applications, nil
 }
 
-func (c *RunnersCollector) fetchRunners(page int) ([]Runner, error) {
-	request, err := http.NewRequest("GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/runners?per_page=%d&page=%d", c.repository, runnersPerPage, page), nil)
+func (c *RunnersCollector) fetchRunners(ctx context.Context, repository string, page int) ([]Runner, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/runners?per_page=%d&page=%d", repository, runnersPerPage, page), nil)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create request object: %w", err)
 	}
-	request.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
-	response, err := c.httpClient.Do(request)
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, "/repos/{repository}/actions/runners")
 	if err != nil {
 		return nil, xerrors.Errorf("failed to request: %w", err)
 	}
@@ -87,7 +234,7 @@ func (c *RunnersCollector) fetchRunners(page int) ([]Runner, error) {
 	}
 
 	if *runnersResponse.TotalCount > runnersPerPage*page {
-		runners, err := c.fetchRunners(page + 1)
+		runners, err := c.fetchRunners(ctx, repository, page+1)
 		if err != nil {
 			return nil, xerrors.Errorf("failed to execute fetchRunners: %w", err)
 		}
@@ -97,44 +244,265 @@ func (c *RunnersCollector) fetchRunners(page int) ([]Runner, error) {
 	return runnersResponse.Runners, nil
 }
 
-func (c *RunnersCollector) scrapeRunners() {
-	runners, err := c.fetchRunners(1)
+func (c *RunnersCollector) Scrape(ctx context.Context) error {
+	ctx = withCollector(ctx, "runners")
+	ctx, span := trace.StartSpan(ctx, "RunnersCollector.Scrape")
+	defer span.End()
+	span.AddAttributes(trace.Int64Attribute("github.repositories", int64(len(c.repositories))))
+
+	var failed []string
+	filter := c.policy.NewFilter()
+	runners := newRunnersGaugeVec()
+	utilizations := make(map[string]*runnerUtilization)
+	idleRunners := newIdleRunnersGaugeVec()
+	runnerInfo := newRunnerInfoGaugeVec()
+	latestVersion := newRunnerLatestVersionGaugeVec()
+	c.mutex.RLock()
+	previousRunners := c.lastRunners
+	c.mutex.RUnlock()
+	lastRunners := make(map[string][]Runner)
+	for _, repository := range c.repositories {
+		r, err := c.fetchRunners(ctx, repository, 1)
+		if err != nil {
+			c.logger.Errorw("Failed to fetch runners",
+				"collector", "runners",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+			// Keep the series of the repository with the last fetched runners
+			// instead of dropping them until the next successful fetch.
+			previous, ok := previousRunners[repository]
+			if !ok {
+				continue
+			}
+			r = previous
+		} else {
+			c.sample(repository, r, time.Now())
+		}
+		lastRunners[repository] = r
+		c.scrapeRepositoryRunners(ctx, repository, r, runners, utilizations, idleRunners, runnerInfo, latestVersion, filter)
+	}
+
+	utilization := newRunnerUtilizationGaugeVec()
+	for _, u := range utilizations {
+		utilization.WithLabelValues(u.labels...).Set(float64(u.busy) / float64(u.online))
+	}
+
+	runnerBusyTime := newRunnerBusyTimeCounterVec()
+	labelSetBusyTime := newLabelSetBusyTimeCounterVec()
+	c.mutex.RLock()
+	for repository, m := range c.busySeconds {
+		for runner, seconds := range m {
+			if labels, ok := filter.Apply("github_actions_runner_busy_seconds_total", runnerBusyTimeLabelNames,
+				repository,
+				runner,
+			); ok {
+				runnerBusyTime.WithLabelValues(labels...).Add(seconds)
+			}
+		}
+	}
+	for repository, m := range c.labelSetBusySeconds {
+		for labelSet, seconds := range m {
+			if labels, ok := filter.Apply("github_actions_runner_label_set_busy_seconds_total", runnerLabelSetLabelNames,
+				repository,
+				labelSet,
+			); ok {
+				labelSetBusyTime.WithLabelValues(labels...).Add(seconds)
+			}
+		}
+	}
+	c.mutex.RUnlock()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastRunners = lastRunners
+	c.runners = runners
+	c.runnerBusyTime = runnerBusyTime
+	c.labelSetBusyTime = labelSetBusyTime
+	c.utilization = utilization
+	c.idleRunners = idleRunners
+	c.runnerInfo = runnerInfo
+	c.latestVersion = latestVersion
+
+	if len(failed) > 0 {
+		return newRepositoriesError("failed to scrape runners", failed)
+	}
+	return nil
+}
+
+func (c *RunnersCollector) scrape(ctx context.Context) {
+	err := c.Scrape(ctx)
+	c.status.Record("runners", err)
 	if err != nil {
-		c.logger.Errorf("Failed to fetch runners count: %s\n", err.Error())
-		return
+		c.logger.Errorw("Failed to scrape",
+			"collector", "runners",
+			"error", err.Error(),
+		)
 	}
+}
+
+// runnerUtilization sums up runners of the series merged by the label policy,
+// so that their ratio is computed from the sums instead of overwriting each
+// other.
+type runnerUtilization struct {
+	labels []string
+	busy   int
+	online int
+}
+
+func (c *RunnersCollector) scrapeRepositoryRunners(
+	ctx context.Context,
+	repository string,
+	runners []Runner,
+	runnersGaugeVec *prometheus.GaugeVec,
+	utilizations map[string]*runnerUtilization,
+	idleRunnersGaugeVec *prometheus.GaugeVec,
+	runnerInfoGaugeVec *prometheus.GaugeVec,
+	latestVersionGaugeVec *prometheus.GaugeVec,
+	filter *LabelFilter,
+) {
+	online := make(map[string]int)
+	busy := make(map[string]int)
+	for _, runner := range runners {
+		if runner.Status != "online" {
+			continue
+		}
+		online[runner.labelSet()]++
+		if runner.Busy {
+			busy[runner.labelSet()]++
+		}
+	}
+	for labelSet, count := range online {
+		if labels, ok := filter.Apply("github_actions_runner_utilization_ratio", runnerLabelSetLabelNames,
+			repository,
+			labelSet,
+		); ok {
+			key := strings.Join(labels, "\xff")
+			u, ok := utilizations[key]
+			if !ok {
+				u = &runnerUtilization{labels: labels}
+				utilizations[key] = u
+			}
+			u.busy += busy[labelSet]
+			u.online += count
+		}
+		if labels, ok := filter.Apply("github_actions_idle_runners", runnerLabelSetLabelNames,
+			repository,
+			labelSet,
+		); ok {
+			idleRunnersGaugeVec.WithLabelValues(labels...).Add(float64(count - busy[labelSet]))
+		}
+	}
+
 	m := make(map[string][]Runner)
 	for _, runner := range runners {
 		m[runner.Status] = append(m[runner.Status], runner)
 	}
 	for _, status := range runnerStatuses {
-		labels := []string{
-			c.repository,
+		labels, ok := filter.Apply("github_actions_runners", runnersLabelNames,
+			repository,
 			status,
+		)
+		if ok {
+			runnersGaugeVec.WithLabelValues(labels...).Add(float64(len(m[status])))
 		}
-		c.runners.WithLabelValues(labels...).Set(float64(len(m[status])))
 	}
+	c.scrapeRepositoryRunnerVersions(ctx, repository, runners, runnerInfoGaugeVec, latestVersionGaugeVec, filter)
 }
 
-func (c *RunnersCollector) StartLoop(ctx context.Context, interval time.Duration) {
-	go func(ctx context.Context) {
-		c.scrapeRunners()
-		t := time.NewTicker(interval)
-		defer t.Stop()
-		for {
-			select {
-			case <-t.C:
-				c.scrapeRunners()
-			case <-ctx.Done():
-				return
-			}
+func (c *RunnersCollector) scrapeRepositoryRunnerVersions(
+	ctx context.Context,
+	repository string,
+	runners []Runner,
+	runnerInfoGaugeVec *prometheus.GaugeVec,
+	latestVersionGaugeVec *prometheus.GaugeVec,
+	filter *LabelFilter,
+) {
+	// The latest versions are best-effort, so that a failure only drops the
+	// latest version series and does not fail the other runner metrics.
+	applications, err := c.fetchRunnerApplications(ctx, repository)
+	if err != nil {
+		c.logger.Errorw("Failed to fetch runner applications",
+			"collector", "runners",
+			"repository", repository,
+			"error", err.Error(),
+		)
+	}
+	for _, application := range applications {
+		version := application.version()
+		if version == "" {
+			continue
+		}
+		if labels, ok := filter.Apply("github_actions_runner_latest_version_info", runnerLatestVersionLabelNames,
+			repository,
+			application.OS,
+			application.Architecture,
+			version,
+		); ok {
+			latestVersionGaugeVec.WithLabelValues(labels...).Set(1)
+		}
+	}
+
+	for _, runner := range runners {
+		if labels, ok := filter.Apply("github_actions_runner_info", runnerInfoLabelNames,
+			repository,
+			runner.Name,
+			runner.OS,
+		); ok {
+			runnerInfoGaugeVec.WithLabelValues(labels...).Set(1)
 		}
-	}(ctx)
+	}
+}
+
+// sample charges busy runners with the time since the last sample, which is
+// capped at the loop interval so that an outage is not charged as busy time.
+// Runners and label sets absent from the runners are dropped.
+func (c *RunnersCollector) sample(repository string, runners []Runner, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	busySeconds := make(map[string]float64)
+	labelSetBusySeconds := make(map[string]float64)
+	sampledAt, ok := c.sampledAt[repository]
+	elapsed := now.Sub(sampledAt)
+	if c.interval > 0 && elapsed > c.interval {
+		elapsed = c.interval
+	}
+	for _, runner := range runners {
+		busySeconds[runner.Name] = c.busySeconds[repository][runner.Name]
+		labelSetBusySeconds[runner.labelSet()] = c.labelSetBusySeconds[repository][runner.labelSet()]
+	}
+	for _, runner := range runners {
+		if !ok || !runner.Busy {
+			continue
+		}
+		busySeconds[runner.Name] += elapsed.Seconds()
+		labelSetBusySeconds[runner.labelSet()] += elapsed.Seconds()
+	}
+	c.busySeconds[repository] = busySeconds
+	c.labelSetBusySeconds[repository] = labelSetBusySeconds
+	c.sampledAt[repository] = now
+}
+
+func (c *RunnersCollector) StartLoop(ctx context.Context, interval time.Duration) {
+	c.status.Start("runners", interval)
+	c.mutex.Lock()
+	c.interval = interval
+	c.mutex.Unlock()
+	c.loop.start(ctx, interval, nil, c.scrape)
 }
 
 func (c *RunnersCollector) collectors() []prometheus.Collector {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return []prometheus.Collector{
 		c.runners,
+		c.runnerBusyTime,
+		c.labelSetBusyTime,
+		c.utilization,
+		c.idleRunners,
+		c.runnerInfo,
+		c.latestVersion,
 	}
 }
 
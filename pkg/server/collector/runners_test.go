@@ -0,0 +1,316 @@
+package collector_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRunnersCollectorScrape(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string
+		downloads   string
+		metricNames []string
+		want        string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			`{"total_count": 4, "runners": [
+  {"id": 1, "name": "fake1", "status": "online", "busy": true, "labels": [{"name": "self-hosted"}, {"name": "linux"}]},
+  {"id": 2, "name": "fake2", "status": "online", "busy": false, "labels": [{"name": "linux"}, {"name": "self-hosted"}]},
+  {"id": 3, "name": "fake3", "status": "offline", "busy": false, "labels": [{"name": "self-hosted"}, {"name": "linux"}]},
+  {"id": 4, "name": "fake4", "status": "online", "busy": true, "labels": [{"name": "self-hosted"}, {"name": "gpu"}]}
+]}`,
+			`[]`,
+			[]string{"github_actions_idle_runners", "github_actions_runner_utilization_ratio"},
+			`
+# HELP github_actions_idle_runners Number of online runners which are not busy of each label set
+# TYPE github_actions_idle_runners gauge
+github_actions_idle_runners{labels="gpu,self-hosted",repository="fake/fake"} 0
+github_actions_idle_runners{labels="linux,self-hosted",repository="fake/fake"} 1
+# HELP github_actions_runner_utilization_ratio Ratio of busy runners to online runners of each label set
+# TYPE github_actions_runner_utilization_ratio gauge
+github_actions_runner_utilization_ratio{labels="gpu,self-hosted",repository="fake/fake"} 1
+github_actions_runner_utilization_ratio{labels="linux,self-hosted",repository="fake/fake"} 0.5
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			`{"total_count": 3, "runners": [
+  {"id": 1, "name": "fake1", "os": "Linux", "status": "online"},
+  {"id": 2, "name": "fake2", "os": "Linux", "status": "online"},
+  {"id": 3, "name": "fake3", "os": "Windows", "status": "online"}
+]}`,
+			`[
+  {"os": "linux", "architecture": "x64", "filename": "actions-runner-linux-x64-2.311.0.tar.gz"},
+  {"os": "linux", "architecture": "arm64", "filename": "actions-runner-linux-arm64-2.311.0.tar.gz"},
+  {"os": "win", "architecture": "x64", "filename": "actions-runner-win-x64-2.311.0.zip"}
+]`,
+			[]string{"github_actions_runner_info", "github_actions_runner_latest_version_info"},
+			`
+# HELP github_actions_runner_info Information about each runner
+# TYPE github_actions_runner_info gauge
+github_actions_runner_info{name="fake1",os="Linux",repository="fake/fake"} 1
+github_actions_runner_info{name="fake2",os="Linux",repository="fake/fake"} 1
+github_actions_runner_info{name="fake3",os="Windows",repository="fake/fake"} 1
+# HELP github_actions_runner_latest_version_info Latest version of runner application available for each platform
+# TYPE github_actions_runner_latest_version_info gauge
+github_actions_runner_latest_version_info{architecture="arm64",os="linux",repository="fake/fake",version="2.311.0"} 1
+github_actions_runner_latest_version_info{architecture="x64",os="linux",repository="fake/fake",version="2.311.0"} 1
+github_actions_runner_latest_version_info{architecture="x64",os="win",repository="fake/fake",version="2.311.0"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		response := tt.response
+		downloads := tt.downloads
+		metricNames := tt.metricNames
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver := collector.NewRunnersCollector(
+				[]string{"fake/fake"},
+				nil,
+				nil,
+				loggerMock{
+					fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+						t.Errorf("%s: %v", msg, keysAndValues)
+					},
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				httpClientMock{
+					fakeDo: func(request *http.Request) (*http.Response, error) {
+						body := response
+						if request.URL.Path == "/repos/fake/fake/actions/runners/downloads" {
+							body = downloads
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(body)),
+						}, nil
+					},
+				},
+			)
+			for i := 0; i < 2; i++ {
+				if err := receiver.Scrape(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := testutil.CollectAndCompare(receiver, strings.NewReader(want), metricNames...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRunnersCollectorScrapeKeepsFailedSeries(t *testing.T) {
+	failing := false
+	receiver := collector.NewRunnersCollector(
+		[]string{"fake/fake"},
+		nil,
+		nil,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				body := `{"total_count": 2, "runners": [
+  {"id": 1, "name": "fake1", "status": "online", "busy": true, "labels": [{"name": "linux"}]},
+  {"id": 2, "name": "fake2", "status": "offline", "busy": false, "labels": [{"name": "linux"}]}
+]}`
+				if request.URL.Path == "/repos/fake/fake/actions/runners/downloads" {
+					body = `[]`
+				} else if failing {
+					return nil, errors.New("fake")
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		},
+	)
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	failing = true
+	if err := receiver.Scrape(context.Background()); err == nil {
+		t.Fatal("want error, but got nil")
+	}
+	want := `
+# HELP github_actions_idle_runners Number of online runners which are not busy of each label set
+# TYPE github_actions_idle_runners gauge
+github_actions_idle_runners{labels="linux",repository="fake/fake"} 0
+# HELP github_actions_runner_utilization_ratio Ratio of busy runners to online runners of each label set
+# TYPE github_actions_runner_utilization_ratio gauge
+github_actions_runner_utilization_ratio{labels="linux",repository="fake/fake"} 1
+`
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+		"github_actions_idle_runners",
+		"github_actions_runner_utilization_ratio",
+	); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunnersCollectorScrapeDropsRemovedLabelSets(t *testing.T) {
+	label := "linux"
+	receiver := collector.NewRunnersCollector(
+		[]string{"fake/fake"},
+		nil,
+		nil,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				body := `{"total_count": 1, "runners": [
+  {"id": 1, "name": "fake1", "status": "online", "busy": false, "labels": [{"name": "` + label + `"}]}
+]}`
+				if request.URL.Path == "/repos/fake/fake/actions/runners/downloads" {
+					body = `[]`
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		},
+	)
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	label = "gpu"
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+# HELP github_actions_runner_label_set_busy_seconds_total Total time runners of each label set were sampled busy
+# TYPE github_actions_runner_label_set_busy_seconds_total counter
+github_actions_runner_label_set_busy_seconds_total{labels="gpu",repository="fake/fake"} 0
+`
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+		"github_actions_runner_label_set_busy_seconds_total",
+	); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunnersCollectorScrapeMergesUtilization(t *testing.T) {
+	policy, err := collector.NewLabelPolicy(collector.LabelPolicyConfig{
+		Rules: []collector.LabelRule{
+			{Action: "replace", Label: "repository", Regex: "fake/.*", Replacement: "fake"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses := map[string]string{
+		"/repos/fake/fake1/actions/runners": `{"total_count": 1, "runners": [
+  {"id": 1, "name": "fake1", "status": "online", "busy": true, "labels": [{"name": "linux"}]}
+]}`,
+		"/repos/fake/fake2/actions/runners": `{"total_count": 3, "runners": [
+  {"id": 2, "name": "fake2", "status": "online", "busy": false, "labels": [{"name": "linux"}]},
+  {"id": 3, "name": "fake3", "status": "online", "busy": false, "labels": [{"name": "linux"}]},
+  {"id": 4, "name": "fake4", "status": "online", "busy": false, "labels": [{"name": "linux"}]}
+]}`,
+	}
+	receiver := collector.NewRunnersCollector(
+		[]string{"fake/fake1", "fake/fake2"},
+		nil,
+		policy,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+				t.Errorf("%s: %v", msg, keysAndValues)
+			},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				body, ok := responses[request.URL.Path]
+				if !ok {
+					body = `[]`
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		},
+	)
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+# HELP github_actions_idle_runners Number of online runners which are not busy of each label set
+# TYPE github_actions_idle_runners gauge
+github_actions_idle_runners{labels="linux",repository="fake"} 3
+# HELP github_actions_runner_utilization_ratio Ratio of busy runners to online runners of each label set
+# TYPE github_actions_runner_utilization_ratio gauge
+github_actions_runner_utilization_ratio{labels="linux",repository="fake"} 0.25
+`
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+		"github_actions_idle_runners",
+		"github_actions_runner_utilization_ratio",
+	); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunnersCollectorScrapeWithoutRunnerApplications(t *testing.T) {
+	receiver := collector.NewRunnersCollector(
+		[]string{"fake/fake"},
+		nil,
+		nil,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				if request.URL.Path == "/repos/fake/fake/actions/runners/downloads" {
+					return nil, errors.New("fake")
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body: ioutil.NopCloser(strings.NewReader(`{"total_count": 1, "runners": [
+  {"id": 1, "name": "fake1", "os": "Linux", "status": "online"}
+]}`)),
+				}, nil
+			},
+		},
+	)
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+# HELP github_actions_runner_info Information about each runner
+# TYPE github_actions_runner_info gauge
+github_actions_runner_info{name="fake1",os="Linux",repository="fake/fake"} 1
+`
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+		"github_actions_runner_info",
+		"github_actions_runner_latest_version_info",
+	); err != nil {
+		t.Error(err)
+	}
+}
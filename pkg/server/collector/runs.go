@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
+	"go.opencensus.io/trace"
 	"golang.org/x/xerrors"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -15,6 +17,21 @@ import (
 
 const (
 	namespace = "github_actions"
+
+	dayLayout = "2006-01-02"
+
+	// actorWindow is how long the usage of an actor is kept.
+	actorWindow = 7 * 24 * time.Hour
+	// actorsMaxCount caps the number of actors kept in the state of a repository.
+	actorsMaxCount = 1000
+)
+
+var (
+	runsLabelNames               = []string{"repository", "status"}
+	completedRunsLabelNames      = []string{"repository", "workflow_id", "conclusion"}
+	pendingDeploymentsLabelNames = []string{"repository", "environment"}
+	triggeredRunsLabelNames      = []string{"repository", "event", "actor_type"}
+	actorRunsLabelNames          = []string{"repository", "actor", "actor_type"}
 )
 
 var (
@@ -22,106 +39,667 @@ var (
 		"queued",
 		"in_progress",
 		"completed",
+		"waiting",
+		"requested",
+		"pending",
 	}
 )
 
+var (
+	runsPerPage = 100
+	runsMaxPage = 10
+)
+
+type WorkflowRun struct {
+	ID              uint64                   `json:"id"`
+	Name            string                   `json:"name"`
+	WorkflowID      uint64                   `json:"workflow_id"`
+	HeadBranch      string                   `json:"head_branch"`
+	HeadSHA         string                   `json:"head_sha"`
+	Event           string                   `json:"event"`
+	Status          string                   `json:"status"`
+	Conclusion      string                   `json:"conclusion"`
+	RunNumber       uint64                   `json:"run_number"`
+	RunAttempt      uint64                   `json:"run_attempt"`
+	CreatedAt       string                   `json:"created_at"`
+	UpdatedAt       string                   `json:"updated_at"`
+	RunStartedAt    string                   `json:"run_started_at"`
+	HTMLURL         string                   `json:"html_url"`
+	HeadCommit      *WorkflowRunCommit       `json:"head_commit,omitempty"`
+	PullRequests    []WorkflowRunPullRequest `json:"pull_requests,omitempty"`
+	Actor           *WorkflowRunActor        `json:"actor,omitempty"`
+	TriggeringActor *WorkflowRunActor        `json:"triggering_actor,omitempty"`
+}
+
+type WorkflowRunPullRequest struct {
+	Number uint64 `json:"number"`
+}
+
+type WorkflowRunActor struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+func (r WorkflowRun) triggeringActor() WorkflowRunActor {
+	if r.TriggeringActor != nil {
+		return *r.TriggeringActor
+	}
+	if r.Actor != nil {
+		return *r.Actor
+	}
+	return WorkflowRunActor{}
+}
+
+func (r WorkflowRun) duration() float64 {
+	startedAt, err := time.Parse(time.RFC3339, r.RunStartedAt)
+	if err != nil {
+		return 0
+	}
+	updatedAt, err := time.Parse(time.RFC3339, r.UpdatedAt)
+	if err != nil || updatedAt.Before(startedAt) {
+		return 0
+	}
+	return updatedAt.Sub(startedAt).Seconds()
+}
+
+type WorkflowRunCommit struct {
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+}
+
 type WorkflowRunsResponse struct {
-	TotalCount   *int              `json:"total_count,omitempty"`
-	WorkflowRuns []json.RawMessage `json:"workflow_runs,omitempty"`
+	TotalCount   *int          `json:"total_count,omitempty"`
+	WorkflowRuns []WorkflowRun `json:"workflow_runs,omitempty"`
+}
+
+type RunsUsage struct {
+	Runs            uint64  `json:"runs"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+type ActorUsage struct {
+	Type string                `json:"type"`
+	Days map[string]*RunsUsage `json:"days"`
+}
+
+// total returns the usage of the actor in the days kept in the state.
+func (a *ActorUsage) total() RunsUsage {
+	var usage RunsUsage
+	for _, u := range a.Days {
+		usage.Runs += u.Runs
+		usage.DurationSeconds += u.DurationSeconds
+	}
+	return usage
+}
+
+// RunsWatermark tracks which workflow runs of a repository have already been seen.
+type RunsWatermark struct {
+	Watermark uint64   `json:"watermark"`
+	Counted   []uint64 `json:"counted"`
+}
+
+// update advances the watermark and returns the runs newly completed since the last update.
+// Nothing is returned for the first update so that the history is not counted.
+func (s *RunsWatermark) update(runs []WorkflowRun) []WorkflowRun {
+	initialized := s.Watermark != 0
+	counted := make(map[uint64]struct{})
+	for _, id := range s.Counted {
+		counted[id] = struct{}{}
+	}
+
+	var completed []WorkflowRun
+	watermark := s.Watermark
+	if watermark == 0 {
+		watermark = 1
+	}
+	for _, run := range runs {
+		if run.ID >= watermark {
+			watermark = run.ID + 1
+		}
+	}
+	for _, run := range runs {
+		if run.ID < s.Watermark {
+			continue
+		}
+		if run.Status != "completed" {
+			if run.ID < watermark {
+				watermark = run.ID
+			}
+			continue
+		}
+		if _, ok := counted[run.ID]; ok {
+			continue
+		}
+		counted[run.ID] = struct{}{}
+		if !initialized {
+			continue
+		}
+		completed = append(completed, run)
+	}
+
+	s.Watermark = watermark
+	s.Counted = []uint64{}
+	for id := range counted {
+		if id >= watermark {
+			s.Counted = append(s.Counted, id)
+		}
+	}
+	sort.Slice(s.Counted, func(i, j int) bool {
+		return s.Counted[i] < s.Counted[j]
+	})
+	return completed
+}
+
+type RunsState struct {
+	RunsWatermark
+	CompletedRuns map[string]map[string]uint64     `json:"completed_runs"`
+	Events        map[string]map[string]*RunsUsage `json:"events"`
+	Actors        map[string]*ActorUsage           `json:"actors"`
+}
+
+func (s *RunsState) update(runs []WorkflowRun, now time.Time) []WorkflowRun {
+	if s.CompletedRuns == nil {
+		s.CompletedRuns = make(map[string]map[string]uint64)
+	}
+	if s.Events == nil {
+		s.Events = make(map[string]map[string]*RunsUsage)
+	}
+	if s.Actors == nil {
+		s.Actors = make(map[string]*ActorUsage)
+	}
+
+	completed := s.RunsWatermark.update(runs)
+	for _, run := range completed {
+		workflowID := strconv.FormatUint(run.WorkflowID, 10)
+		if s.CompletedRuns[workflowID] == nil {
+			s.CompletedRuns[workflowID] = make(map[string]uint64)
+		}
+		s.CompletedRuns[workflowID][run.Conclusion]++
+
+		actor := run.triggeringActor()
+		// Runs of the GraphQL backend have no triggering actor, and are left out
+		// of the breakdown instead of being counted with empty labels.
+		if actor.Type == "" {
+			continue
+		}
+		duration := run.duration()
+		if s.Events[run.Event] == nil {
+			s.Events[run.Event] = make(map[string]*RunsUsage)
+		}
+		if s.Events[run.Event][actor.Type] == nil {
+			s.Events[run.Event][actor.Type] = &RunsUsage{}
+		}
+		s.Events[run.Event][actor.Type].Runs++
+		s.Events[run.Event][actor.Type].DurationSeconds += duration
+		if actor.Login != "" {
+			day := now.UTC().Format(dayLayout)
+			if updatedAt, err := time.Parse(time.RFC3339, run.UpdatedAt); err == nil {
+				day = updatedAt.UTC().Format(dayLayout)
+			}
+			if s.Actors[actor.Login] == nil {
+				s.Actors[actor.Login] = &ActorUsage{}
+			}
+			if s.Actors[actor.Login].Days == nil {
+				s.Actors[actor.Login].Days = make(map[string]*RunsUsage)
+			}
+			if s.Actors[actor.Login].Days[day] == nil {
+				s.Actors[actor.Login].Days[day] = &RunsUsage{}
+			}
+			s.Actors[actor.Login].Type = actor.Type
+			s.Actors[actor.Login].Days[day].Runs++
+			s.Actors[actor.Login].Days[day].DurationSeconds += duration
+		}
+	}
+	s.pruneActors(now)
+	return completed
+}
+
+// pruneActors drops the days out of the actor window and keeps only the most active actors
+// so that the state stays small enough for the store.
+func (s *RunsState) pruneActors(now time.Time) {
+	oldest := now.Add(-actorWindow).UTC().Format(dayLayout)
+	for login, actor := range s.Actors {
+		for day := range actor.Days {
+			if day < oldest {
+				delete(actor.Days, day)
+			}
+		}
+		if len(actor.Days) == 0 {
+			delete(s.Actors, login)
+		}
+	}
+	if len(s.Actors) <= actorsMaxCount {
+		return
+	}
+	totals := make(map[string]RunsUsage, len(s.Actors))
+	for login, actor := range s.Actors {
+		totals[login] = actor.total()
+	}
+	for _, login := range sortActors(totals)[actorsMaxCount:] {
+		delete(s.Actors, login)
+	}
 }
 
 type RunsCollector struct {
-	repository string
-	token      string
-	logger     ILogger
-	httpClient IHTTPClient
-	runs       *prometheus.GaugeVec
+	repositories         []string
+	backend              IBackend
+	store                IStore
+	status               *StatusRecorder
+	policy               *LabelPolicy
+	actorTopN            int
+	logger               ILogger
+	httpClient           IHTTPClient
+	states               map[string]*RunsState
+	lastCounts           map[string]map[string]int
+	lastPending          map[string]map[string]*pendingEnvironment
+	loop                 loop
+	mutex                sync.RWMutex
+	runs                 *prometheus.GaugeVec
+	completedRuns        *prometheus.CounterVec
+	pendingDeployments   *prometheus.GaugeVec
+	pendingDeploymentAge *prometheus.GaugeVec
+	triggeredRuns        *prometheus.CounterVec
+	triggeredRunDuration *prometheus.CounterVec
+	actorRuns            *prometheus.GaugeVec
+	actorRunDuration     *prometheus.GaugeVec
 }
 
 func NewRunsCollector(
-	repository string,
-	token string,
+	repositories []string,
+	backend IBackend,
+	store IStore,
+	status *StatusRecorder,
+	policy *LabelPolicy,
+	actorTopN int,
 	logger ILogger,
 	httpClient IHTTPClient,
 ) *RunsCollector {
 	return &RunsCollector{
-		repository: repository,
-		token:      token,
-		logger:     logger,
-		httpClient: httpClient,
-		runs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "runs",
-			Help:      "List how many workflow runs each repository actions",
-		}, []string{"repository", "status"}),
+		repositories:         repositories,
+		backend:              backend,
+		store:                store,
+		status:               status,
+		policy:               policy,
+		actorTopN:            actorTopN,
+		logger:               logger,
+		httpClient:           httpClient,
+		states:               make(map[string]*RunsState),
+		lastCounts:           make(map[string]map[string]int),
+		lastPending:          make(map[string]map[string]*pendingEnvironment),
+		runs:                 newRunsGaugeVec(),
+		completedRuns:        newCompletedRunsCounterVec(),
+		pendingDeployments:   newPendingDeploymentsGaugeVec(),
+		pendingDeploymentAge: newPendingDeploymentAgeGaugeVec(),
+		triggeredRuns:        newTriggeredRunsCounterVec(),
+		triggeredRunDuration: newTriggeredRunDurationCounterVec(),
+		actorRuns:            newActorRunsGaugeVec(),
+		actorRunDuration:     newActorRunDurationGaugeVec(),
 	}
 }
 
-func (c *RunsCollector) fetchRunsCount(status string) (*int, error) {
-	request, err := http.NewRequest("GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/runs?status=%s", c.repository, status), nil)
-	if err != nil {
-		return nil, xerrors.Errorf("failed to create request object: %w", err)
+func newRunsGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "runs",
+		Help:      "List how many workflow runs each repository actions",
+	}, runsLabelNames)
+}
+
+func newCompletedRunsCounterVec() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "completed_runs_total",
+		Help:      "Total number of completed workflow runs",
+	}, completedRunsLabelNames)
+}
+
+func newTriggeredRunsCounterVec() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "triggered_runs_total",
+		Help:      "Total number of completed workflow runs by triggering event and type of triggering actor",
+	}, triggeredRunsLabelNames)
+}
+
+func newTriggeredRunDurationCounterVec() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "triggered_run_duration_seconds_total",
+		Help:      "Total duration of completed workflow runs by triggering event and type of triggering actor",
+	}, triggeredRunsLabelNames)
+}
+
+func newActorRunsGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "actor_runs",
+		Help:      "Number of workflow runs completed in the last 7 days by triggering actor",
+	}, actorRunsLabelNames)
+}
+
+func newActorRunDurationGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "actor_run_duration_seconds",
+		Help:      "Duration of workflow runs completed in the last 7 days by triggering actor",
+	}, actorRunsLabelNames)
+}
+
+func (c *RunsCollector) stateKey(repository string) string {
+	return fmt.Sprintf("runs/%s", repository)
+}
+
+func (c *RunsCollector) loadState(repository string) (*RunsState, error) {
+	c.mutex.RLock()
+	state, ok := c.states[repository]
+	c.mutex.RUnlock()
+	if ok {
+		return state, nil
 	}
-	request.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
-	response, err := c.httpClient.Do(request)
+	state = &RunsState{}
+	b, err := c.store.Get(c.stateKey(repository))
 	if err != nil {
-		return nil, xerrors.Errorf("failed to request: %w", err)
+		return nil, xerrors.Errorf("failed to get state: %w", err)
 	}
-	defer response.Body.Close()
+	if b != nil {
+		if err := json.Unmarshal(b, state); err != nil {
+			return nil, xerrors.Errorf("failed to parse state: %w", err)
+		}
+	}
+	c.mutex.Lock()
+	c.states[repository] = state
+	c.mutex.Unlock()
+	return state, nil
+}
 
-	body, err := ioutil.ReadAll(response.Body)
+func (c *RunsCollector) saveState(repository string, state *RunsState) error {
+	b, err := json.Marshal(state)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to read response: %w", err)
+		return xerrors.Errorf("failed to marshal state: %w", err)
+	}
+	if err := c.store.Put(c.stateKey(repository), b); err != nil {
+		return xerrors.Errorf("failed to put state: %w", err)
+	}
+	return nil
+}
+
+func (c *RunsCollector) scrapeCompletedRuns(ctx context.Context) error {
+	var failed []string
+	var repositories []string
+	states := make(map[string]*RunsState)
+	since := make(map[string]uint64)
+	for _, repository := range c.repositories {
+		state, err := c.loadState(repository)
+		if err != nil {
+			c.logger.Errorw("Failed to load state",
+				"collector", "runs",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+			continue
+		}
+		repositories = append(repositories, repository)
+		states[repository] = state
+		since[repository] = state.Watermark
+	}
+
+	runs, errs := c.backend.FetchRuns(ctx, repositories, since)
+	filter := c.policy.NewFilter()
+	completedRuns := newCompletedRunsCounterVec()
+	triggeredRuns := newTriggeredRunsCounterVec()
+	triggeredRunDuration := newTriggeredRunDurationCounterVec()
+	actorRuns := newActorRunsGaugeVec()
+	actorRunDuration := newActorRunDurationGaugeVec()
+	now := time.Now()
+	for _, repository := range repositories {
+		state := states[repository]
+		if err, ok := errs[repository]; ok {
+			c.logger.Errorw("Failed to fetch runs",
+				"collector", "runs",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+		} else {
+			state.update(runs[repository], now)
+			if err := c.saveState(repository, state); err != nil {
+				c.logger.Errorw("Failed to save state",
+					"collector", "runs",
+					"repository", repository,
+					"error", err.Error(),
+				)
+			}
+		}
+		for workflowID, m := range state.CompletedRuns {
+			for conclusion, count := range m {
+				labels, ok := filter.Apply("github_actions_completed_runs_total", completedRunsLabelNames,
+					repository,
+					workflowID,
+					conclusion,
+				)
+				if ok {
+					completedRuns.WithLabelValues(labels...).Add(float64(count))
+				}
+			}
+		}
+		for event, m := range state.Events {
+			for actorType, usage := range m {
+				labels, ok := filter.Apply("github_actions_triggered_runs_total", triggeredRunsLabelNames,
+					repository,
+					event,
+					actorType,
+				)
+				if ok {
+					triggeredRuns.WithLabelValues(labels...).Add(float64(usage.Runs))
+				}
+				labels, ok = filter.Apply("github_actions_triggered_run_duration_seconds_total", triggeredRunsLabelNames,
+					repository,
+					event,
+					actorType,
+				)
+				if ok {
+					triggeredRunDuration.WithLabelValues(labels...).Add(usage.DurationSeconds)
+				}
+			}
+		}
 	}
+	// The top actors are chosen across all repositories so that an actor is either
+	// broken out or folded into "other" everywhere.
+	if c.actorTopN > 0 {
+		top := topActors(states, c.actorTopN)
+		for _, repository := range repositories {
+			for actor, usage := range actorsUsage(states[repository].Actors, top) {
+				labels, ok := filter.Apply("github_actions_actor_runs", actorRunsLabelNames,
+					repository,
+					actor[0],
+					actor[1],
+				)
+				if ok {
+					actorRuns.WithLabelValues(labels...).Add(float64(usage.Runs))
+				}
+				labels, ok = filter.Apply("github_actions_actor_run_duration_seconds", actorRunsLabelNames,
+					repository,
+					actor[0],
+					actor[1],
+				)
+				if ok {
+					actorRunDuration.WithLabelValues(labels...).Add(usage.DurationSeconds)
+				}
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.completedRuns = completedRuns
+	c.triggeredRuns = triggeredRuns
+	c.triggeredRunDuration = triggeredRunDuration
+	c.actorRuns = actorRuns
+	c.actorRunDuration = actorRunDuration
 
-	var workflowRunsResponse WorkflowRunsResponse
-	if err := json.Unmarshal(body, &workflowRunsResponse); err != nil {
-		return nil, xerrors.Errorf("failed to parse response: %w", err)
+	if len(failed) > 0 {
+		return newRepositoriesError("failed to scrape completed runs", failed)
 	}
+	return nil
+}
+
+// sortActors returns the logins ordered by descending number of runs.
+func sortActors(totals map[string]RunsUsage) []string {
+	logins := make([]string, 0, len(totals))
+	for login := range totals {
+		logins = append(logins, login)
+	}
+	sort.Slice(logins, func(i, j int) bool {
+		if totals[logins[i]].Runs != totals[logins[j]].Runs {
+			return totals[logins[i]].Runs > totals[logins[j]].Runs
+		}
+		return logins[i] < logins[j]
+	})
+	return logins
+}
 
-	if workflowRunsResponse.TotalCount == nil {
-		return nil, xerrors.Errorf("bad response: %s", string(body))
+// topActors returns the n actors with the most runs across the repositories.
+func topActors(states map[string]*RunsState, n int) map[string]struct{} {
+	totals := make(map[string]RunsUsage)
+	for _, state := range states {
+		for login, actor := range state.Actors {
+			usage := actor.total()
+			total := totals[login]
+			total.Runs += usage.Runs
+			total.DurationSeconds += usage.DurationSeconds
+			totals[login] = total
+		}
 	}
+	top := make(map[string]struct{})
+	for i, login := range sortActors(totals) {
+		if i >= n {
+			break
+		}
+		top[login] = struct{}{}
+	}
+	return top
+}
 
-	return workflowRunsResponse.TotalCount, nil
+// actorsUsage returns the usage by actor and actor type, folding the actors out of top into "other".
+func actorsUsage(actors map[string]*ActorUsage, top map[string]struct{}) map[[2]string]RunsUsage {
+	result := make(map[[2]string]RunsUsage)
+	for login, actor := range actors {
+		key := [2]string{login, actor.Type}
+		if _, ok := top[login]; !ok {
+			key[0] = otherLabelValue
+		}
+		total := actor.total()
+		usage := result[key]
+		usage.Runs += total.Runs
+		usage.DurationSeconds += total.DurationSeconds
+		result[key] = usage
+	}
+	return result
 }
 
-func (c *RunsCollector) scrapeRuns() {
-	for _, status := range statuses {
-		count, err := c.fetchRunsCount(status)
-		if err != nil {
-			c.logger.Errorf("Failed to fetch runs count: %s\n", err.Error())
-			return
+func (c *RunsCollector) Scrape(ctx context.Context) error {
+	ctx = withCollector(ctx, "runs")
+	ctx, span := trace.StartSpan(ctx, "RunsCollector.Scrape")
+	defer span.End()
+	span.AddAttributes(trace.Int64Attribute("github.repositories", int64(len(c.repositories))))
+
+	completedRunsErr := c.scrapeCompletedRuns(ctx)
+
+	c.mutex.RLock()
+	previousCounts := c.lastCounts
+	previousPending := c.lastPending
+	c.mutex.RUnlock()
+
+	var failed []string
+	fetched, errs := c.backend.FetchRunsCounts(ctx, c.repositories)
+	counts := make(map[string]map[string]int)
+	for repository, m := range fetched {
+		counts[repository] = m
+	}
+	for repository, err := range errs {
+		c.logger.Errorw("Failed to fetch runs count",
+			"collector", "runs",
+			"repository", repository,
+			"error", err.Error(),
+		)
+		failed = append(failed, repository)
+		// Keep the series of the repository with the last fetched counts
+		// instead of dropping them until the next successful fetch.
+		if m, ok := previousCounts[repository]; ok {
+			counts[repository] = m
 		}
-		if count != nil {
-			labels := []string{
-				c.repository,
+	}
+	filter := c.policy.NewFilter()
+	runs := newRunsGaugeVec()
+	for repository, m := range counts {
+		for status, count := range m {
+			labels, ok := filter.Apply("github_actions_runs", runsLabelNames,
+				repository,
 				status,
+			)
+			if ok {
+				runs.WithLabelValues(labels...).Add(float64(count))
 			}
-			c.runs.WithLabelValues(labels...).Set(float64(*count))
 		}
 	}
+
+	lastPending, pendingDeployments, pendingDeploymentAge, pendingDeploymentsErr := c.scrapePendingDeployments(ctx, counts, previousPending, filter)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastCounts = counts
+	c.lastPending = lastPending
+	c.runs = runs
+	c.pendingDeployments = pendingDeployments
+	c.pendingDeploymentAge = pendingDeploymentAge
+
+	if completedRunsErr != nil {
+		return xerrors.Errorf("failed to execute scrapeCompletedRuns: %w", completedRunsErr)
+	}
+	if len(failed) > 0 {
+		return newRepositoriesError("failed to fetch runs count", failed)
+	}
+	if pendingDeploymentsErr != nil {
+		return xerrors.Errorf("failed to execute scrapePendingDeployments: %w", pendingDeploymentsErr)
+	}
+	return nil
+}
+
+func (c *RunsCollector) scrape(ctx context.Context) {
+	err := c.Scrape(ctx)
+	c.status.Record("runs", err)
+	if err != nil {
+		c.logger.Errorw("Failed to scrape",
+			"collector", "runs",
+			"error", err.Error(),
+		)
+	}
+}
+
+func (c *RunsCollector) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.states = make(map[string]*RunsState)
 }
 
 func (c *RunsCollector) StartLoop(ctx context.Context, interval time.Duration) {
-	go func(ctx context.Context) {
-		c.scrapeRuns()
-		t := time.NewTicker(interval)
-		defer t.Stop()
-		for {
-			select {
-			case <-t.C:
-				c.scrapeRuns()
-			case <-ctx.Done():
-				return
-			}
-		}
-	}(ctx)
+	c.status.Start("runs", interval)
+	c.loop.start(ctx, interval, c.reset, c.scrape)
 }
 
 func (c *RunsCollector) collectors() []prometheus.Collector {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return []prometheus.Collector{
 		c.runs,
+		c.completedRuns,
+		c.pendingDeployments,
+		c.pendingDeploymentAge,
+		c.triggeredRuns,
+		c.triggeredRunDuration,
+		c.actorRuns,
+		c.actorRunDuration,
 	}
 }
 
@@ -0,0 +1,544 @@
+package collector_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/xerrors"
+)
+
+func TestRunsCollectorScrapeTriggeredRuns(t *testing.T) {
+	startedAt := time.Now().Add(-time.Hour).UTC()
+	runs := []collector.WorkflowRun{
+		{ID: 1, Status: "completed", Event: "push"},
+		{
+			ID:              2,
+			Status:          "completed",
+			Event:           "push",
+			RunStartedAt:    startedAt.Format(time.RFC3339),
+			UpdatedAt:       startedAt.Add(time.Minute).Format(time.RFC3339),
+			Actor:           &collector.WorkflowRunActor{Login: "alice", Type: "User"},
+			TriggeringActor: &collector.WorkflowRunActor{Login: "bob", Type: "User"},
+		},
+		{
+			ID:           3,
+			Status:       "completed",
+			Event:        "push",
+			RunStartedAt: startedAt.Format(time.RFC3339),
+			UpdatedAt:    startedAt.Add(2 * time.Minute).Format(time.RFC3339),
+			Actor:        &collector.WorkflowRunActor{Login: "bob", Type: "User"},
+		},
+		{
+			ID:           4,
+			Status:       "completed",
+			Event:        "pull_request",
+			RunStartedAt: startedAt.Format(time.RFC3339),
+			UpdatedAt:    startedAt.Add(30 * time.Second).Format(time.RFC3339),
+			Actor:        &collector.WorkflowRunActor{Login: "alice", Type: "User"},
+		},
+		{
+			ID:           5,
+			Status:       "completed",
+			Event:        "pull_request",
+			RunStartedAt: startedAt.Format(time.RFC3339),
+			UpdatedAt:    startedAt.Add(10 * time.Second).Format(time.RFC3339),
+			Actor:        &collector.WorkflowRunActor{Login: "dependabot[bot]", Type: "Bot"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		actorTopN int
+		want      string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			0,
+			`
+# HELP github_actions_triggered_runs_total Total number of completed workflow runs by triggering event and type of triggering actor
+# TYPE github_actions_triggered_runs_total counter
+github_actions_triggered_runs_total{actor_type="Bot",event="pull_request",repository="fake/fake1"} 1
+github_actions_triggered_runs_total{actor_type="User",event="pull_request",repository="fake/fake1"} 1
+github_actions_triggered_runs_total{actor_type="User",event="push",repository="fake/fake1"} 2
+# HELP github_actions_triggered_run_duration_seconds_total Total duration of completed workflow runs by triggering event and type of triggering actor
+# TYPE github_actions_triggered_run_duration_seconds_total counter
+github_actions_triggered_run_duration_seconds_total{actor_type="Bot",event="pull_request",repository="fake/fake1"} 10
+github_actions_triggered_run_duration_seconds_total{actor_type="User",event="pull_request",repository="fake/fake1"} 30
+github_actions_triggered_run_duration_seconds_total{actor_type="User",event="push",repository="fake/fake1"} 180
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			1,
+			`
+# HELP github_actions_triggered_runs_total Total number of completed workflow runs by triggering event and type of triggering actor
+# TYPE github_actions_triggered_runs_total counter
+github_actions_triggered_runs_total{actor_type="Bot",event="pull_request",repository="fake/fake1"} 1
+github_actions_triggered_runs_total{actor_type="User",event="pull_request",repository="fake/fake1"} 1
+github_actions_triggered_runs_total{actor_type="User",event="push",repository="fake/fake1"} 2
+# HELP github_actions_triggered_run_duration_seconds_total Total duration of completed workflow runs by triggering event and type of triggering actor
+# TYPE github_actions_triggered_run_duration_seconds_total counter
+github_actions_triggered_run_duration_seconds_total{actor_type="Bot",event="pull_request",repository="fake/fake1"} 10
+github_actions_triggered_run_duration_seconds_total{actor_type="User",event="pull_request",repository="fake/fake1"} 30
+github_actions_triggered_run_duration_seconds_total{actor_type="User",event="push",repository="fake/fake1"} 180
+# HELP github_actions_actor_runs Number of workflow runs completed in the last 7 days by triggering actor
+# TYPE github_actions_actor_runs gauge
+github_actions_actor_runs{actor="bob",actor_type="User",repository="fake/fake1"} 2
+github_actions_actor_runs{actor="other",actor_type="Bot",repository="fake/fake1"} 1
+github_actions_actor_runs{actor="other",actor_type="User",repository="fake/fake1"} 1
+# HELP github_actions_actor_run_duration_seconds Duration of workflow runs completed in the last 7 days by triggering actor
+# TYPE github_actions_actor_run_duration_seconds gauge
+github_actions_actor_run_duration_seconds{actor="bob",actor_type="User",repository="fake/fake1"} 180
+github_actions_actor_run_duration_seconds{actor="other",actor_type="Bot",repository="fake/fake1"} 10
+github_actions_actor_run_duration_seconds{actor="other",actor_type="User",repository="fake/fake1"} 30
+`,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		actorTopN := tt.actorTopN
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			scraped := false
+			receiver := collector.NewRunsCollector(
+				[]string{"fake/fake1"},
+				&backendMock{
+					fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+						if !scraped {
+							return map[string][]collector.WorkflowRun{"fake/fake1": runs[:1]}, nil
+						}
+						return map[string][]collector.WorkflowRun{"fake/fake1": runs}, nil
+					},
+					fakeFetchRunsCounts: func(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+						return map[string]map[string]int{"fake/fake1": {}}, nil
+					},
+				},
+				&storeMock{
+					m: make(map[string][]byte),
+				},
+				nil,
+				nil,
+				actorTopN,
+				loggerMock{
+					fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+						t.Errorf("%s: %v", msg, keysAndValues)
+					},
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				nil,
+			)
+			if err := receiver.Scrape(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			scraped = true
+			if err := receiver.Scrape(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+				"github_actions_triggered_runs_total",
+				"github_actions_triggered_run_duration_seconds_total",
+				"github_actions_actor_runs",
+				"github_actions_actor_run_duration_seconds",
+			); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRunsCollectorScrapeTriggeredRunsWithoutActors(t *testing.T) {
+	createdAt := time.Now().Add(-time.Hour).UTC()
+	// Runs fetched by the GraphQL backend have no start time and no actors.
+	runs := []collector.WorkflowRun{
+		{ID: 1, Status: "completed", Conclusion: "success", Event: "push", HeadSHA: "fake"},
+		{
+			ID:         2,
+			Status:     "completed",
+			Conclusion: "success",
+			Event:      "push",
+			HeadSHA:    "fake",
+			CreatedAt:  createdAt.Format(time.RFC3339),
+			UpdatedAt:  createdAt.Add(time.Minute).Format(time.RFC3339),
+		},
+	}
+
+	scraped := false
+	receiver := collector.NewRunsCollector(
+		[]string{"fake/fake1"},
+		&backendMock{
+			fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+				if !scraped {
+					return map[string][]collector.WorkflowRun{"fake/fake1": runs[:1]}, nil
+				}
+				return map[string][]collector.WorkflowRun{"fake/fake1": runs}, nil
+			},
+			fakeFetchRunsCounts: func(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+				return map[string]map[string]int{"fake/fake1": {}}, nil
+			},
+		},
+		&storeMock{
+			m: make(map[string][]byte),
+		},
+		nil,
+		nil,
+		10,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+				t.Errorf("%s: %v", msg, keysAndValues)
+			},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		nil,
+	)
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	scraped = true
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(""),
+		"github_actions_triggered_runs_total",
+		"github_actions_triggered_run_duration_seconds_total",
+		"github_actions_actor_runs",
+		"github_actions_actor_run_duration_seconds",
+	); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunsCollectorScrapeActorsAcrossRepositories(t *testing.T) {
+	updatedAt := time.Now().Add(-time.Hour).UTC()
+	scraped := false
+	receiver := collector.NewRunsCollector(
+		[]string{"fake/fake1", "fake/fake2"},
+		&backendMock{
+			fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+				if !scraped {
+					return map[string][]collector.WorkflowRun{
+						"fake/fake1": {{ID: 1, Status: "completed"}},
+						"fake/fake2": {{ID: 1, Status: "completed"}},
+					}, nil
+				}
+				return map[string][]collector.WorkflowRun{
+					"fake/fake1": {
+						{ID: 3, Status: "completed", UpdatedAt: updatedAt.Format(time.RFC3339), Actor: &collector.WorkflowRunActor{Login: "alice", Type: "User"}},
+						{ID: 2, Status: "completed", UpdatedAt: updatedAt.Format(time.RFC3339), Actor: &collector.WorkflowRunActor{Login: "alice", Type: "User"}},
+					},
+					"fake/fake2": {
+						{ID: 5, Status: "completed", UpdatedAt: updatedAt.Format(time.RFC3339), Actor: &collector.WorkflowRunActor{Login: "bob", Type: "User"}},
+						{ID: 4, Status: "completed", UpdatedAt: updatedAt.Format(time.RFC3339), Actor: &collector.WorkflowRunActor{Login: "bob", Type: "User"}},
+						{ID: 3, Status: "completed", UpdatedAt: updatedAt.Format(time.RFC3339), Actor: &collector.WorkflowRunActor{Login: "bob", Type: "User"}},
+						{ID: 2, Status: "completed", UpdatedAt: updatedAt.Add(-8 * 24 * time.Hour).Format(time.RFC3339), Actor: &collector.WorkflowRunActor{Login: "carol", Type: "User"}},
+					},
+				}, nil
+			},
+			fakeFetchRunsCounts: func(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+				return map[string]map[string]int{"fake/fake1": {}, "fake/fake2": {}}, nil
+			},
+		},
+		&storeMock{
+			m: make(map[string][]byte),
+		},
+		nil,
+		nil,
+		1,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+				t.Errorf("%s: %v", msg, keysAndValues)
+			},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		nil,
+	)
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	scraped = true
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+# HELP github_actions_actor_runs Number of workflow runs completed in the last 7 days by triggering actor
+# TYPE github_actions_actor_runs gauge
+github_actions_actor_runs{actor="bob",actor_type="User",repository="fake/fake2"} 3
+github_actions_actor_runs{actor="other",actor_type="User",repository="fake/fake1"} 2
+`
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want), "github_actions_actor_runs"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunsCollectorScrapePartialFailure(t *testing.T) {
+	failing := false
+	receiver := collector.NewRunsCollector(
+		[]string{"fake/fake1", "fake/fake2"},
+		&backendMock{
+			fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+				return map[string][]collector.WorkflowRun{"fake/fake1": {}}, map[string]error{"fake/fake2": errors.New("fake")}
+			},
+			fakeFetchRunsCounts: func(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+				if !failing {
+					return map[string]map[string]int{"fake/fake1": {"queued": 3}, "fake/fake2": {"queued": 2}}, nil
+				}
+				return map[string]map[string]int{"fake/fake1": {"queued": 1}}, map[string]error{"fake/fake2": errors.New("fake")}
+			},
+		},
+		&storeMock{
+			m: make(map[string][]byte),
+		},
+		nil,
+		nil,
+		0,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		nil,
+	)
+	if err := receiver.Scrape(context.Background()); err == nil {
+		t.Fatal("want error, but got nil")
+	}
+	failing = true
+	err := receiver.Scrape(context.Background())
+	var repositoriesErr *collector.RepositoriesError
+	if !xerrors.As(err, &repositoriesErr) {
+		t.Fatalf("want RepositoriesError, but got %v", err)
+	}
+	if diff := cmp.Diff([]string{"fake/fake2"}, repositoriesErr.Repositories); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+	want := `
+# HELP github_actions_runs List how many workflow runs each repository actions
+# TYPE github_actions_runs gauge
+github_actions_runs{repository="fake/fake1",status="queued"} 1
+github_actions_runs{repository="fake/fake2",status="queued"} 2
+`
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want), "github_actions_runs"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunsCollectorScrapeCompletedRuns(t *testing.T) {
+	tests := []struct {
+		name      string
+		scrapes   [][]collector.WorkflowRun
+		restartAt int
+		wantSince []uint64
+		wantState collector.RunsState
+		want      string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[][]collector.WorkflowRun{
+				{
+					{ID: 3, Status: "in_progress"},
+					{ID: 2, Status: "completed", Conclusion: "success"},
+					{ID: 1, Status: "completed", Conclusion: "success"},
+				},
+				{
+					{ID: 5, Status: "completed", Conclusion: "failure"},
+					{ID: 4, Status: "in_progress"},
+					{ID: 3, Status: "completed", Conclusion: "success"},
+				},
+				{
+					{ID: 6, Status: "queued"},
+					{ID: 5, Status: "completed", Conclusion: "failure"},
+					{ID: 4, Status: "completed", Conclusion: "success"},
+				},
+			},
+			-1,
+			[]uint64{0, 3, 4},
+			collector.RunsState{
+				RunsWatermark: collector.RunsWatermark{
+					Watermark: 6,
+					Counted:   []uint64{},
+				},
+			},
+			`
+# HELP github_actions_completed_runs_total Total number of completed workflow runs
+# TYPE github_actions_completed_runs_total counter
+github_actions_completed_runs_total{conclusion="failure",repository="fake/fake1",workflow_id="0"} 1
+github_actions_completed_runs_total{conclusion="success",repository="fake/fake1",workflow_id="0"} 2
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[][]collector.WorkflowRun{
+				{
+					{ID: 1, Status: "completed", Conclusion: "success"},
+				},
+				{
+					{ID: 4, Status: "completed", Conclusion: "success"},
+					{ID: 3, Status: "in_progress"},
+					{ID: 2, Status: "completed", Conclusion: "failure"},
+				},
+				{
+					{ID: 4, Status: "completed", Conclusion: "success"},
+					{ID: 3, Status: "completed", Conclusion: "cancelled"},
+				},
+			},
+			2,
+			[]uint64{0, 2, 3},
+			collector.RunsState{
+				RunsWatermark: collector.RunsWatermark{
+					Watermark: 5,
+					Counted:   []uint64{},
+				},
+			},
+			`
+# HELP github_actions_completed_runs_total Total number of completed workflow runs
+# TYPE github_actions_completed_runs_total counter
+github_actions_completed_runs_total{conclusion="cancelled",repository="fake/fake1",workflow_id="0"} 1
+github_actions_completed_runs_total{conclusion="failure",repository="fake/fake1",workflow_id="0"} 1
+github_actions_completed_runs_total{conclusion="success",repository="fake/fake1",workflow_id="0"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		scrapes := tt.scrapes
+		restartAt := tt.restartAt
+		wantSince := tt.wantSince
+		wantState := tt.wantState
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &storeMock{
+				m: make(map[string][]byte),
+			}
+			var gotSince []uint64
+			i := 0
+			newReceiver := func() *collector.RunsCollector {
+				return collector.NewRunsCollector(
+					[]string{"fake/fake1"},
+					&backendMock{
+						fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+							gotSince = append(gotSince, since["fake/fake1"])
+							return map[string][]collector.WorkflowRun{"fake/fake1": scrapes[i]}, nil
+						},
+						fakeFetchRunsCounts: func(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+							return map[string]map[string]int{"fake/fake1": {}}, nil
+						},
+					},
+					store,
+					nil,
+					nil,
+					0,
+					loggerMock{
+						fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+							t.Errorf("%s: %v", msg, keysAndValues)
+						},
+						fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+					},
+					nil,
+				)
+			}
+			receiver := newReceiver()
+			for i = range scrapes {
+				if i == restartAt {
+					receiver = newReceiver()
+				}
+				if err := receiver.Scrape(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if diff := cmp.Diff(wantSince, gotSince); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+			var gotState collector.RunsState
+			if err := json.Unmarshal(store.m["runs/fake/fake1"], &gotState); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(wantState, gotState, cmpopts.IgnoreFields(collector.RunsState{}, "CompletedRuns", "Events", "Actors")); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+			if err := testutil.CollectAndCompare(receiver, strings.NewReader(want), "github_actions_completed_runs_total"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRunsCollectorStartLoopWaitsForPreviousLoop(t *testing.T) {
+	var running int32
+	fetching := make(chan struct{}, 2)
+	release := make(chan struct{})
+	receiver := collector.NewRunsCollector(
+		[]string{"fake/fake1"},
+		&backendMock{
+			fakeFetchRuns: func(ctx context.Context, repositories []string, since map[string]uint64) (map[string][]collector.WorkflowRun, map[string]error) {
+				if atomic.AddInt32(&running, 1) > 1 {
+					t.Error("scraped concurrently")
+				}
+				defer atomic.AddInt32(&running, -1)
+				fetching <- struct{}{}
+				<-release
+				return map[string][]collector.WorkflowRun{"fake/fake1": {}}, nil
+			},
+			fakeFetchRunsCounts: func(ctx context.Context, repositories []string) (map[string]map[string]int, map[string]error) {
+				return map[string]map[string]int{"fake/fake1": {}}, nil
+			},
+		},
+		&storeMock{
+			m: make(map[string][]byte),
+		},
+		nil,
+		nil,
+		0,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+				t.Errorf("%s: %v", msg, keysAndValues)
+			},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		nil,
+	)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	receiver.StartLoop(ctx1, time.Hour)
+	<-fetching
+	cancel1()
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	restarted := make(chan struct{})
+	go func() {
+		receiver.StartLoop(ctx2, time.Hour)
+		close(restarted)
+	}()
+	select {
+	case <-restarted:
+		t.Fatal("restarted before the previous loop exited")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	<-restarted
+	<-fetching
+}
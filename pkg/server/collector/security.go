@@ -0,0 +1,386 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+	"golang.org/x/xerrors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	secretsLabelNames            = []string{"repository", "scope"}
+	secretAgeLabelNames          = []string{"repository", "scope", "name"}
+	actionsPermissionsLabelNames = []string{"repository", "enabled", "allowed_actions", "default_workflow_permissions", "can_approve_pull_request_reviews", "fork_pr_approval_policy"}
+)
+
+var (
+	secretsPerPage = 100
+	secretScopes   = []struct {
+		name   string
+		prefix string
+	}{
+		{"repository", ""},
+		{"organization", "organization-"},
+	}
+)
+
+type ActionsSecret struct {
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type ActionsSecretsResponse struct {
+	TotalCount *int            `json:"total_count,omitempty"`
+	Secrets    []ActionsSecret `json:"secrets,omitempty"`
+	Variables  []ActionsSecret `json:"variables,omitempty"`
+}
+
+type ActionsPermissions struct {
+	Enabled                      bool   `json:"enabled"`
+	AllowedActions               string `json:"allowed_actions"`
+	DefaultWorkflowPermissions   string `json:"default_workflow_permissions"`
+	CanApprovePullRequestReviews bool   `json:"can_approve_pull_request_reviews"`
+	ApprovalPolicy               string `json:"approval_policy"`
+}
+
+// repositorySecurity holds the secrets and variables of a repository keyed by
+// kind and scope, and its Actions permissions.
+type repositorySecurity struct {
+	secrets     map[string][]ActionsSecret
+	permissions ActionsPermissions
+}
+
+type SecurityCollector struct {
+	repositories       []string
+	status             *StatusRecorder
+	policy             *LabelPolicy
+	logger             ILogger
+	httpClient         IHTTPClient
+	loop               loop
+	mutex              sync.RWMutex
+	secrets            *prometheus.GaugeVec
+	secretAge          *prometheus.GaugeVec
+	variables          *prometheus.GaugeVec
+	variableAge        *prometheus.GaugeVec
+	actionsPermissions *prometheus.GaugeVec
+	lastSecurity       map[string]*repositorySecurity
+}
+
+func NewSecurityCollector(
+	repositories []string,
+	status *StatusRecorder,
+	policy *LabelPolicy,
+	logger ILogger,
+	httpClient IHTTPClient,
+) *SecurityCollector {
+	return &SecurityCollector{
+		repositories:       repositories,
+		status:             status,
+		policy:             policy,
+		logger:             logger,
+		httpClient:         httpClient,
+		secrets:            newSecretsGaugeVec(),
+		secretAge:          newSecretAgeGaugeVec(),
+		variables:          newVariablesGaugeVec(),
+		variableAge:        newVariableAgeGaugeVec(),
+		actionsPermissions: newActionsPermissionsGaugeVec(),
+		lastSecurity:       make(map[string]*repositorySecurity),
+	}
+}
+
+func newSecretsGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "secrets",
+		Help:      "Number of Actions secrets available to each repository",
+	}, secretsLabelNames)
+}
+
+func newSecretAgeGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "secret_age_seconds",
+		Help:      "Time since each Actions secret was last updated",
+	}, secretAgeLabelNames)
+}
+
+func newVariablesGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "variables",
+		Help:      "Number of Actions variables available to each repository",
+	}, secretsLabelNames)
+}
+
+func newVariableAgeGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "variable_age_seconds",
+		Help:      "Time since each Actions variable was last updated",
+	}, secretAgeLabelNames)
+}
+
+func newActionsPermissionsGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "permissions_info",
+		Help:      "Actions permissions of each repository",
+	}, actionsPermissionsLabelNames)
+}
+
+func (c *SecurityCollector) fetchSecrets(ctx context.Context, repository string, path string, page int) ([]ActionsSecret, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s%s?per_page=%d&page=%d", repository, path, secretsPerPage, page), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, "/repos/{repository}"+path)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var secretsResponse ActionsSecretsResponse
+	if err := json.Unmarshal(body, &secretsResponse); err != nil {
+		return nil, xerrors.Errorf("failed to parse response: %w", err)
+	}
+	if secretsResponse.TotalCount == nil {
+		return nil, xerrors.Errorf("bad response: %s", string(body))
+	}
+	secrets := append(secretsResponse.Secrets, secretsResponse.Variables...)
+
+	if *secretsResponse.TotalCount > secretsPerPage*page {
+		s, err := c.fetchSecrets(ctx, repository, path, page+1)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to execute fetchSecrets: %w", err)
+		}
+		secrets = append(secrets, s...)
+	}
+
+	return secrets, nil
+}
+
+func (c *SecurityCollector) fetchActionsPermissions(ctx context.Context, repository string, path string, optional bool, permissions *ActionsPermissions) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s%s", repository, path), nil)
+	if err != nil {
+		return xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, "/repos/{repository}"+path)
+	if err != nil {
+		return xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return xerrors.Errorf("failed to read response: %w", err)
+	}
+	// Optional endpoints are unavailable to some tokens and plans, in which
+	// case their fields are left empty instead of failing the repository.
+	if optional && (response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusUnprocessableEntity) {
+		return nil
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return xerrors.Errorf("bad response: %s", string(body))
+	}
+	if err := json.Unmarshal(body, permissions); err != nil {
+		return xerrors.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+func (c *SecurityCollector) Scrape(ctx context.Context) error {
+	ctx = withCollector(ctx, "security")
+	ctx, span := trace.StartSpan(ctx, "SecurityCollector.Scrape")
+	defer span.End()
+	span.AddAttributes(trace.Int64Attribute("github.repositories", int64(len(c.repositories))))
+
+	var failed []string
+	filter := c.policy.NewFilter()
+	secrets := newSecretsGaugeVec()
+	secretAge := newSecretAgeGaugeVec()
+	variables := newVariablesGaugeVec()
+	variableAge := newVariableAgeGaugeVec()
+	actionsPermissions := newActionsPermissionsGaugeVec()
+	c.mutex.RLock()
+	previousSecurity := c.lastSecurity
+	c.mutex.RUnlock()
+	lastSecurity := make(map[string]*repositorySecurity)
+	now := time.Now()
+	for _, repository := range c.repositories {
+		security, err := c.fetchRepositorySecurity(ctx, repository)
+		if err != nil {
+			c.logger.Errorw("Failed to scrape repository",
+				"collector", "security",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+			// Keep the series of the repository with the last fetched secrets
+			// and permissions instead of dropping them until the next
+			// successful fetch.
+			previous, ok := previousSecurity[repository]
+			if !ok {
+				continue
+			}
+			security = previous
+		}
+		lastSecurity[repository] = security
+		scrapeRepositorySecurity(repository, security, secrets, secretAge, variables, variableAge, actionsPermissions, filter, now)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastSecurity = lastSecurity
+	c.secrets = secrets
+	c.secretAge = secretAge
+	c.variables = variables
+	c.variableAge = variableAge
+	c.actionsPermissions = actionsPermissions
+
+	if len(failed) > 0 {
+		return newRepositoriesError("failed to scrape security", failed)
+	}
+	return nil
+}
+
+func (c *SecurityCollector) fetchRepositorySecurity(ctx context.Context, repository string) (*repositorySecurity, error) {
+	security := &repositorySecurity{
+		secrets: make(map[string][]ActionsSecret),
+	}
+	for _, scope := range secretScopes {
+		for _, kind := range []string{"secrets", "variables"} {
+			secrets, err := c.fetchSecrets(ctx, repository, fmt.Sprintf("/actions/%s%s", scope.prefix, kind), 1)
+			if err != nil {
+				return nil, xerrors.Errorf("failed to fetch %s %s: %w", scope.name, kind, err)
+			}
+			security.secrets[kind+"/"+scope.name] = secrets
+		}
+	}
+
+	for _, endpoint := range []struct {
+		path     string
+		optional bool
+	}{
+		{"/actions/permissions", false},
+		{"/actions/permissions/workflow", false},
+		{"/actions/permissions/fork-pr-contributor-approval", true},
+	} {
+		if err := c.fetchActionsPermissions(ctx, repository, endpoint.path, endpoint.optional, &security.permissions); err != nil {
+			return nil, xerrors.Errorf("failed to fetch %s: %w", endpoint.path, err)
+		}
+	}
+	return security, nil
+}
+
+func scrapeRepositorySecurity(
+	repository string,
+	security *repositorySecurity,
+	secretsGaugeVec *prometheus.GaugeVec,
+	secretAgeGaugeVec *prometheus.GaugeVec,
+	variablesGaugeVec *prometheus.GaugeVec,
+	variableAgeGaugeVec *prometheus.GaugeVec,
+	actionsPermissionsGaugeVec *prometheus.GaugeVec,
+	filter *LabelFilter,
+	now time.Time,
+) {
+	for _, scope := range secretScopes {
+		for _, m := range []struct {
+			kind     string
+			count    *prometheus.GaugeVec
+			age      *prometheus.GaugeVec
+			countKey string
+			ageKey   string
+		}{
+			{"secrets", secretsGaugeVec, secretAgeGaugeVec, "github_actions_secrets", "github_actions_secret_age_seconds"},
+			{"variables", variablesGaugeVec, variableAgeGaugeVec, "github_actions_variables", "github_actions_variable_age_seconds"},
+		} {
+			secrets := security.secrets[m.kind+"/"+scope.name]
+			if labels, ok := filter.Apply(m.countKey, secretsLabelNames,
+				repository,
+				scope.name,
+			); ok {
+				m.count.WithLabelValues(labels...).Add(float64(len(secrets)))
+			}
+			for _, secret := range secrets {
+				updatedAt, err := time.Parse(time.RFC3339, secret.UpdatedAt)
+				if err != nil {
+					continue
+				}
+				if labels, ok := filter.Apply(m.ageKey, secretAgeLabelNames,
+					repository,
+					scope.name,
+					secret.Name,
+				); ok {
+					m.age.WithLabelValues(labels...).Set(now.Sub(updatedAt).Seconds())
+				}
+			}
+		}
+	}
+
+	permissions := security.permissions
+	if labels, ok := filter.Apply("github_actions_permissions_info", actionsPermissionsLabelNames,
+		repository,
+		strconv.FormatBool(permissions.Enabled),
+		permissions.AllowedActions,
+		permissions.DefaultWorkflowPermissions,
+		strconv.FormatBool(permissions.CanApprovePullRequestReviews),
+		permissions.ApprovalPolicy,
+	); ok {
+		actionsPermissionsGaugeVec.WithLabelValues(labels...).Set(1)
+	}
+}
+
+func (c *SecurityCollector) scrape(ctx context.Context) {
+	err := c.Scrape(ctx)
+	c.status.Record("security", err)
+	if err != nil {
+		c.logger.Errorw("Failed to scrape",
+			"collector", "security",
+			"error", err.Error(),
+		)
+	}
+}
+
+func (c *SecurityCollector) StartLoop(ctx context.Context, interval time.Duration) {
+	c.status.Start("security", interval)
+	c.loop.start(ctx, interval, nil, c.scrape)
+}
+
+func (c *SecurityCollector) collectors() []prometheus.Collector {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return []prometheus.Collector{
+		c.secrets,
+		c.secretAge,
+		c.variables,
+		c.variableAge,
+		c.actionsPermissions,
+	}
+}
+
+func (c *SecurityCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+func (c *SecurityCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
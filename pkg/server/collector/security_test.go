@@ -0,0 +1,197 @@
+package collector_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSecurityCollectorScrape(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses map[string]string
+		want      string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			map[string]string{
+				"/repos/fake/fake/actions/secrets": `{"total_count": 2, "secrets": [
+  {"name": "FAKE1", "created_at": "2020-01-01T00:00:00Z", "updated_at": "2020-01-01T00:00:00Z"},
+  {"name": "FAKE2", "created_at": "2020-01-01T00:00:00Z", "updated_at": "2020-01-01T00:00:00Z"}
+]}`,
+				"/repos/fake/fake/actions/organization-secrets": `{"total_count": 1, "secrets": [
+  {"name": "FAKE3", "created_at": "2020-01-01T00:00:00Z", "updated_at": "2020-01-01T00:00:00Z"}
+]}`,
+				"/repos/fake/fake/actions/variables": `{"total_count": 1, "variables": [
+  {"name": "FAKE4", "value": "secret", "created_at": "2020-01-01T00:00:00Z", "updated_at": "2020-01-01T00:00:00Z"}
+]}`,
+				"/repos/fake/fake/actions/organization-variables":                   `{"total_count": 0, "variables": []}`,
+				"/repos/fake/fake/actions/permissions":                              `{"enabled": true, "allowed_actions": "selected"}`,
+				"/repos/fake/fake/actions/permissions/workflow":                     `{"default_workflow_permissions": "read", "can_approve_pull_request_reviews": false}`,
+				"/repos/fake/fake/actions/permissions/fork-pr-contributor-approval": `{"approval_policy": "first_time_contributors"}`,
+			},
+			`
+# HELP github_actions_permissions_info Actions permissions of each repository
+# TYPE github_actions_permissions_info gauge
+github_actions_permissions_info{allowed_actions="selected",can_approve_pull_request_reviews="false",default_workflow_permissions="read",enabled="true",fork_pr_approval_policy="first_time_contributors",repository="fake/fake"} 1
+# HELP github_actions_secrets Number of Actions secrets available to each repository
+# TYPE github_actions_secrets gauge
+github_actions_secrets{repository="fake/fake",scope="organization"} 1
+github_actions_secrets{repository="fake/fake",scope="repository"} 2
+# HELP github_actions_variables Number of Actions variables available to each repository
+# TYPE github_actions_variables gauge
+github_actions_variables{repository="fake/fake",scope="organization"} 0
+github_actions_variables{repository="fake/fake",scope="repository"} 1
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			map[string]string{
+				"/repos/fake/fake/actions/secrets":                `{"total_count": 0, "secrets": []}`,
+				"/repos/fake/fake/actions/organization-secrets":   `{"total_count": 0, "secrets": []}`,
+				"/repos/fake/fake/actions/variables":              `{"total_count": 0, "variables": []}`,
+				"/repos/fake/fake/actions/organization-variables": `{"total_count": 0, "variables": []}`,
+				"/repos/fake/fake/actions/permissions":            `{"enabled": false}`,
+				"/repos/fake/fake/actions/permissions/workflow":   `{"default_workflow_permissions": "write", "can_approve_pull_request_reviews": true}`,
+			},
+			`
+# HELP github_actions_permissions_info Actions permissions of each repository
+# TYPE github_actions_permissions_info gauge
+github_actions_permissions_info{allowed_actions="",can_approve_pull_request_reviews="true",default_workflow_permissions="write",enabled="false",fork_pr_approval_policy="",repository="fake/fake"} 1
+# HELP github_actions_secrets Number of Actions secrets available to each repository
+# TYPE github_actions_secrets gauge
+github_actions_secrets{repository="fake/fake",scope="organization"} 0
+github_actions_secrets{repository="fake/fake",scope="repository"} 0
+# HELP github_actions_variables Number of Actions variables available to each repository
+# TYPE github_actions_variables gauge
+github_actions_variables{repository="fake/fake",scope="organization"} 0
+github_actions_variables{repository="fake/fake",scope="repository"} 0
+`,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		responses := tt.responses
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver := collector.NewSecurityCollector(
+				[]string{"fake/fake"},
+				nil,
+				nil,
+				loggerMock{
+					fakeErrorw: func(msg string, keysAndValues ...interface{}) {},
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				httpClientMock{
+					fakeDo: func(request *http.Request) (*http.Response, error) {
+						body, ok := responses[request.URL.Path]
+						if !ok {
+							return &http.Response{
+								StatusCode: http.StatusNotFound,
+								Body:       ioutil.NopCloser(strings.NewReader(`{"message": "Not Found"}`)),
+							}, nil
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(body)),
+						}, nil
+					},
+				},
+			)
+			if err := receiver.Scrape(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+				"github_actions_secrets",
+				"github_actions_variables",
+				"github_actions_permissions_info",
+			); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSecurityCollectorScrapeFailedRepository(t *testing.T) {
+	responses := map[string]string{
+		"/repos/fake/fake/actions/secrets":                `{"total_count": 1, "secrets": [{"name": "FAKE1", "created_at": "2020-01-01T00:00:00Z", "updated_at": "2020-01-01T00:00:00Z"}]}`,
+		"/repos/fake/fake/actions/organization-secrets":   `{"total_count": 0, "secrets": []}`,
+		"/repos/fake/fake/actions/variables":              `{"total_count": 0, "variables": []}`,
+		"/repos/fake/fake/actions/organization-variables": `{"total_count": 0, "variables": []}`,
+		"/repos/fake/fake/actions/permissions":            `{"enabled": true, "allowed_actions": "all"}`,
+		"/repos/fake/fake/actions/permissions/workflow":   `{"default_workflow_permissions": "read", "can_approve_pull_request_reviews": false}`,
+	}
+	want := `
+# HELP github_actions_permissions_info Actions permissions of each repository
+# TYPE github_actions_permissions_info gauge
+github_actions_permissions_info{allowed_actions="all",can_approve_pull_request_reviews="false",default_workflow_permissions="read",enabled="true",fork_pr_approval_policy="",repository="fake/fake"} 1
+# HELP github_actions_secrets Number of Actions secrets available to each repository
+# TYPE github_actions_secrets gauge
+github_actions_secrets{repository="fake/fake",scope="organization"} 0
+github_actions_secrets{repository="fake/fake",scope="repository"} 1
+`
+	fail := false
+	receiver := collector.NewSecurityCollector(
+		[]string{"fake/fake"},
+		nil,
+		nil,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				if fail {
+					return nil, errors.New("fake")
+				}
+				body, ok := responses[request.URL.Path]
+				if !ok {
+					return &http.Response{
+						StatusCode: http.StatusForbidden,
+						Body:       ioutil.NopCloser(strings.NewReader(`{"message": "Resource not accessible by integration"}`)),
+					}, nil
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		},
+	)
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+		"github_actions_secrets",
+		"github_actions_permissions_info",
+	); err != nil {
+		t.Error(err)
+	}
+
+	fail = true
+	if err := receiver.Scrape(context.Background()); err == nil {
+		t.Fatal("want error, but got nil")
+	}
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+		"github_actions_secrets",
+		"github_actions_permissions_info",
+	); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+type CollectorStatus struct {
+	Name               string     `json:"name"`
+	Repositories       []string   `json:"repositories"`
+	IntervalSeconds    float64    `json:"interval_seconds"`
+	LastRunAt          *time.Time `json:"last_run_at,omitempty"`
+	LastSuccessAt      *time.Time `json:"last_success_at,omitempty"`
+	LastErrorAt        *time.Time `json:"last_error_at,omitempty"`
+	LastError          string     `json:"last_error,omitempty"`
+	FailedRepositories []string   `json:"failed_repositories,omitempty"`
+	NextRunAt          *time.Time `json:"next_run_at,omitempty"`
+}
+
+// RepositoriesError is an error of a scrape which failed only for some
+// repositories, so that the others still count as a success.
+type RepositoriesError struct {
+	Message      string
+	Repositories []string
+}
+
+func newRepositoriesError(message string, repositories []string) *RepositoriesError {
+	sort.Strings(repositories)
+	return &RepositoriesError{
+		Message:      message,
+		Repositories: repositories,
+	}
+}
+
+func (e *RepositoriesError) Error() string {
+	return fmt.Sprintf("%s of %s", e.Message, strings.Join(e.Repositories, ", "))
+}
+
+type StatusRecorder struct {
+	mutex    sync.RWMutex
+	statuses map[string]*CollectorStatus
+}
+
+func NewStatusRecorder() *StatusRecorder {
+	return &StatusRecorder{
+		statuses: make(map[string]*CollectorStatus),
+	}
+}
+
+func (r *StatusRecorder) Register(name string, repositories []string) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.statuses[name]; !ok {
+		r.statuses[name] = &CollectorStatus{
+			Name:         name,
+			Repositories: repositories,
+		}
+	}
+}
+
+func (r *StatusRecorder) Start(name string, interval time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	status := r.status(name)
+	status.IntervalSeconds = interval.Seconds()
+	status.NextRunAt = &now
+}
+
+func (r *StatusRecorder) Record(name string, err error) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	status := r.status(name)
+	status.LastRunAt = &now
+	status.FailedRepositories = nil
+	if err != nil {
+		status.LastErrorAt = &now
+		status.LastError = err.Error()
+		var repositoriesErr *RepositoriesError
+		if xerrors.As(err, &repositoriesErr) {
+			status.FailedRepositories = repositoriesErr.Repositories
+		}
+	}
+	if err == nil || (status.FailedRepositories != nil && len(status.FailedRepositories) < len(status.Repositories)) {
+		status.LastSuccessAt = &now
+	}
+	if status.IntervalSeconds > 0 {
+		nextRunAt := now.Add(time.Duration(status.IntervalSeconds * float64(time.Second)))
+		status.NextRunAt = &nextRunAt
+	}
+}
+
+func (r *StatusRecorder) Ready() error {
+	if r == nil {
+		return nil
+	}
+	var pending []string
+	for _, status := range r.Statuses() {
+		if status.LastSuccessAt == nil {
+			pending = append(pending, status.Name)
+		}
+	}
+	if len(pending) > 0 {
+		return xerrors.Errorf("collectors have not succeeded yet: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+func (r *StatusRecorder) Statuses() []CollectorStatus {
+	if r == nil {
+		return nil
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	statuses := make([]CollectorStatus, 0, len(r.statuses))
+	for _, status := range r.statuses {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func (r *StatusRecorder) status(name string) *CollectorStatus {
+	status, ok := r.statuses[name]
+	if !ok {
+		status = &CollectorStatus{
+			Name: name,
+		}
+		r.statuses[name] = status
+	}
+	return status
+}
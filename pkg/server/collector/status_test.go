@@ -0,0 +1,146 @@
+package collector_test
+
+import (
+	"errors"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	tests := []struct {
+		name string
+		in   func(r *collector.StatusRecorder)
+		want []collector.CollectorStatus
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			func(r *collector.StatusRecorder) {
+				r.Register("runs", []string{"fake/fake"})
+				r.Register("runners", []string{"fake/fake"})
+				r.Start("runs", 5*time.Minute)
+				r.Record("runs", nil)
+				r.Record("runs", errors.New("fake"))
+			},
+			[]collector.CollectorStatus{
+				{
+					Name:         "runners",
+					Repositories: []string{"fake/fake"},
+				},
+				{
+					Name:            "runs",
+					Repositories:    []string{"fake/fake"},
+					IntervalSeconds: 300,
+					LastError:       "fake",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		in := tt.in
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver := collector.NewStatusRecorder()
+			in(receiver)
+			got := receiver.Statuses()
+			if diff := cmp.Diff(want, got, cmpopts.IgnoreTypes(&time.Time{})); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+			for _, status := range got {
+				if status.LastError != "" && (status.LastSuccessAt == nil || status.LastErrorAt == nil || !status.NextRunAt.After(*status.LastErrorAt)) {
+					t.Errorf("unexpected times: %+v", status)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusRecorderReady(t *testing.T) {
+	tests := []struct {
+		name            string
+		in              func(r *collector.StatusRecorder)
+		wantErrorString string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			func(r *collector.StatusRecorder) {
+				r.Register("runs", []string{"fake/fake"})
+				r.Register("runners", []string{"fake/fake"})
+				r.Record("runs", errors.New("fake"))
+			},
+			"collectors have not succeeded yet: runners, runs",
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			func(r *collector.StatusRecorder) {
+				r.Register("runs", []string{"fake/fake"})
+				r.Register("runners", []string{"fake/fake"})
+				r.Record("runs", errors.New("fake"))
+				r.Record("runs", nil)
+				r.Record("runners", nil)
+				r.Record("runners", errors.New("fake"))
+			},
+			"",
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			func(r *collector.StatusRecorder) {
+				r.Register("runs", []string{"fake/fake1", "fake/fake2"})
+				r.Record("runs", &collector.RepositoriesError{Message: "fake", Repositories: []string{"fake/fake2"}})
+			},
+			"",
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			func(r *collector.StatusRecorder) {
+				r.Register("runs", []string{"fake/fake1", "fake/fake2"})
+				r.Record("runs", &collector.RepositoriesError{Message: "fake", Repositories: []string{"fake/fake1", "fake/fake2"}})
+			},
+			"collectors have not succeeded yet: runs",
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		in := tt.in
+		wantErrorString := tt.wantErrorString
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver := collector.NewStatusRecorder()
+			in(receiver)
+			err := receiver.Ready()
+			if wantErrorString == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != wantErrorString {
+				t.Errorf("want %q, but got %v", wantErrorString, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+type collectorContextKey struct{}
+
+func withCollector(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, collectorContextKey{}, name)
+}
+
+func collectorFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(collectorContextKey{}).(string)
+	return name
+}
+
+func doRequest(ctx context.Context, httpClient IHTTPClient, logger ILogger, request *http.Request, repository string, endpoint string) (*http.Response, error) {
+	ctx, span := trace.StartSpan(ctx, request.Method+" "+endpoint, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.AddAttributes(
+		trace.StringAttribute("github.repository", repository),
+		trace.StringAttribute("github.endpoint", endpoint),
+	)
+
+	start := time.Now()
+	response, err := httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+		logger.Errorw("GitHub API request failed",
+			"collector", collectorFromContext(ctx),
+			"repository", repository,
+			"endpoint", endpoint,
+			"error", err.Error(),
+		)
+		return nil, err
+	}
+	span.AddAttributes(trace.Int64Attribute(ochttp.StatusCodeAttribute, int64(response.StatusCode)))
+	span.SetStatus(ochttp.TraceStatus(response.StatusCode, response.Status))
+
+	keysAndValues := []interface{}{
+		"collector", collectorFromContext(ctx),
+		"repository", repository,
+		"endpoint", endpoint,
+		"status_code", response.StatusCode,
+		"duration_seconds", time.Since(start).Seconds(),
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		logger.Errorw("GitHub API request returned error", keysAndValues...)
+	} else {
+		logger.Debugw("GitHub API request", keysAndValues...)
+	}
+	return response, nil
+}
@@ -0,0 +1,415 @@
+package collector
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	runsOnLabelNames              = []string{"repository", "path", "job", "runs_on"}
+	unpinnedActionLabelNames      = []string{"repository", "path", "job", "action"}
+	missingTimeoutLabelNames      = []string{"repository", "path", "job"}
+	writeAllPermissionsLabelNames = []string{"repository", "path", "job"}
+)
+
+var (
+	workflowFilesPrefix = ".github/workflows/"
+	firstPartyOwners    = []string{"actions", "github"}
+	pinnedRefPattern    = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+type WorkflowFile struct {
+	Permissions interface{}                `yaml:"permissions"`
+	Jobs        map[string]WorkflowFileJob `yaml:"jobs"`
+}
+
+type WorkflowFileJob struct {
+	RunsOn         interface{} `yaml:"runs-on"`
+	Uses           string      `yaml:"uses"`
+	TimeoutMinutes interface{} `yaml:"timeout-minutes"`
+	Permissions    interface{} `yaml:"permissions"`
+	Steps          []struct {
+		Uses string `yaml:"uses"`
+	} `yaml:"steps"`
+}
+
+func (j WorkflowFileJob) runsOn() string {
+	var labels []string
+	switch v := j.RunsOn.(type) {
+	case string:
+		labels = append(labels, v)
+	case []interface{}:
+		for _, label := range v {
+			labels = append(labels, fmt.Sprint(label))
+		}
+	case map[interface{}]interface{}:
+		if group, ok := v["group"]; ok {
+			labels = append(labels, fmt.Sprintf("group:%v", group))
+		}
+		switch l := v["labels"].(type) {
+		case string:
+			labels = append(labels, l)
+		case []interface{}:
+			for _, label := range l {
+				labels = append(labels, fmt.Sprint(label))
+			}
+		}
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, ",")
+}
+
+func isUnpinnedThirdPartyAction(repository string, uses string) bool {
+	if uses == "" || strings.HasPrefix(uses, "./") || strings.HasPrefix(uses, "docker://") {
+		return false
+	}
+	s := strings.SplitN(uses, "@", 2)
+	owner := strings.SplitN(s[0], "/", 2)[0]
+	if owner == strings.SplitN(repository, "/", 2)[0] {
+		return false
+	}
+	for _, firstPartyOwner := range firstPartyOwners {
+		if owner == firstPartyOwner {
+			return false
+		}
+	}
+	return len(s) != 2 || !pinnedRefPattern.MatchString(s[1])
+}
+
+func isWriteAll(permissions interface{}) bool {
+	s, ok := permissions.(string)
+	return ok && s == "write-all"
+}
+
+type WorkflowFilesCollector struct {
+	repositories        []string
+	backend             IBackend
+	status              *StatusRecorder
+	policy              *LabelPolicy
+	logger              ILogger
+	httpClient          IHTTPClient
+	loop                loop
+	mutex               sync.RWMutex
+	runsOn              *prometheus.GaugeVec
+	unpinnedActions     *prometheus.GaugeVec
+	missingTimeout      *prometheus.GaugeVec
+	writeAllPermissions *prometheus.GaugeVec
+	lastWorkflowFiles   map[string]map[string]*WorkflowFile
+}
+
+func NewWorkflowFilesCollector(
+	repositories []string,
+	backend IBackend,
+	status *StatusRecorder,
+	policy *LabelPolicy,
+	logger ILogger,
+	httpClient IHTTPClient,
+) *WorkflowFilesCollector {
+	return &WorkflowFilesCollector{
+		repositories:        repositories,
+		backend:             backend,
+		status:              status,
+		policy:              policy,
+		logger:              logger,
+		httpClient:          httpClient,
+		runsOn:              newRunsOnGaugeVec(),
+		unpinnedActions:     newUnpinnedActionsGaugeVec(),
+		missingTimeout:      newMissingTimeoutGaugeVec(),
+		writeAllPermissions: newWriteAllPermissionsGaugeVec(),
+		lastWorkflowFiles:   make(map[string]map[string]*WorkflowFile),
+	}
+}
+
+func newRunsOnGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workflow_job_runs_on_info",
+		Help:      "Runner labels of each job in workflow files",
+	}, runsOnLabelNames)
+}
+
+func newUnpinnedActionsGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workflow_unpinned_action_info",
+		Help:      "Third-party actions not pinned to a commit SHA in workflow files",
+	}, unpinnedActionLabelNames)
+}
+
+func newMissingTimeoutGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workflow_job_missing_timeout_info",
+		Help:      "Jobs without timeout-minutes in workflow files",
+	}, missingTimeoutLabelNames)
+}
+
+func newWriteAllPermissionsGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workflow_write_all_permissions_info",
+		Help:      "Workflows and jobs with permissions: write-all in workflow files",
+	}, writeAllPermissionsLabelNames)
+}
+
+func (c *WorkflowFilesCollector) fetchWorkflowFile(ctx context.Context, repository string, path string) (*WorkflowFile, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/contents/%s", repository, path), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request object: %w", err)
+	}
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, "/repos/{repository}/contents/{path}")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read response: %w", err)
+	}
+
+	var contentResponse struct {
+		Content  *string `json:"content"`
+		Encoding string  `json:"encoding"`
+	}
+	if err := json.Unmarshal(body, &contentResponse); err != nil {
+		return nil, xerrors.Errorf("failed to parse response: %w", err)
+	}
+	if contentResponse.Content == nil || contentResponse.Encoding != "base64" {
+		return nil, xerrors.Errorf("bad response: %s", string(body))
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(*contentResponse.Content, "\n", ""))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode content: %w", err)
+	}
+
+	var workflowFile WorkflowFile
+	if err := yaml.Unmarshal(content, &workflowFile); err != nil {
+		return nil, xerrors.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &workflowFile, nil
+}
+
+func (c *WorkflowFilesCollector) Scrape(ctx context.Context) error {
+	ctx = withCollector(ctx, "workflow_files")
+	ctx, span := trace.StartSpan(ctx, "WorkflowFilesCollector.Scrape")
+	defer span.End()
+	span.AddAttributes(trace.Int64Attribute("github.repositories", int64(len(c.repositories))))
+
+	var failed []string
+	c.mutex.RLock()
+	previousWorkflowFiles := c.lastWorkflowFiles
+	c.mutex.RUnlock()
+	lastWorkflowFiles := make(map[string]map[string]*WorkflowFile)
+	workflows, errs := c.backend.FetchWorkflows(ctx, c.repositories)
+	for repository, err := range errs {
+		c.logger.Errorw("Failed to fetch workflows",
+			"collector", "workflow_files",
+			"repository", repository,
+			"error", err.Error(),
+		)
+		failed = append(failed, repository)
+		// Keep the series of the repository with the last fetched workflow
+		// files instead of dropping them until the next successful fetch.
+		if files, ok := previousWorkflowFiles[repository]; ok {
+			lastWorkflowFiles[repository] = files
+		}
+	}
+	for repository, w := range workflows {
+		files, err := c.fetchRepositoryWorkflowFiles(ctx, repository, w, previousWorkflowFiles[repository])
+		if err != nil {
+			c.logger.Errorw("Failed to scrape repository",
+				"collector", "workflow_files",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			failed = append(failed, repository)
+		}
+		lastWorkflowFiles[repository] = files
+	}
+	filter := c.policy.NewFilter()
+	runsOn := newRunsOnGaugeVec()
+	unpinnedActions := newUnpinnedActionsGaugeVec()
+	missingTimeout := newMissingTimeoutGaugeVec()
+	writeAllPermissions := newWriteAllPermissionsGaugeVec()
+	for repository, files := range lastWorkflowFiles {
+		scrapeRepositoryWorkflowFiles(repository, files, runsOn, unpinnedActions, missingTimeout, writeAllPermissions, filter)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastWorkflowFiles = lastWorkflowFiles
+	c.runsOn = runsOn
+	c.unpinnedActions = unpinnedActions
+	c.missingTimeout = missingTimeout
+	c.writeAllPermissions = writeAllPermissions
+
+	if len(failed) > 0 {
+		return newRepositoriesError("failed to scrape workflow files", failed)
+	}
+	return nil
+}
+
+// fetchRepositoryWorkflowFiles fetches the workflow files of the repository
+// keyed by path, falling back to the previous file of each path which failed
+// to be fetched.
+func (c *WorkflowFilesCollector) fetchRepositoryWorkflowFiles(
+	ctx context.Context,
+	repository string,
+	workflows []Workflow,
+	previous map[string]*WorkflowFile,
+) (map[string]*WorkflowFile, error) {
+	var failed []string
+	files := make(map[string]*WorkflowFile)
+	for _, workflow := range workflows {
+		if !strings.HasPrefix(workflow.Path, workflowFilesPrefix) {
+			continue
+		}
+		workflowFile, err := c.fetchWorkflowFile(ctx, repository, workflow.Path)
+		if err != nil {
+			c.logger.Errorw("Failed to fetch workflow file",
+				"collector", "workflow_files",
+				"repository", repository,
+				"path", workflow.Path,
+				"error", err.Error(),
+			)
+			failed = append(failed, workflow.Path)
+			p, ok := previous[workflow.Path]
+			if !ok {
+				continue
+			}
+			workflowFile = p
+		}
+		files[workflow.Path] = workflowFile
+	}
+
+	if len(failed) > 0 {
+		return files, xerrors.Errorf("failed to fetch workflow files %s", strings.Join(failed, ", "))
+	}
+	return files, nil
+}
+
+func scrapeRepositoryWorkflowFiles(
+	repository string,
+	files map[string]*WorkflowFile,
+	runsOnGaugeVec *prometheus.GaugeVec,
+	unpinnedActionsGaugeVec *prometheus.GaugeVec,
+	missingTimeoutGaugeVec *prometheus.GaugeVec,
+	writeAllPermissionsGaugeVec *prometheus.GaugeVec,
+	filter *LabelFilter,
+) {
+	for path, workflowFile := range files {
+		if isWriteAll(workflowFile.Permissions) {
+			if labels, ok := filter.Apply("github_actions_workflow_write_all_permissions_info", writeAllPermissionsLabelNames,
+				repository,
+				path,
+				"",
+			); ok {
+				writeAllPermissionsGaugeVec.WithLabelValues(labels...).Set(1)
+			}
+		}
+		for name, job := range workflowFile.Jobs {
+			if isWriteAll(job.Permissions) {
+				if labels, ok := filter.Apply("github_actions_workflow_write_all_permissions_info", writeAllPermissionsLabelNames,
+					repository,
+					path,
+					name,
+				); ok {
+					writeAllPermissionsGaugeVec.WithLabelValues(labels...).Set(1)
+				}
+			}
+
+			uses := []string{job.Uses}
+			if job.Uses == "" {
+				if labels, ok := filter.Apply("github_actions_workflow_job_runs_on_info", runsOnLabelNames,
+					repository,
+					path,
+					name,
+					job.runsOn(),
+				); ok {
+					runsOnGaugeVec.WithLabelValues(labels...).Set(1)
+				}
+				if job.TimeoutMinutes == nil {
+					if labels, ok := filter.Apply("github_actions_workflow_job_missing_timeout_info", missingTimeoutLabelNames,
+						repository,
+						path,
+						name,
+					); ok {
+						missingTimeoutGaugeVec.WithLabelValues(labels...).Set(1)
+					}
+				}
+				uses = nil
+				for _, step := range job.Steps {
+					uses = append(uses, step.Uses)
+				}
+			}
+			for _, action := range uses {
+				if !isUnpinnedThirdPartyAction(repository, action) {
+					continue
+				}
+				if labels, ok := filter.Apply("github_actions_workflow_unpinned_action_info", unpinnedActionLabelNames,
+					repository,
+					path,
+					name,
+					action,
+				); ok {
+					unpinnedActionsGaugeVec.WithLabelValues(labels...).Set(1)
+				}
+			}
+		}
+	}
+}
+
+func (c *WorkflowFilesCollector) scrape(ctx context.Context) {
+	err := c.Scrape(ctx)
+	c.status.Record("workflow_files", err)
+	if err != nil {
+		c.logger.Errorw("Failed to scrape",
+			"collector", "workflow_files",
+			"error", err.Error(),
+		)
+	}
+}
+
+func (c *WorkflowFilesCollector) StartLoop(ctx context.Context, interval time.Duration) {
+	c.status.Start("workflow_files", interval)
+	c.loop.start(ctx, interval, nil, c.scrape)
+}
+
+func (c *WorkflowFilesCollector) collectors() []prometheus.Collector {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return []prometheus.Collector{
+		c.runsOn,
+		c.unpinnedActions,
+		c.missingTimeout,
+		c.writeAllPermissions,
+	}
+}
+
+func (c *WorkflowFilesCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+func (c *WorkflowFilesCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
@@ -0,0 +1,208 @@
+package collector_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const fakeWorkflowFile = `
+name: CI
+on: push
+permissions: write-all
+jobs:
+  build:
+    runs-on: [self-hosted, linux]
+    timeout-minutes: 10
+    steps:
+      - uses: actions/checkout@v2
+      - uses: fake/setup@v1
+      - uses: fake/fake-action@v1
+      - uses: other/pinned@0123456789abcdef0123456789abcdef01234567
+      - uses: other/unpinned@v1
+      - uses: ./local
+      - uses: docker://alpine
+      - run: make
+  test:
+    runs-on:
+      group: large
+      labels: ubuntu-latest
+    permissions: write-all
+    steps:
+      - uses: other/unpinned
+  call:
+    uses: other/workflows/.github/workflows/deploy.yml@main
+`
+
+func TestWorkflowFilesCollectorScrape(t *testing.T) {
+	tests := []struct {
+		name      string
+		workflows []collector.Workflow
+		want      string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]collector.Workflow{
+				{ID: 1, Name: "CI", Path: ".github/workflows/ci.yml"},
+				{ID: 2, Name: "CodeQL", Path: "dynamic/github-code-scanning/codeql"},
+			},
+			`
+# HELP github_actions_workflow_job_missing_timeout_info Jobs without timeout-minutes in workflow files
+# TYPE github_actions_workflow_job_missing_timeout_info gauge
+github_actions_workflow_job_missing_timeout_info{job="test",path=".github/workflows/ci.yml",repository="fake/fake"} 1
+# HELP github_actions_workflow_job_runs_on_info Runner labels of each job in workflow files
+# TYPE github_actions_workflow_job_runs_on_info gauge
+github_actions_workflow_job_runs_on_info{job="build",path=".github/workflows/ci.yml",repository="fake/fake",runs_on="linux,self-hosted"} 1
+github_actions_workflow_job_runs_on_info{job="test",path=".github/workflows/ci.yml",repository="fake/fake",runs_on="group:large,ubuntu-latest"} 1
+# HELP github_actions_workflow_unpinned_action_info Third-party actions not pinned to a commit SHA in workflow files
+# TYPE github_actions_workflow_unpinned_action_info gauge
+github_actions_workflow_unpinned_action_info{action="other/unpinned",job="test",path=".github/workflows/ci.yml",repository="fake/fake"} 1
+github_actions_workflow_unpinned_action_info{action="other/unpinned@v1",job="build",path=".github/workflows/ci.yml",repository="fake/fake"} 1
+github_actions_workflow_unpinned_action_info{action="other/workflows/.github/workflows/deploy.yml@main",job="call",path=".github/workflows/ci.yml",repository="fake/fake"} 1
+# HELP github_actions_workflow_write_all_permissions_info Workflows and jobs with permissions: write-all in workflow files
+# TYPE github_actions_workflow_write_all_permissions_info gauge
+github_actions_workflow_write_all_permissions_info{job="",path=".github/workflows/ci.yml",repository="fake/fake"} 1
+github_actions_workflow_write_all_permissions_info{job="test",path=".github/workflows/ci.yml",repository="fake/fake"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		workflows := tt.workflows
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver := collector.NewWorkflowFilesCollector(
+				[]string{"fake/fake"},
+				&backendMock{
+					fakeFetchWorkflows: func(ctx context.Context, repositories []string) (map[string][]collector.Workflow, map[string]error) {
+						return map[string][]collector.Workflow{
+							"fake/fake": workflows,
+						}, nil
+					},
+				},
+				nil,
+				nil,
+				loggerMock{
+					fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+						t.Errorf("%s: %v", msg, keysAndValues)
+					},
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				httpClientMock{
+					fakeDo: func(request *http.Request) (*http.Response, error) {
+						if request.URL.Path != "/repos/fake/fake/contents/.github/workflows/ci.yml" {
+							t.Errorf("unexpected request: %s", request.URL.Path)
+						}
+						body, _ := json.Marshal(map[string]string{
+							"content":  base64.StdEncoding.EncodeToString([]byte(fakeWorkflowFile)),
+							"encoding": "base64",
+						})
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(string(body))),
+						}, nil
+					},
+				},
+			)
+			if err := receiver.Scrape(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := testutil.CollectAndCompare(receiver, strings.NewReader(want)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestWorkflowFilesCollectorScrapeFailedRepository(t *testing.T) {
+	want := `
+# HELP github_actions_workflow_write_all_permissions_info Workflows and jobs with permissions: write-all in workflow files
+# TYPE github_actions_workflow_write_all_permissions_info gauge
+github_actions_workflow_write_all_permissions_info{job="",path=".github/workflows/ci.yml",repository="fake/fake"} 1
+github_actions_workflow_write_all_permissions_info{job="test",path=".github/workflows/ci.yml",repository="fake/fake"} 1
+`
+	failWorkflows := false
+	failFiles := false
+	receiver := collector.NewWorkflowFilesCollector(
+		[]string{"fake/fake"},
+		&backendMock{
+			fakeFetchWorkflows: func(ctx context.Context, repositories []string) (map[string][]collector.Workflow, map[string]error) {
+				if failWorkflows {
+					return nil, map[string]error{
+						"fake/fake": errors.New("fake"),
+					}
+				}
+				return map[string][]collector.Workflow{
+					"fake/fake": {
+						{ID: 1, Name: "CI", Path: ".github/workflows/ci.yml"},
+					},
+				}, nil
+			},
+		},
+		nil,
+		nil,
+		loggerMock{
+			fakeErrorw: func(msg string, keysAndValues ...interface{}) {},
+			fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+		},
+		httpClientMock{
+			fakeDo: func(request *http.Request) (*http.Response, error) {
+				if failFiles {
+					return nil, errors.New("fake")
+				}
+				body, _ := json.Marshal(map[string]string{
+					"content":  base64.StdEncoding.EncodeToString([]byte(fakeWorkflowFile)),
+					"encoding": "base64",
+				})
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(string(body))),
+				}, nil
+			},
+		},
+	)
+	if err := receiver.Scrape(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+		"github_actions_workflow_write_all_permissions_info",
+	); err != nil {
+		t.Error(err)
+	}
+
+	failWorkflows = true
+	if err := receiver.Scrape(context.Background()); err == nil {
+		t.Fatal("want error, but got nil")
+	}
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+		"github_actions_workflow_write_all_permissions_info",
+	); err != nil {
+		t.Error(err)
+	}
+
+	failWorkflows = false
+	failFiles = true
+	if err := receiver.Scrape(context.Background()); err == nil {
+		t.Fatal("want error, but got nil")
+	}
+	if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+		"github_actions_workflow_write_all_permissions_info",
+	); err != nil {
+		t.Error(err)
+	}
+}
@@ -6,13 +6,23 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"go.opencensus.io/trace"
 	"golang.org/x/xerrors"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+var (
+	workflowsLabelNames    = []string{"repository", "state"}
+	workflowInfoLabelNames = []string{"repository", "workflow_id", "name", "path", "state"}
+	billableTimeLabelNames = []string{"repository", "workflow_id", "name"}
+)
+
 var (
 	workflowsPerPage = 100
 )
@@ -36,81 +46,88 @@ type WorkflowsResponse struct {
 }
 
 type WorkflowsCollector struct {
-	repository   string
-	token        string
-	logger       ILogger
-	httpClient   IHTTPClient
-	workflows    *prometheus.GaugeVec
-	billableTime *prometheus.GaugeVec
+	repositories      []string
+	backend           IBackend
+	store             IStore
+	status            *StatusRecorder
+	policy            *LabelPolicy
+	deployments       *DeploymentMatcher
+	logger            ILogger
+	httpClient        IHTTPClient
+	states            map[string]*DeploymentsState
+	lastWorkflows     map[string][]Workflow
+	lastBillableTimes map[string]map[uint64]time.Duration
+	loop              loop
+	mutex             sync.RWMutex
+	workflows         *prometheus.GaugeVec
+	workflowInfo      *prometheus.GaugeVec
+	billableTime      *prometheus.GaugeVec
+	deploymentsTotal  *prometheus.CounterVec
+	changeFailureRate *prometheus.GaugeVec
+	leadTime          *leadTimeHistograms
 }
 
 func NewWorkflowsCollector(
-	repository string,
-	token string,
+	repositories []string,
+	backend IBackend,
+	store IStore,
+	status *StatusRecorder,
+	policy *LabelPolicy,
+	deployments *DeploymentMatcher,
 	logger ILogger,
 	httpClient IHTTPClient,
 ) *WorkflowsCollector {
 	return &WorkflowsCollector{
-		repository: repository,
-		token:      token,
-		logger:     logger,
-		httpClient: httpClient,
-		workflows: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "workflows",
-			Help:      "List how many workflows in a repository",
-		}, []string{"repository", "state"}),
-		billableTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "workflow_billable_time_seconds",
-			Help:      "Total billable time of each workflows",
-		}, []string{"repository", "name"}),
-	}
-}
-
-func (c *WorkflowsCollector) fetchWorkflows(page int) ([]Workflow, error) {
-	request, err := http.NewRequest("GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/workflows?per_page=%d&page=%d", c.repository, workflowsPerPage, page), nil)
-	if err != nil {
-		return nil, xerrors.Errorf("failed to create request object: %w", err)
-	}
-	request.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
-	response, err := c.httpClient.Do(request)
-	if err != nil {
-		return nil, xerrors.Errorf("failed to request: %w", err)
-	}
-	defer response.Body.Close()
-
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return nil, xerrors.Errorf("failed to read response: %w", err)
+		repositories:      repositories,
+		backend:           backend,
+		store:             store,
+		status:            status,
+		policy:            policy,
+		deployments:       deployments,
+		logger:            logger,
+		httpClient:        httpClient,
+		states:            make(map[string]*DeploymentsState),
+		lastWorkflows:     make(map[string][]Workflow),
+		lastBillableTimes: make(map[string]map[uint64]time.Duration),
+		workflows:         newWorkflowsGaugeVec(),
+		workflowInfo:      newWorkflowInfoGaugeVec(),
+		billableTime:      newBillableTimeGaugeVec(),
+		deploymentsTotal:  newDeploymentsCounterVec(),
+		changeFailureRate: newChangeFailureRateGaugeVec(),
+		leadTime:          newLeadTimeHistograms(),
 	}
+}
 
-	var workflowsResponse WorkflowsResponse
-	if err := json.Unmarshal(body, &workflowsResponse); err != nil {
-		return nil, xerrors.Errorf("failed to parse response: %w", err)
-	}
-	if workflowsResponse.TotalCount == nil {
-		return nil, xerrors.Errorf("bad response: %s", string(body))
-	}
+func newWorkflowsGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workflows",
+		Help:      "List how many workflows in a repository",
+	}, workflowsLabelNames)
+}
 
-	if *workflowsResponse.TotalCount > runnersPerPage*page {
-		workflows, err := c.fetchWorkflows(page + 1)
-		if err != nil {
-			return nil, xerrors.Errorf("failed to execute fetchWorkflows: %w", err)
-		}
-		workflowsResponse.Workflows = append(workflowsResponse.Workflows, workflows...)
-	}
+func newWorkflowInfoGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workflow_info",
+		Help:      "Information about each workflows",
+	}, workflowInfoLabelNames)
+}
 
-	return workflowsResponse.Workflows, nil
+func newBillableTimeGaugeVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workflow_billable_time_seconds",
+		Help:      "Total billable time of each workflows",
+	}, billableTimeLabelNames)
 }
 
-func (c *WorkflowsCollector) fetchBillableTime(id uint64) (*time.Duration, error) {
-	request, err := http.NewRequest("GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/workflows/%d/timing", c.repository, id), nil)
+func (c *WorkflowsCollector) fetchBillableTime(ctx context.Context, repository string, id uint64) (*time.Duration, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/workflows/%d/timing", repository, id), nil)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create request object: %w", err)
 	}
-	request.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
-	response, err := c.httpClient.Do(request)
+	response, err := doRequest(ctx, c.httpClient, c.logger, request, repository, "/repos/{repository}/actions/workflows/{workflow_id}/timing")
 	if err != nil {
 		return nil, xerrors.Errorf("failed to request: %w", err)
 	}
@@ -137,60 +154,192 @@ func (c *WorkflowsCollector) fetchBillableTime(id uint64) (*time.Duration, error
 	return &totalBillableTime, nil
 }
 
-func (c *WorkflowsCollector) scrapeWorkflows() {
-	workflows, err := c.fetchWorkflows(1)
+func (c *WorkflowsCollector) Scrape(ctx context.Context) error {
+	ctx = withCollector(ctx, "workflows")
+	ctx, span := trace.StartSpan(ctx, "WorkflowsCollector.Scrape")
+	defer span.End()
+	span.AddAttributes(trace.Int64Attribute("github.repositories", int64(len(c.repositories))))
+
+	c.mutex.RLock()
+	previousWorkflows := c.lastWorkflows
+	previousBillableTimes := c.lastBillableTimes
+	c.mutex.RUnlock()
+
+	var failed []string
+	fetched, errs := c.backend.FetchWorkflows(ctx, c.repositories)
+	workflows := make(map[string][]Workflow)
+	for repository, w := range fetched {
+		workflows[repository] = w
+	}
+	for repository, err := range errs {
+		c.logger.Errorw("Failed to fetch workflows",
+			"collector", "workflows",
+			"repository", repository,
+			"error", err.Error(),
+		)
+		failed = append(failed, repository)
+		// Keep the series of the repository with the last fetched workflows
+		// instead of dropping them until the next successful fetch.
+		if w, ok := previousWorkflows[repository]; ok {
+			workflows[repository] = w
+		}
+	}
+	filter := c.policy.NewFilter()
+	workflowsGaugeVec := newWorkflowsGaugeVec()
+	workflowInfo := newWorkflowInfoGaugeVec()
+	billableTime := newBillableTimeGaugeVec()
+	billableTimes := make(map[string]map[uint64]time.Duration)
+	for repository, w := range workflows {
+		m, err := c.scrapeRepositoryWorkflows(ctx, repository, w, previousBillableTimes[repository], workflowsGaugeVec, workflowInfo, billableTime, filter)
+		billableTimes[repository] = m
+		if err != nil {
+			c.logger.Errorw("Failed to scrape repository",
+				"collector", "workflows",
+				"repository", repository,
+				"error", err.Error(),
+			)
+			if _, ok := errs[repository]; !ok {
+				failed = append(failed, repository)
+			}
+		}
+	}
+	var failedDeployments []string
+	deploymentsTotal := newDeploymentsCounterVec()
+	changeFailureRate := newChangeFailureRateGaugeVec()
+	leadTime := newLeadTimeHistograms()
+	if c.deployments != nil {
+		for repository, w := range workflows {
+			if err := c.scrapeRepositoryDeployments(ctx, repository, w, deploymentsTotal, changeFailureRate, leadTime, filter); err != nil {
+				c.logger.Errorw("Failed to scrape repository deployments",
+					"collector", "workflows",
+					"repository", repository,
+					"error", err.Error(),
+				)
+				failedDeployments = append(failedDeployments, repository)
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastWorkflows = workflows
+	c.lastBillableTimes = billableTimes
+	c.workflows = workflowsGaugeVec
+	c.workflowInfo = workflowInfo
+	c.billableTime = billableTime
+	c.deploymentsTotal = deploymentsTotal
+	c.changeFailureRate = changeFailureRate
+	c.leadTime = leadTime
+
+	if len(failed) > 0 {
+		return newRepositoriesError("failed to scrape workflows", failed)
+	}
+	if len(failedDeployments) > 0 {
+		return newRepositoriesError("failed to scrape deployments", failedDeployments)
+	}
+	return nil
+}
+
+func (c *WorkflowsCollector) scrape(ctx context.Context) {
+	err := c.Scrape(ctx)
+	c.status.Record("workflows", err)
 	if err != nil {
-		c.logger.Errorf("Failed to fetch workflows: %s\n", err.Error())
-		return
+		c.logger.Errorw("Failed to scrape",
+			"collector", "workflows",
+			"error", err.Error(),
+		)
 	}
+}
+
+func (c *WorkflowsCollector) scrapeRepositoryWorkflows(
+	ctx context.Context,
+	repository string,
+	workflows []Workflow,
+	previousBillableTimes map[uint64]time.Duration,
+	workflowsGaugeVec *prometheus.GaugeVec,
+	workflowInfo *prometheus.GaugeVec,
+	billableTimeGaugeVec *prometheus.GaugeVec,
+	filter *LabelFilter,
+) (map[uint64]time.Duration, error) {
+	var failed []string
+	billableTimes := make(map[uint64]time.Duration)
 	workflowsMap := make(map[string][]Workflow)
-	billableTimeMap := make(map[string]time.Duration)
 	for _, workflow := range workflows {
 		workflowsMap[workflow.State] = append(workflowsMap[workflow.State], workflow)
 
-		billableTime, err := c.fetchBillableTime(workflow.ID)
+		workflowID := strconv.FormatUint(workflow.ID, 10)
+		if labels, ok := filter.Apply("github_actions_workflow_info", workflowInfoLabelNames,
+			repository,
+			workflowID,
+			workflow.Name,
+			workflow.Path,
+			workflow.State,
+		); ok {
+			workflowInfo.WithLabelValues(labels...).Set(1)
+		}
+
+		billableTime, err := c.fetchBillableTime(ctx, repository, workflow.ID)
 		if err != nil {
-			c.logger.Errorf("Failed to fetch billableTime: %s\n", err.Error())
-			continue
+			c.logger.Errorw("Failed to fetch billable time",
+				"collector", "workflows",
+				"repository", repository,
+				"workflow_id", workflowID,
+				"error", err.Error(),
+			)
+			failed = append(failed, workflowID)
+			previous, ok := previousBillableTimes[workflow.ID]
+			if !ok {
+				continue
+			}
+			billableTime = &previous
+		}
+		billableTimes[workflow.ID] = *billableTime
+		labels, ok := filter.Apply("github_actions_workflow_billable_time_seconds", billableTimeLabelNames,
+			repository,
+			workflowID,
+			workflow.Name,
+		)
+		if ok {
+			billableTimeGaugeVec.WithLabelValues(labels...).Add(float64(*billableTime / time.Second))
 		}
-		billableTimeMap[workflow.Name] = *billableTime
 	}
 	for state, w := range workflowsMap {
-		labels := []string{
-			c.repository,
+		labels, ok := filter.Apply("github_actions_workflows", workflowsLabelNames,
+			repository,
 			state,
+		)
+		if ok {
+			workflowsGaugeVec.WithLabelValues(labels...).Add(float64(len(w)))
 		}
-		c.workflows.WithLabelValues(labels...).Set(float64(len(w)))
 	}
 
-	for name, billableTime := range billableTimeMap {
-		labels := []string{
-			c.repository,
-			name,
-		}
-		c.billableTime.WithLabelValues(labels...).Set(float64(billableTime / time.Second))
+	if len(failed) > 0 {
+		return billableTimes, xerrors.Errorf("failed to fetch billable time of workflows %s", strings.Join(failed, ", "))
 	}
+	return billableTimes, nil
+}
+
+func (c *WorkflowsCollector) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.states = make(map[string]*DeploymentsState)
 }
 
 func (c *WorkflowsCollector) StartLoop(ctx context.Context, interval time.Duration) {
-	go func(ctx context.Context) {
-		c.scrapeWorkflows()
-		t := time.NewTicker(interval)
-		defer t.Stop()
-		for {
-			select {
-			case <-t.C:
-				c.scrapeWorkflows()
-			case <-ctx.Done():
-				return
-			}
-		}
-	}(ctx)
+	c.status.Start("workflows", interval)
+	c.loop.start(ctx, interval, c.reset, c.scrape)
 }
 
 func (c *WorkflowsCollector) collectors() []prometheus.Collector {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return []prometheus.Collector{
+		c.workflows,
+		c.workflowInfo,
 		c.billableTime,
+		c.deploymentsTotal,
+		c.changeFailureRate,
+		c.leadTime,
 	}
 }
 
@@ -0,0 +1,239 @@
+package collector_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/xerrors"
+)
+
+func TestWorkflowsCollectorScrape(t *testing.T) {
+	tests := []struct {
+		name      string
+		workflows []collector.Workflow
+		timings   map[string]string
+		want      string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]collector.Workflow{
+				{ID: 1, Name: "CI", Path: ".github/workflows/ci.yml", State: "active"},
+				{ID: 2, Name: "Release", Path: ".github/workflows/release.yml", State: "disabled_manually"},
+			},
+			map[string]string{
+				"/repos/fake/fake/actions/workflows/1/timing": `{"billable": {"UBUNTU": {"total_ms": 60000}, "MACOS": {"total_ms": 30000}}}`,
+				"/repos/fake/fake/actions/workflows/2/timing": `{"billable": {}}`,
+			},
+			`
+# HELP github_actions_workflow_billable_time_seconds Total billable time of each workflows
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{name="CI",repository="fake/fake",workflow_id="1"} 90
+github_actions_workflow_billable_time_seconds{name="Release",repository="fake/fake",workflow_id="2"} 0
+# HELP github_actions_workflow_info Information about each workflows
+# TYPE github_actions_workflow_info gauge
+github_actions_workflow_info{name="CI",path=".github/workflows/ci.yml",repository="fake/fake",state="active",workflow_id="1"} 1
+github_actions_workflow_info{name="Release",path=".github/workflows/release.yml",repository="fake/fake",state="disabled_manually",workflow_id="2"} 1
+# HELP github_actions_workflows List how many workflows in a repository
+# TYPE github_actions_workflows gauge
+github_actions_workflows{repository="fake/fake",state="active"} 1
+github_actions_workflows{repository="fake/fake",state="disabled_manually"} 1
+`,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]collector.Workflow{
+				{ID: 1, Name: "CI", Path: ".github/workflows/ci.yml", State: "active"},
+				{ID: 2, Name: "CI", Path: ".github/workflows/ci-legacy.yml", State: "active"},
+			},
+			map[string]string{
+				"/repos/fake/fake/actions/workflows/1/timing": `{"billable": {"UBUNTU": {"total_ms": 60000}}}`,
+				"/repos/fake/fake/actions/workflows/2/timing": `{"billable": {"UBUNTU": {"total_ms": 120000}}}`,
+			},
+			`
+# HELP github_actions_workflow_billable_time_seconds Total billable time of each workflows
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{name="CI",repository="fake/fake",workflow_id="1"} 60
+github_actions_workflow_billable_time_seconds{name="CI",repository="fake/fake",workflow_id="2"} 120
+# HELP github_actions_workflow_info Information about each workflows
+# TYPE github_actions_workflow_info gauge
+github_actions_workflow_info{name="CI",path=".github/workflows/ci-legacy.yml",repository="fake/fake",state="active",workflow_id="2"} 1
+github_actions_workflow_info{name="CI",path=".github/workflows/ci.yml",repository="fake/fake",state="active",workflow_id="1"} 1
+# HELP github_actions_workflows List how many workflows in a repository
+# TYPE github_actions_workflows gauge
+github_actions_workflows{repository="fake/fake",state="active"} 2
+`,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		workflows := tt.workflows
+		timings := tt.timings
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver := collector.NewWorkflowsCollector(
+				[]string{"fake/fake"},
+				&backendMock{
+					fakeFetchWorkflows: func(ctx context.Context, repositories []string) (map[string][]collector.Workflow, map[string]error) {
+						return map[string][]collector.Workflow{"fake/fake": workflows}, nil
+					},
+				},
+				&storeMock{
+					m: make(map[string][]byte),
+				},
+				nil,
+				nil,
+				nil,
+				loggerMock{
+					fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+						t.Errorf("%s: %v", msg, keysAndValues)
+					},
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				httpClientMock{
+					fakeDo: func(request *http.Request) (*http.Response, error) {
+						body, ok := timings[request.URL.Path]
+						if !ok {
+							t.Errorf("unexpected request: %s", request.URL.Path)
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(body)),
+						}, nil
+					},
+				},
+			)
+			if err := receiver.Scrape(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+				"github_actions_workflows",
+				"github_actions_workflow_info",
+				"github_actions_workflow_billable_time_seconds",
+			); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestWorkflowsCollectorScrapeKeepsFailedSeries(t *testing.T) {
+	tests := []struct {
+		name                string
+		failFetchWorkflows  bool
+		failFetchBillable   bool
+		wantErrorRepository string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			true,
+			false,
+			"fake/fake",
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			false,
+			true,
+			"fake/fake",
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		failFetchWorkflows := tt.failFetchWorkflows
+		failFetchBillable := tt.failFetchBillable
+		wantErrorRepository := tt.wantErrorRepository
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			failing := false
+			receiver := collector.NewWorkflowsCollector(
+				[]string{"fake/fake"},
+				&backendMock{
+					fakeFetchWorkflows: func(ctx context.Context, repositories []string) (map[string][]collector.Workflow, map[string]error) {
+						if failing && failFetchWorkflows {
+							return map[string][]collector.Workflow{}, map[string]error{"fake/fake": errors.New("fake")}
+						}
+						return map[string][]collector.Workflow{
+							"fake/fake": {
+								{ID: 1, Name: "CI", Path: ".github/workflows/ci.yml", State: "active"},
+							},
+						}, nil
+					},
+				},
+				&storeMock{
+					m: make(map[string][]byte),
+				},
+				nil,
+				nil,
+				nil,
+				loggerMock{
+					fakeErrorw: func(msg string, keysAndValues ...interface{}) {},
+					fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
+				},
+				httpClientMock{
+					fakeDo: func(request *http.Request) (*http.Response, error) {
+						if failing && failFetchBillable {
+							return nil, errors.New("fake")
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(`{"billable": {"UBUNTU": {"total_ms": 60000}}}`)),
+						}, nil
+					},
+				},
+			)
+			if err := receiver.Scrape(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			failing = true
+			err := receiver.Scrape(context.Background())
+			var repositoriesErr *collector.RepositoriesError
+			if !xerrors.As(err, &repositoriesErr) {
+				t.Fatalf("want RepositoriesError, but got %v", err)
+			}
+			if diff := cmp.Diff([]string{wantErrorRepository}, repositoriesErr.Repositories); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+			want := `
+# HELP github_actions_workflow_billable_time_seconds Total billable time of each workflows
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{name="CI",repository="fake/fake",workflow_id="1"} 60
+# HELP github_actions_workflow_info Information about each workflows
+# TYPE github_actions_workflow_info gauge
+github_actions_workflow_info{name="CI",path=".github/workflows/ci.yml",repository="fake/fake",state="active",workflow_id="1"} 1
+# HELP github_actions_workflows List how many workflows in a repository
+# TYPE github_actions_workflows gauge
+github_actions_workflows{repository="fake/fake",state="active"} 1
+`
+			if err := testutil.CollectAndCompare(receiver, strings.NewReader(want),
+				"github_actions_workflows",
+				"github_actions_workflow_info",
+				"github_actions_workflow_billable_time_seconds",
+			); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
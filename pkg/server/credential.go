@@ -0,0 +1,132 @@
+package server
+
+import (
+	"github-actions-exporter/pkg/client"
+	"github-actions-exporter/pkg/server/shard"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/xerrors"
+)
+
+type ITokenSource interface {
+	Token() (string, error)
+}
+
+func resolveShardIndex(a *Args) (int, error) {
+	if a.ShardCount <= 1 {
+		return 0, nil
+	}
+	index := a.ShardIndex
+	if index < 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return 0, xerrors.Errorf("failed to get hostname: %w", err)
+		}
+		index, err = shard.OrdinalFromHostname(hostname)
+		if err != nil {
+			return 0, xerrors.Errorf("failed to derive shard index from hostname: %w", err)
+		}
+	}
+	if index >= a.ShardCount {
+		return 0, xerrors.Errorf("shard index %d is out of shard count %d", index, a.ShardCount)
+	}
+	return index, nil
+}
+
+func selectForShard(values []string, index int) (string, error) {
+	switch {
+	case len(values) == 0:
+		return "", nil
+	case len(values) == 1:
+		return values[0], nil
+	case index < len(values):
+		return values[index], nil
+	default:
+		return "", xerrors.Errorf("%d values are given but shard index is %d", len(values), index)
+	}
+}
+
+func selectIntForShard(values []int, index int) (int, error) {
+	switch {
+	case len(values) == 0:
+		return 0, nil
+	case len(values) == 1:
+		return values[0], nil
+	case index < len(values):
+		return values[index], nil
+	default:
+		return 0, xerrors.Errorf("%d values are given but shard index is %d", len(values), index)
+	}
+}
+
+type gitHubClient struct {
+	IHTTPClient
+	authorized *client.AuthorizedHTTPClient
+	rateLimits *client.RateLimitHTTPClient
+}
+
+func newGitHubClient(i *Instance, a *Args, shardIndex int, store IStore) (*gitHubClient, error) {
+	if a.EnableETagCache && a.StateStore == "configmap" {
+		return nil, xerrors.New("--enable-etag-cache cannot be used with --state-store=configmap, since the cached responses exceed the 1MiB limit of a ConfigMap")
+	}
+	tokenSource, err := newTokenSource(i, a, shardIndex)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create token source: %w", err)
+	}
+	authorized := &client.AuthorizedHTTPClient{
+		TokenSource: tokenSource,
+		Inner:       i.HTTPClient(),
+	}
+	rateLimits := &client.RateLimitHTTPClient{
+		Inner: authorized,
+	}
+	var httpClient IHTTPClient = rateLimits
+	if a.EnableETagCache {
+		httpClient = &client.ETagCachingHTTPClient{
+			Cache:        store,
+			Inner:        httpClient,
+			Logger:       i.Logger(),
+			Paths:        client.ListingPaths,
+			MaxEntrySize: client.DefaultETagCacheMaxEntrySize,
+			TTL:          client.DefaultETagCacheTTL,
+		}
+	}
+	return &gitHubClient{
+		IHTTPClient: httpClient,
+		authorized:  authorized,
+		rateLimits:  rateLimits,
+	}, nil
+}
+
+func newTokenSource(i *Instance, a *Args, shardIndex int) (ITokenSource, error) {
+	appID, err := selectIntForShard(a.AppIDs, shardIndex)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to select app id: %w", err)
+	}
+	if appID == 0 {
+		token, err := selectForShard(a.Tokens, shardIndex)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to select token: %w", err)
+		}
+		return client.NewStaticTokenSource(token), nil
+	}
+
+	installationID, err := selectIntForShard(a.AppInstallationIDs, shardIndex)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to select app installation id: %w", err)
+	}
+	privateKeyFile, err := selectForShard(a.AppPrivateKeyFiles, shardIndex)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to select app private key file: %w", err)
+	}
+	privateKey, err := ioutil.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", privateKeyFile, err)
+	}
+	appTokenSource, err := client.NewAppTokenSource(int64(appID), int64(installationID), privateKey, i.HTTPClient())
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create app token source: %w", err)
+	}
+	return appTokenSource, nil
+}
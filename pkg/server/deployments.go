@@ -0,0 +1,15 @@
+package server
+
+import (
+	"github-actions-exporter/pkg/server/collector"
+
+	"golang.org/x/xerrors"
+)
+
+func newDeploymentMatcher(a *Args) (*collector.DeploymentMatcher, error) {
+	matcher, err := collector.NewDeploymentMatcher(a.DeploymentWorkflowPattern, a.EnableDeploymentsAPI)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create deployment matcher: %w", err)
+	}
+	return matcher, nil
+}
@@ -0,0 +1,24 @@
+package handler
+
+import "net/http"
+
+type ReadyHandler struct {
+	ready func() error
+}
+
+func NewReadyHandler(ready func() error) *ReadyHandler {
+	return &ReadyHandler{
+		ready: ready,
+	}
+}
+
+func (h *ReadyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	if err := h.ready(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(http.StatusText(http.StatusOK)))
+}
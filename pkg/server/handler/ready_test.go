@@ -0,0 +1,71 @@
+package handler_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github-actions-exporter/pkg/server/handler"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestReadyHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		receiver *handler.ReadyHandler
+		in       *http.Request
+		want     *httptest.ResponseRecorder
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			handler.NewReadyHandler(func() error {
+				return nil
+			}),
+			httptest.NewRequest("GET", "/readyz", nil),
+			&httptest.ResponseRecorder{
+				Code:      http.StatusOK,
+				HeaderMap: http.Header{"Content-Type": {"text/plain"}},
+				Body:      bytes.NewBufferString("OK"),
+			},
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			handler.NewReadyHandler(func() error {
+				return errors.New("fake")
+			}),
+			httptest.NewRequest("GET", "/readyz", nil),
+			&httptest.ResponseRecorder{
+				Code:      http.StatusServiceUnavailable,
+				HeaderMap: http.Header{"Content-Type": {"text/plain"}},
+				Body:      bytes.NewBufferString("fake"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := httptest.NewRecorder()
+
+		name := tt.name
+		receiver := tt.receiver
+		in := tt.in
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver.ServeHTTP(got, in)
+			if diff := cmp.Diff(want, got, cmpopts.IgnoreUnexported(*got), cmp.AllowUnexported(*got.Body)); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"github-actions-exporter/pkg/client"
+	"github-actions-exporter/pkg/server/collector"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+		"time": func(t *time.Time) string {
+			if t == nil {
+				return "-"
+			}
+			return t.Format(time.RFC3339)
+		},
+		"json": func(v interface{}) (string, error) {
+			b, err := json.MarshalIndent(v, "", "  ")
+			return string(b), err
+		},
+		"join": strings.Join,
+	}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>github-actions-exporter status</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>github-actions-exporter status</h1>
+<p>Generated at {{ time .GeneratedAt }}. <a href="?format=json">JSON</a></p>
+<h2>Collectors</h2>
+<table>
+<tr><th>Collector</th><th>Repositories</th><th>Interval</th><th>Last success</th><th>Last error</th><th>Next run</th></tr>
+{{ range .Collectors -}}
+<tr><td>{{ .Name }}</td><td>{{ join .Repositories ", " }}</td><td>{{ .IntervalSeconds }}s</td><td>{{ time .LastSuccessAt }}</td><td>{{ if .LastError }}{{ time .LastErrorAt }}: {{ .LastError }}{{ else }}-{{ end }}</td><td>{{ time .NextRunAt }}</td></tr>
+{{ end -}}
+</table>
+<h2>Rate limits</h2>
+<table>
+<tr><th>Resource</th><th>Remaining</th><th>Limit</th><th>Used</th><th>Reset</th></tr>
+{{ range .RateLimits -}}
+<tr><td>{{ .Resource }}</td><td>{{ .Remaining }}</td><td>{{ .Limit }}</td><td>{{ .Used }}</td><td>{{ .ResetAt.Format "2006-01-02T15:04:05Z07:00" }}</td></tr>
+{{ end -}}
+</table>
+<h2>Configuration</h2>
+<pre>{{ json .Config }}</pre>
+</body>
+</html>
+`))
+)
+
+type Status struct {
+	GeneratedAt *time.Time                  `json:"generated_at"`
+	Collectors  []collector.CollectorStatus `json:"collectors"`
+	RateLimits  []client.RateLimit          `json:"rate_limits"`
+	Config      map[string]interface{}      `json:"config"`
+}
+
+type StatusHandler struct {
+	status func() Status
+}
+
+func NewStatusHandler(status func() Status) *StatusHandler {
+	return &StatusHandler{
+		status: status,
+	}
+}
+
+func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := h.status()
+	if status.GeneratedAt == nil {
+		now := time.Now().UTC()
+		status.GeneratedAt = &now
+	}
+
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		b, err := json.Marshal(status)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(b)
+		return
+	}
+
+	var b strings.Builder
+	if err := statusTemplate.Execute(&b, status); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(b.String()))
+}
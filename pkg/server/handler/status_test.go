@@ -0,0 +1,103 @@
+package handler_test
+
+import (
+	"bytes"
+	"fmt"
+	"github-actions-exporter/pkg/client"
+	"github-actions-exporter/pkg/server/collector"
+	"github-actions-exporter/pkg/server/handler"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestStatusHandler(t *testing.T) {
+	generatedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	status := func() handler.Status {
+		return handler.Status{
+			GeneratedAt: &generatedAt,
+			Collectors: []collector.CollectorStatus{
+				{
+					Name:            "runs",
+					Repositories:    []string{"fake/fake"},
+					IntervalSeconds: 300,
+					LastErrorAt:     &generatedAt,
+					LastError:       "<fake>",
+				},
+			},
+			RateLimits: []client.RateLimit{
+				{Resource: "core", Limit: 5000, Remaining: 4999, Used: 1, ResetAt: generatedAt},
+			},
+			Config: map[string]interface{}{
+				"Tokens": []string{"REDACTED"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		receiver     *handler.StatusHandler
+		in           *http.Request
+		want         *httptest.ResponseRecorder
+		wantContains []string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			handler.NewStatusHandler(status),
+			httptest.NewRequest("GET", "/status?format=json", nil),
+			&httptest.ResponseRecorder{
+				Code:      http.StatusOK,
+				HeaderMap: http.Header{"Content-Type": {"application/json"}},
+				Body:      bytes.NewBufferString(`{"generated_at":"2020-01-01T00:00:00Z","collectors":[{"name":"runs","repositories":["fake/fake"],"interval_seconds":300,"last_error_at":"2020-01-01T00:00:00Z","last_error":"\u003cfake\u003e"}],"rate_limits":[{"resource":"core","limit":5000,"remaining":4999,"used":1,"reset_at":"2020-01-01T00:00:00Z"}],"config":{"Tokens":["REDACTED"]}}`),
+			},
+			nil,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			handler.NewStatusHandler(status),
+			httptest.NewRequest("GET", "/status", nil),
+			nil,
+			[]string{
+				"<td>runs</td><td>fake/fake</td><td>300s</td><td>-</td><td>2020-01-01T00:00:00Z: &lt;fake&gt;</td><td>-</td>",
+				"<td>core</td><td>4999</td><td>5000</td><td>1</td><td>2020-01-01T00:00:00Z</td>",
+				"&#34;REDACTED&#34;",
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := httptest.NewRecorder()
+
+		name := tt.name
+		receiver := tt.receiver
+		in := tt.in
+		want := tt.want
+		wantContains := tt.wantContains
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver.ServeHTTP(got, in)
+			if want != nil {
+				if diff := cmp.Diff(want, got, cmpopts.IgnoreUnexported(*got), cmp.AllowUnexported(*got.Body)); diff != "" {
+					t.Errorf("(-want +got):\n%s", diff)
+				}
+			}
+			for _, s := range wantContains {
+				if !strings.Contains(got.Body.String(), s) {
+					t.Errorf("%q is not contained in:\n%s", s, got.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"time"
 
 	"go.opencensus.io/plugin/ochttp"
+	"golang.org/x/xerrors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 const (
@@ -16,9 +19,10 @@ const (
 )
 
 type Instance struct {
-	processors []IProcessor
-	httpClient IHTTPClient
-	logger     ILogger
+	processors       []IProcessor
+	httpClient       IHTTPClient
+	kubernetesClient IKubernetesClient
+	logger           ILogger
 }
 
 func NewInstance() *Instance {
@@ -60,6 +64,25 @@ func (i *Instance) SetHTTPClient(httpClient IHTTPClient) {
 	i.httpClient = httpClient
 }
 
+func (i *Instance) KubernetesClient() (IKubernetesClient, error) {
+	if i.kubernetesClient == nil {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to get in-cluster config: %w", err)
+		}
+		kubernetesClient, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create kubernetes client: %w", err)
+		}
+		i.kubernetesClient = kubernetesClient
+	}
+	return i.kubernetesClient, nil
+}
+
+func (i *Instance) SetKubernetesClient(kubernetesClient IKubernetesClient) {
+	i.kubernetesClient = kubernetesClient
+}
+
 func (i *Instance) Logger() ILogger {
 	return i.logger
 }
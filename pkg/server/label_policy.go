@@ -0,0 +1,18 @@
+package server
+
+import (
+	"github-actions-exporter/pkg/server/collector"
+
+	"golang.org/x/xerrors"
+)
+
+func newLabelPolicy(a *Args) (*collector.LabelPolicy, error) {
+	if a.LabelPolicyFile == "" {
+		return nil, nil
+	}
+	policy, err := collector.LoadLabelPolicy(a.LabelPolicyFile)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load label policy: %w", err)
+	}
+	return policy, nil
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"github-actions-exporter/pkg/client"
+	"io"
+	"os"
+
+	"golang.org/x/xerrors"
+)
+
+func newLogger(a *Args, out io.Writer) (ILogger, error) {
+	level := client.LogLevelInfo
+	if a.Verbose {
+		level = client.LogLevelDebug
+	}
+	if a.LogLevel != "" {
+		l, err := client.ParseLogLevel(a.LogLevel)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse log level: %w", err)
+		}
+		level = l
+	}
+	logger, err := client.NewStructuredLogger(out, os.Stderr, a.LogFormat, level)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create logger: %w", err)
+	}
+	return logger, nil
+}
@@ -37,15 +37,15 @@ func TestClientClosedRequestMiddleware(t *testing.T) {
 				ctx, cancel := context.WithCancel(context.Background())
 				cancel()
 				return httptest.NewRequest("GET", "/", nil).WithContext(client.SetRequestLogger(ctx, client.NewRequestLogger("", loggerMock{
-					fakeInfof: func(format string, v ...interface{}) {
+					fakeInfow: func(msg string, keysAndValues ...interface{}) {
 						stack :=
 							`client closed request in GET /:
     github-actions-exporter/pkg/server/middleware.NewClientClosedRequestMiddleware.func1.1.1
         github-actions-exporter/pkg/server/middleware/client_closed_request.go:55
   - context canceled
 `
-						want := fmt.Sprintf(`{"severity":"info","requestID":"","payload":%q}`, stack)
-						got := fmt.Sprintf(format, v...)
+						want := []interface{}{stack, "request_id", ""}
+						got := append([]interface{}{msg}, keysAndValues...)
 						if diff := cmp.Diff(want, got); diff != "" {
 							t.Errorf("(-want +got):\n%s", diff)
 						}
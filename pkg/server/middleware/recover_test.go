@@ -37,15 +37,14 @@ func TestRecoverMiddleware(t *testing.T) {
 				panic(errors.New("fake"))
 			})),
 			httptest.NewRequest("GET", "/", nil).WithContext(client.SetRequestLogger(context.Background(), client.NewRequestLogger("", loggerMock{
-				fakeErrorf: func(format string, v ...interface{}) {
-					stack := "panic: fake\n"
-					want := fmt.Sprintf(`{"severity":"error","requestID":"","payload":%q}`, stack)
-					got := fmt.Sprintf(format, v...)
+				fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+					want := []interface{}{"panic: fake\n", "request_id", ""}
+					got := append([]interface{}{msg}, keysAndValues...)
 					if diff := cmp.Diff(want, got); diff != "" {
 						t.Errorf("(-want +got):\n%s", diff)
 					}
 				},
-				fakeDebugf: func(format string, v ...interface{}) {},
+				fakeDebugw: func(msg string, keysAndValues ...interface{}) {},
 			}))),
 		},
 	}
@@ -24,9 +24,9 @@ func TestRequestLoggerMiddleware(t *testing.T) {
 				return fmt.Sprintf("L%d", line)
 			}(),
 			middleware.NewRequestLoggerMiddleware(loggerMock{
-				fakeErrorf: func(format string, v ...interface{}) {
-					want := `{"severity":"error","requestID":"fake2","payload":"fake1"}`
-					got := fmt.Sprintf(format, v...)
+				fakeErrorw: func(msg string, keysAndValues ...interface{}) {
+					want := []interface{}{"fake1", "request_id", "fake2"}
+					got := append([]interface{}{msg}, keysAndValues...)
 					if diff := cmp.Diff(want, got); diff != "" {
 						t.Errorf("(-want +got):\n%s", diff)
 					}
@@ -2,8 +2,10 @@ package processor
 
 import (
 	"context"
+	"crypto/tls"
 	"github-actions-exporter/pkg/server/handler"
 	"github-actions-exporter/pkg/server/middleware"
+	"github-actions-exporter/pkg/server/web"
 	"net"
 	"net/http"
 	"syscall"
@@ -22,6 +24,9 @@ type APISettings struct {
 	KeepAlived           bool
 	ReUsePort            bool
 	TCPKeepAliveInterval time.Duration
+	Status               func() handler.Status
+	Ready                func() error
+	WebConfig            *web.Config
 	Logger               ILogger
 }
 
@@ -36,11 +41,28 @@ func NewAPI(settings APISettings) (*API, error) {
 	router.Use(middleware.NewRequestLoggerMiddleware(settings.Logger))
 	router.Use(middleware.NewRecoverMiddleware())
 	router.Use(middleware.NewClientClosedRequestMiddleware())
+	router.Use(web.NewAuthMiddleware(settings.WebConfig, "/health", "/healthz", "/readyz"))
 
 	router.Handle(
 		"/health",
 		handler.NewHealthHandler(),
 	).Methods("GET")
+	router.Handle(
+		"/healthz",
+		handler.NewHealthHandler(),
+	).Methods("GET")
+	if settings.Ready != nil {
+		router.Handle(
+			"/readyz",
+			handler.NewReadyHandler(settings.Ready),
+		).Methods("GET")
+	}
+	if settings.Status != nil {
+		router.Handle(
+			"/status",
+			handler.NewStatusHandler(settings.Status),
+		).Methods("GET")
+	}
 
 	var listener net.Listener
 	var err error
@@ -67,6 +89,13 @@ func NewAPI(settings APISettings) (*API, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("could not listen %s: %w", settings.Address, err)
 	}
+	tlsConfig, err := settings.WebConfig.TLSConfig()
+	if err != nil {
+		return nil, xerrors.Errorf("could not set up tls: %w", err)
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
 	server := &http.Server{
 		Handler: &ochttp.Handler{
 			Handler: router,
@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+type ElectorSettings struct {
+	KubernetesClient IKubernetesClient
+	Namespace        string
+	Name             string
+	Identity         string
+	LeaseDuration    time.Duration
+	RenewDeadline    time.Duration
+	RetryPeriod      time.Duration
+	Logger           ILogger
+}
+
+type Elector struct {
+	elector   *leaderelection.LeaderElector
+	identity  string
+	logger    ILogger
+	mutex     sync.RWMutex
+	leading   bool
+	callbacks []func(context.Context)
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func NewElector(settings ElectorSettings) (*Elector, error) {
+	e := &Elector{
+		identity: settings.Identity,
+		logger:   settings.Logger,
+		done:     make(chan struct{}),
+	}
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Namespace: settings.Namespace,
+				Name:      settings.Name,
+			},
+			Client: settings.KubernetesClient.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: settings.Identity,
+			},
+		},
+		LeaseDuration:   settings.LeaseDuration,
+		RenewDeadline:   settings.RenewDeadline,
+		RetryPeriod:     settings.RetryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: e.onStartedLeading,
+			OnStoppedLeading: e.onStoppedLeading,
+			OnNewLeader: func(identity string) {
+				e.logger.Infof("%s is the leader\n", identity)
+			},
+		},
+		Name: settings.Name,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("could not create leader elector: %w", err)
+	}
+	e.elector = elector
+	return e, nil
+}
+
+func (e *Elector) OnStartedLeading(callback func(context.Context)) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.callbacks = append(e.callbacks, callback)
+}
+
+func (e *Elector) IsLeader() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.leading
+}
+
+func (e *Elector) onStartedLeading(ctx context.Context) {
+	e.logger.Infof("%s started leading\n", e.identity)
+	e.mutex.Lock()
+	e.leading = true
+	callbacks := make([]func(context.Context), len(e.callbacks))
+	copy(callbacks, e.callbacks)
+	e.mutex.Unlock()
+	// The callbacks run without the lock since they may call IsLeader.
+	for _, callback := range callbacks {
+		callback(ctx)
+	}
+}
+
+func (e *Elector) onStoppedLeading() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.leading {
+		e.logger.Infof("%s stopped leading\n", e.identity)
+	}
+	e.leading = false
+}
+
+func (e *Elector) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.mutex.Lock()
+	e.cancel = cancel
+	e.mutex.Unlock()
+	defer close(e.done)
+	for {
+		e.elector.Run(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
+
+func (e *Elector) Stop(ctx context.Context) error {
+	e.mutex.RLock()
+	cancel := e.cancel
+	e.mutex.RUnlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
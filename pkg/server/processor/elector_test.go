@@ -0,0 +1,116 @@
+package processor_test
+
+import (
+	"context"
+	"github-actions-exporter/pkg/client"
+	"github-actions-exporter/pkg/server/processor"
+	"sync"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	typedcoordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+)
+
+type kubernetesClientMock struct {
+	kubernetes.Interface
+	leases *leasesMock
+}
+
+func (c *kubernetesClientMock) CoordinationV1() typedcoordinationv1.CoordinationV1Interface {
+	return &coordinationV1Mock{leases: c.leases}
+}
+
+type coordinationV1Mock struct {
+	typedcoordinationv1.CoordinationV1Interface
+	leases *leasesMock
+}
+
+func (c *coordinationV1Mock) Leases(namespace string) typedcoordinationv1.LeaseInterface {
+	return c.leases
+}
+
+type leasesMock struct {
+	typedcoordinationv1.LeaseInterface
+	mutex sync.Mutex
+	lease *coordinationv1.Lease
+}
+
+func (m *leasesMock) Get(name string, options metav1.GetOptions) (*coordinationv1.Lease, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.lease == nil {
+		return nil, errors.NewNotFound(schema.GroupResource{Resource: "leases"}, name)
+	}
+	return m.lease.DeepCopy(), nil
+}
+
+func (m *leasesMock) Create(lease *coordinationv1.Lease) (*coordinationv1.Lease, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.lease != nil {
+		return nil, errors.NewAlreadyExists(schema.GroupResource{Resource: "leases"}, lease.Name)
+	}
+	m.lease = lease.DeepCopy()
+	return lease, nil
+}
+
+func (m *leasesMock) Update(lease *coordinationv1.Lease) (*coordinationv1.Lease, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lease = lease.DeepCopy()
+	return lease, nil
+}
+
+func TestElector(t *testing.T) {
+	elector, err := processor.NewElector(processor.ElectorSettings{
+		KubernetesClient: &kubernetesClientMock{leases: &leasesMock{}},
+		Namespace:        "fake",
+		Name:             "fake",
+		Identity:         "fake",
+		LeaseDuration:    2 * time.Second,
+		RenewDeadline:    time.Second,
+		RetryPeriod:      100 * time.Millisecond,
+		Logger:           client.NewDefaultLogger(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan bool, 1)
+	elector.OnStartedLeading(func(ctx context.Context) {
+		// IsLeader must not block while the callbacks run.
+		started <- elector.IsLeader()
+	})
+	if elector.IsLeader() {
+		t.Error("want not leader before start")
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- elector.Start()
+	}()
+	select {
+	case leader := <-started:
+		if !leader {
+			t.Error("want leader in the callback")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for leading")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := elector.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	if elector.IsLeader() {
+		t.Error("want not leader after stop")
+	}
+}
@@ -2,7 +2,10 @@ package processor
 
 import (
 	"context"
+	"crypto/tls"
+	"github-actions-exporter/pkg/client"
 	"github-actions-exporter/pkg/server/collector"
+	"github-actions-exporter/pkg/server/web"
 	"net"
 	"net/http"
 	"net/http/pprof"
@@ -24,64 +27,155 @@ import (
 
 const (
 	metricsPath = "/metrics"
+	serviceName = "github-actions-exporter"
 )
 
 type MonitorSettings struct {
-	Address                        string
-	MaxConnections                 int64
-	JaegerEndpoint                 string
-	EnableProfiling                bool
-	EnableTracing                  bool
-	TracingSampleRate              float64
-	KeepAlived                     bool
-	ReUsePort                      bool
-	TCPKeepAliveInterval           time.Duration
-	RunsCollectorLoopInterval      time.Duration
-	RunnersCollectorLoopInterval   time.Duration
-	WorkflowsCollectorLoopInterval time.Duration
-	HTTPClient                     IHTTPClient
-	Logger                         ILogger
-	Repository                     string
-	Token                          string
+	Address                            string
+	MaxConnections                     int64
+	JaegerEndpoint                     string
+	EnableProfiling                    bool
+	EnableTracing                      bool
+	TracingSampleRate                  float64
+	TracingExporter                    string
+	OTLPEndpoint                       string
+	OTLPHeaders                        map[string]string
+	KeepAlived                         bool
+	ReUsePort                          bool
+	TCPKeepAliveInterval               time.Duration
+	WebConfig                          *web.Config
+	RunsCollectorLoopInterval          time.Duration
+	RunnersCollectorLoopInterval       time.Duration
+	WorkflowsCollectorLoopInterval     time.Duration
+	RunTracesCollectorLoopInterval     time.Duration
+	PullRequestsCollectorLoopInterval  time.Duration
+	FlakyCollectorLoopInterval         time.Duration
+	SecurityCollectorLoopInterval      time.Duration
+	WorkflowFilesCollectorLoopInterval time.Duration
+	EnableRunTraces                    bool
+	BackendCacheTTL                    time.Duration
+	RunTracesServiceName               string
+	HTTPClient                         IHTTPClient
+	Store                              IStore
+	StatusRecorder                     *collector.StatusRecorder
+	LabelPolicy                        *collector.LabelPolicy
+	Deployments                        *collector.DeploymentMatcher
+	RequiredWorkflowPattern            string
+	ActorTopN                          int
+	Elector                            *Elector
+	Logger                             ILogger
+	Repositories                       []string
+	Backend                            string
+	Collectors                         []string
 }
 
 type Monitor struct {
+	registry       *prometheus.Registry
 	maxConnections int64
 	listener       net.Listener
 	server         *http.Server
+	flushers       []flusher
+}
+
+// flusher is a span exporter which buffers spans, e.g. the jaeger and OTLP
+// exporters.
+type flusher interface {
+	Flush()
 }
 
 func NewMonitor(settings MonitorSettings) (*Monitor, error) {
 	router := mux.NewRouter()
+	router.Use(web.NewAuthMiddleware(settings.WebConfig))
 
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
 	registry.MustRegister(prometheus.NewGoCollector())
-	ctx := context.Background()
-	runsCollector := collector.NewRunsCollector(
-		settings.Repository,
-		settings.Token,
-		settings.Logger,
-		settings.HTTPClient,
-	)
-	registry.MustRegister(runsCollector)
-	runsCollector.StartLoop(ctx, settings.RunsCollectorLoopInterval)
-	runnersCollector := collector.NewRunnersCollector(
-		settings.Repository,
-		settings.Token,
-		settings.Logger,
-		settings.HTTPClient,
-	)
-	registry.MustRegister(runnersCollector)
-	runnersCollector.StartLoop(ctx, settings.RunnersCollectorLoopInterval)
-	workflowsCollector := collector.NewWorkflowsCollector(
-		settings.Repository,
-		settings.Token,
+	backend, err := collector.NewBackend(settings.Backend, settings.BackendCacheTTL, settings.Logger, settings.HTTPClient)
+	if err != nil {
+		return nil, xerrors.Errorf("could not set up backend: %w", err)
+	}
+	collectors, err := collector.NewCollectors(
+		settings.Collectors,
+		settings.Repositories,
+		backend,
+		settings.BackendCacheTTL,
+		settings.Store,
+		settings.StatusRecorder,
+		settings.LabelPolicy,
+		settings.Deployments,
+		settings.RequiredWorkflowPattern,
+		settings.ActorTopN,
 		settings.Logger,
 		settings.HTTPClient,
 	)
-	registry.MustRegister(workflowsCollector)
-	workflowsCollector.StartLoop(ctx, settings.WorkflowsCollectorLoopInterval)
+	if err != nil {
+		return nil, xerrors.Errorf("could not set up collectors: %w", err)
+	}
+	if settings.LabelPolicy != nil {
+		registry.MustRegister(settings.LabelPolicy)
+	}
+	intervals := map[string]time.Duration{
+		"runs":           settings.RunsCollectorLoopInterval,
+		"runners":        settings.RunnersCollectorLoopInterval,
+		"workflows":      settings.WorkflowsCollectorLoopInterval,
+		"pull_requests":  settings.PullRequestsCollectorLoopInterval,
+		"flaky":          settings.FlakyCollectorLoopInterval,
+		"security":       settings.SecurityCollectorLoopInterval,
+		"workflow_files": settings.WorkflowFilesCollectorLoopInterval,
+	}
+	var flushers []flusher
+	var runTracesCollector *collector.RunTracesCollector
+	if settings.EnableRunTraces {
+		spanExporter, err := newSpanExporter(settings, settings.RunTracesServiceName)
+		if err != nil {
+			return nil, xerrors.Errorf("could not set up span exporter: %w", err)
+		}
+		if f, ok := spanExporter.(flusher); ok {
+			flushers = append(flushers, f)
+		}
+		settings.StatusRecorder.Register("run_traces", settings.Repositories)
+		runTracesCollector = collector.NewRunTracesCollector(
+			settings.Repositories,
+			backend,
+			settings.Store,
+			spanExporter,
+			settings.StatusRecorder,
+			settings.Logger,
+			settings.HTTPClient,
+		)
+	}
+	start := func(ctx context.Context) {
+		for name, c := range collectors {
+			c.StartLoop(ctx, intervals[name])
+		}
+		if runTracesCollector != nil {
+			runTracesCollector.StartLoop(ctx, settings.RunTracesCollectorLoopInterval)
+		}
+	}
+	if settings.Elector != nil {
+		for _, c := range collectors {
+			registry.MustRegister(&leaderCollector{
+				Collector: c,
+				elector:   settings.Elector,
+			})
+		}
+		registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "github_actions",
+			Name:      "leader",
+			Help:      "Whether this replica is the leader polling GitHub",
+		}, func() float64 {
+			if settings.Elector.IsLeader() {
+				return 1
+			}
+			return 0
+		}))
+		settings.Elector.OnStartedLeading(start)
+	} else {
+		for _, c := range collectors {
+			registry.MustRegister(c)
+		}
+		start(context.Background())
+	}
 
 	prometheusExporter, err := ocprom.NewExporter(ocprom.Options{Registry: registry})
 	if err != nil {
@@ -92,19 +186,15 @@ func NewMonitor(settings MonitorSettings) (*Monitor, error) {
 	router.Handle(metricsPath, prometheusExporter)
 
 	if settings.EnableTracing {
-		jaegerExporter, err := jaeger.NewExporter(jaeger.Options{
-			AgentEndpoint: settings.JaegerEndpoint,
-			Process: jaeger.Process{
-				ServiceName: "github-actions-exporter",
-			},
-		})
+		spanExporter, err := newSpanExporter(settings, serviceName)
 		if err != nil {
-			return nil, xerrors.Errorf("could not set up jaeger exporter: %w", err)
+			return nil, xerrors.Errorf("could not set up span exporter: %w", err)
 		}
-		defer jaegerExporter.Flush()
-
 		trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(settings.TracingSampleRate)})
-		trace.RegisterExporter(jaegerExporter)
+		trace.RegisterExporter(spanExporter)
+		if f, ok := spanExporter.(flusher); ok {
+			flushers = append(flushers, f)
+		}
 	}
 
 	if settings.EnableProfiling {
@@ -141,6 +231,13 @@ func NewMonitor(settings MonitorSettings) (*Monitor, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("could not listen %s: %w", settings.Address, err)
 	}
+	tlsConfig, err := settings.WebConfig.TLSConfig()
+	if err != nil {
+		return nil, xerrors.Errorf("could not set up tls: %w", err)
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
 	server := &http.Server{
 		Handler: &ochttp.Handler{
 			Handler: router,
@@ -151,16 +248,76 @@ func NewMonitor(settings MonitorSettings) (*Monitor, error) {
 	}
 	server.SetKeepAlivesEnabled(settings.KeepAlived)
 	return &Monitor{
+		registry:       registry,
 		maxConnections: settings.MaxConnections,
 		listener:       listener,
 		server:         server,
+		flushers:       flushers,
 	}, nil
 }
 
+func newSpanExporter(settings MonitorSettings, serviceName string) (trace.Exporter, error) {
+	switch settings.TracingExporter {
+	case "jaeger":
+		jaegerExporter, err := jaeger.NewExporter(jaeger.Options{
+			AgentEndpoint: settings.JaegerEndpoint,
+			Process: jaeger.Process{
+				ServiceName: serviceName,
+			},
+		})
+		if err != nil {
+			return nil, xerrors.Errorf("could not set up jaeger exporter: %w", err)
+		}
+		return jaegerExporter, nil
+	case "otlp":
+		return client.NewOTLPExporter(
+			settings.OTLPEndpoint,
+			serviceName,
+			settings.OTLPHeaders,
+			&http.Client{
+				Timeout: 10 * time.Second,
+			},
+			settings.Logger,
+		), nil
+	default:
+		return nil, xerrors.Errorf("unknown tracing exporter: %s", settings.TracingExporter)
+	}
+}
+
+type leaderCollector struct {
+	prometheus.Collector
+	elector *Elector
+}
+
+func (c *leaderCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.elector.IsLeader() {
+		c.Collector.Collect(ch)
+	}
+}
+
+func (m *Monitor) Gatherer() prometheus.Gatherer {
+	return m.registry
+}
+
 func (m *Monitor) Start() error {
 	return m.server.Serve(netutil.LimitListener(m.listener, int(m.maxConnections)))
 }
 
 func (m *Monitor) Stop(ctx context.Context) error {
-	return m.server.Shutdown(ctx)
+	if err := m.server.Shutdown(ctx); err != nil {
+		return xerrors.Errorf("failed to shutdown server: %w", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, f := range m.flushers {
+			f.Flush()
+		}
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return xerrors.Errorf("failed to flush span exporters: %w", ctx.Err())
+	}
 }
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"context"
+	"github-actions-exporter/pkg/client"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"golang.org/x/xerrors"
+)
+
+type PusherSettings struct {
+	Target     string
+	Endpoint   string
+	Job        string
+	Instance   string
+	Headers    map[string]string
+	Interval   time.Duration
+	Gatherer   prometheus.Gatherer
+	HTTPClient IHTTPClient
+	Logger     ILogger
+}
+
+type Pusher struct {
+	push     func() error
+	interval time.Duration
+	logger   ILogger
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewPusher(settings PusherSettings) (*Pusher, error) {
+	httpClient := &client.HeaderHTTPClient{
+		Headers: settings.Headers,
+		Inner:   settings.HTTPClient,
+	}
+	var f func() error
+	switch settings.Target {
+	case "pushgateway":
+		pusher := push.New(settings.Endpoint, settings.Job).
+			Grouping("instance", settings.Instance).
+			Gatherer(settings.Gatherer).
+			Client(httpClient)
+		f = pusher.Push
+	case "remote-write":
+		remoteWriteClient := client.NewRemoteWriteClient(settings.Endpoint, httpClient)
+		f = func() error {
+			families, err := settings.Gatherer.Gather()
+			if err != nil {
+				return xerrors.Errorf("failed to gather metrics: %w", err)
+			}
+			return remoteWriteClient.Write(families, time.Now())
+		}
+	case "otlp":
+		otlpMetricsClient := client.NewOTLPMetricsClient(settings.Endpoint, serviceName, httpClient)
+		f = func() error {
+			families, err := settings.Gatherer.Gather()
+			if err != nil {
+				return xerrors.Errorf("failed to gather metrics: %w", err)
+			}
+			return otlpMetricsClient.Export(families, time.Now())
+		}
+	default:
+		return nil, xerrors.Errorf("unknown push target: %s", settings.Target)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pusher{
+		push:     f,
+		interval: settings.Interval,
+		logger:   settings.Logger,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+func (p *Pusher) pushOnce() {
+	if err := p.push(); err != nil {
+		p.logger.Errorw("Failed to push metrics",
+			"error", err.Error(),
+		)
+	}
+}
+
+func (p *Pusher) Start() error {
+	defer close(p.done)
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			p.pushOnce()
+		case <-p.ctx.Done():
+			p.pushOnce()
+			return nil
+		}
+	}
+}
+
+func (p *Pusher) Stop(ctx context.Context) error {
+	p.cancel()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
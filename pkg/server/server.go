@@ -2,8 +2,12 @@ package server
 
 import (
 	"context"
-	"github-actions-exporter/pkg/client"
+	"fmt"
+	"github-actions-exporter/pkg/server/collector"
+	"github-actions-exporter/pkg/server/handler"
 	"github-actions-exporter/pkg/server/processor"
+	"github-actions-exporter/pkg/server/shard"
+	"github-actions-exporter/pkg/server/web"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,46 +17,188 @@ import (
 )
 
 func Run(a *Args) error {
+	if a.EnableRunTraces && a.Backend != "rest" {
+		return xerrors.Errorf("--enable-run-traces requires --backend=rest, since runs of the %s backend have no start time", a.Backend)
+	}
+	if a.ActorTopN > 0 && a.Backend != "rest" {
+		return xerrors.Errorf("--actor-top-n requires --backend=rest, since runs of the %s backend have no triggering actor", a.Backend)
+	}
 	i := NewInstance()
-	logger := client.NewStandardLogger(a.Verbose)
+	logger, err := newLogger(a, os.Stdout)
+	if err != nil {
+		return xerrors.Errorf("failed to create logger: %w", err)
+	}
 	i.SetLogger(logger)
 
+	shardIndex, err := resolveShardIndex(a)
+	if err != nil {
+		return xerrors.Errorf("failed to resolve shard index: %w", err)
+	}
+	repositories := shard.Filter(a.Repositories, shardIndex, a.ShardCount)
+	i.Logger().Infow("Shard is resolved",
+		"shard_index", shardIndex,
+		"shard_count", a.ShardCount,
+		"repositories", len(repositories),
+	)
+
+	store, err := newStore(i, a)
+	if err != nil {
+		return xerrors.Errorf("failed to create state store: %w", err)
+	}
+	gitHubClient, err := newGitHubClient(i, a, shardIndex, store)
+	if err != nil {
+		return xerrors.Errorf("failed to create github client: %w", err)
+	}
+	var webConfig *web.Config
+	if a.WebConfigFile != "" {
+		webConfig, err = web.LoadConfig(a.WebConfigFile)
+		if err != nil {
+			return xerrors.Errorf("failed to load web config: %w", err)
+		}
+	}
+	policy, err := newLabelPolicy(a)
+	if err != nil {
+		return xerrors.Errorf("failed to create label policy: %w", err)
+	}
+	deployments, err := newDeploymentMatcher(a)
+	if err != nil {
+		return xerrors.Errorf("failed to create deployment matcher: %w", err)
+	}
+	statusRecorder := collector.NewStatusRecorder()
+	config, err := redactedConfig(a)
+	if err != nil {
+		return xerrors.Errorf("failed to redact config: %w", err)
+	}
+
+	var elector *processor.Elector
 	api, err := processor.NewAPI(processor.APISettings{
 		Address:              a.APIAddress,
 		MaxConnections:       a.APIMaxConnections,
 		ReUsePort:            a.ReUsePort,
 		KeepAlived:           a.KeepAlived,
 		TCPKeepAliveInterval: time.Duration(a.TCPKeepAliveInterval) * time.Second,
-		Logger:               i.Logger(),
+		Status: func() handler.Status {
+			return handler.Status{
+				Collectors: statusRecorder.Statuses(),
+				RateLimits: gitHubClient.rateLimits.RateLimits(),
+				Config:     config,
+			}
+		},
+		Ready: func() error {
+			if err := gitHubClient.authorized.AuthError(); err != nil {
+				return err
+			}
+			if elector != nil && !elector.IsLeader() {
+				return xerrors.New("not the leader")
+			}
+			return statusRecorder.Ready()
+		},
+		WebConfig: webConfig,
+		Logger:    i.Logger(),
 	})
 	if err != nil {
 		return xerrors.Errorf("failed to create api: %w", err)
 	}
 	i.AddProcessor(api)
 
+	if a.EnableLeaderElection {
+		kubernetesClient, err := i.KubernetesClient()
+		if err != nil {
+			return xerrors.Errorf("failed to get kubernetes client: %w", err)
+		}
+		namespace, err := currentNamespace()
+		if err != nil {
+			return xerrors.Errorf("failed to get current namespace: %w", err)
+		}
+		identity, err := os.Hostname()
+		if err != nil {
+			return xerrors.Errorf("failed to get hostname: %w", err)
+		}
+		lease := a.LeaderElectionLease
+		if a.ShardCount > 1 {
+			lease = fmt.Sprintf("%s-%d", lease, shardIndex)
+		}
+		elector, err = processor.NewElector(processor.ElectorSettings{
+			KubernetesClient: kubernetesClient,
+			Namespace:        namespace,
+			Name:             lease,
+			Identity:         identity,
+			LeaseDuration:    time.Duration(a.LeaderElectionLeaseDuration) * time.Second,
+			RenewDeadline:    time.Duration(a.LeaderElectionRenewDeadline) * time.Second,
+			RetryPeriod:      time.Duration(a.LeaderElectionRetryPeriod) * time.Second,
+			Logger:           i.Logger(),
+		})
+		if err != nil {
+			return xerrors.Errorf("failed to create elector: %w", err)
+		}
+		i.AddProcessor(elector)
+	}
+
 	monitor, err := processor.NewMonitor(processor.MonitorSettings{
-		Address:                        a.MonitorAddress,
-		MaxConnections:                 a.MonitorMaxConnections,
-		JaegerEndpoint:                 a.MonitoringJaegerEndpoint,
-		EnableProfiling:                a.EnableProfiling,
-		EnableTracing:                  a.EnableTracing,
-		TracingSampleRate:              a.TracingSampleRate,
-		ReUsePort:                      a.ReUsePort,
-		KeepAlived:                     a.KeepAlived,
-		TCPKeepAliveInterval:           time.Duration(a.TCPKeepAliveInterval) * time.Second,
-		RunsCollectorLoopInterval:      time.Duration(a.RunsCollectorLoopInterval) * time.Second,
-		RunnersCollectorLoopInterval:   time.Duration(a.RunnersCollectorLoopInterval) * time.Second,
-		WorkflowsCollectorLoopInterval: time.Duration(a.WorkflowsCollectorLoopInterval) * time.Second,
-		HTTPClient:                     i.HTTPClient(),
-		Logger:                         i.Logger(),
-		Repository:                     a.Repository,
-		Token:                          a.Token,
+		Address:                            a.MonitorAddress,
+		MaxConnections:                     a.MonitorMaxConnections,
+		JaegerEndpoint:                     a.MonitoringJaegerEndpoint,
+		EnableProfiling:                    a.EnableProfiling,
+		EnableTracing:                      a.EnableTracing,
+		TracingSampleRate:                  a.TracingSampleRate,
+		TracingExporter:                    a.TracingExporter,
+		OTLPEndpoint:                       a.OTLPEndpoint,
+		OTLPHeaders:                        a.OTLPHeaders,
+		ReUsePort:                          a.ReUsePort,
+		KeepAlived:                         a.KeepAlived,
+		TCPKeepAliveInterval:               time.Duration(a.TCPKeepAliveInterval) * time.Second,
+		WebConfig:                          webConfig,
+		RunsCollectorLoopInterval:          time.Duration(a.RunsCollectorLoopInterval) * time.Second,
+		RunnersCollectorLoopInterval:       time.Duration(a.RunnersCollectorLoopInterval) * time.Second,
+		WorkflowsCollectorLoopInterval:     time.Duration(a.WorkflowsCollectorLoopInterval) * time.Second,
+		RunTracesCollectorLoopInterval:     time.Duration(a.RunTracesCollectorLoopInterval) * time.Second,
+		PullRequestsCollectorLoopInterval:  time.Duration(a.PullRequestsCollectorLoopInterval) * time.Second,
+		FlakyCollectorLoopInterval:         time.Duration(a.FlakyCollectorLoopInterval) * time.Second,
+		SecurityCollectorLoopInterval:      time.Duration(a.SecurityCollectorLoopInterval) * time.Second,
+		WorkflowFilesCollectorLoopInterval: time.Duration(a.WorkflowFilesCollectorLoopInterval) * time.Second,
+		EnableRunTraces:                    a.EnableRunTraces,
+		BackendCacheTTL:                    backendCacheTTL(a),
+		RunTracesServiceName:               a.RunTracesServiceName,
+		HTTPClient:                         gitHubClient,
+		Store:                              store,
+		StatusRecorder:                     statusRecorder,
+		LabelPolicy:                        policy,
+		Deployments:                        deployments,
+		RequiredWorkflowPattern:            a.RequiredWorkflowPattern,
+		ActorTopN:                          a.ActorTopN,
+		Elector:                            elector,
+		Logger:                             i.Logger(),
+		Repositories:                       repositories,
+		Backend:                            a.Backend,
+		Collectors:                         a.Collectors,
 	})
 	if err != nil {
 		return xerrors.Errorf("failed to create monitor: %w", err)
 	}
 	i.AddProcessor(monitor)
 
+	if a.PushTarget != "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return xerrors.Errorf("failed to get hostname: %w", err)
+		}
+		pusher, err := processor.NewPusher(processor.PusherSettings{
+			Target:     a.PushTarget,
+			Endpoint:   a.PushEndpoint,
+			Job:        a.PushJob,
+			Instance:   hostname,
+			Headers:    a.PushHeaders,
+			Interval:   time.Duration(a.PushInterval) * time.Second,
+			Gatherer:   monitor.Gatherer(),
+			HTTPClient: i.HTTPClient(),
+			Logger:     i.Logger(),
+		})
+		if err != nil {
+			return xerrors.Errorf("failed to create pusher: %w", err)
+		}
+		i.AddProcessor(pusher)
+	}
+
 	i.Start()
 
 	quit := make(chan os.Signal, 1)
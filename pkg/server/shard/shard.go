@@ -0,0 +1,58 @@
+package shard
+
+import (
+	"hash/fnv"
+	"regexp"
+	"strconv"
+
+	"golang.org/x/xerrors"
+)
+
+var (
+	ordinalPattern = regexp.MustCompile(`-(\d+)$`)
+)
+
+func score(repository string, index int) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(repository))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(strconv.Itoa(index)))
+	return h.Sum64()
+}
+
+func Owner(repository string, count int) int {
+	owner := 0
+	var max uint64
+	for i := 0; i < count; i++ {
+		if s := score(repository, i); i == 0 || s > max {
+			owner = i
+			max = s
+		}
+	}
+	return owner
+}
+
+func Filter(repositories []string, index int, count int) []string {
+	if count <= 1 {
+		return repositories
+	}
+	filtered := []string{}
+	for _, repository := range repositories {
+		if Owner(repository, count) == index {
+			filtered = append(filtered, repository)
+		}
+	}
+	return filtered
+}
+
+func OrdinalFromHostname(hostname string) (int, error) {
+	m := ordinalPattern.FindStringSubmatch(hostname)
+	if m == nil {
+		return 0, xerrors.Errorf("%s has no ordinal", hostname)
+	}
+	ordinal, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, xerrors.Errorf("failed to parse ordinal of %s: %w", hostname, err)
+	}
+	return ordinal, nil
+}
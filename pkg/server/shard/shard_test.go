@@ -0,0 +1,123 @@
+package shard_test
+
+import (
+	"fmt"
+	"github-actions-exporter/pkg/server/shard"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func fakeRepositories(n int) []string {
+	repositories := make([]string, n)
+	for i := 0; i < n; i++ {
+		repositories[i] = fmt.Sprintf("fake/fake%d", i)
+	}
+	return repositories
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    []string
+		count int
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			fakeRepositories(100),
+			1,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			fakeRepositories(100),
+			3,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		in := tt.in
+		count := tt.count
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for i := 0; i < count; i++ {
+				filtered := shard.Filter(in, i, count)
+				if count > 1 && len(filtered) == len(in) {
+					t.Errorf("shard %d owns every repository", i)
+				}
+				got = append(got, filtered...)
+			}
+			sort.Strings(got)
+			want := append([]string{}, in...)
+			sort.Strings(want)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+
+			for _, repository := range in {
+				before := shard.Owner(repository, count)
+				after := shard.Owner(repository, count+1)
+				if after != before && after != count {
+					t.Errorf("%s moved from %d to %d", repository, before, after)
+				}
+			}
+		})
+	}
+}
+
+func TestOrdinalFromHostname(t *testing.T) {
+	tests := []struct {
+		name            string
+		in              string
+		want            int
+		wantErrorString string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			"github-actions-exporter-2",
+			2,
+			"",
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			"fake",
+			0,
+			"fake has no ordinal",
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		in := tt.in
+		want := tt.want
+		wantErrorString := tt.wantErrorString
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := shard.OrdinalFromHostname(in)
+			if err != nil {
+				if diff := cmp.Diff(wantErrorString, err.Error()); diff != "" {
+					t.Errorf("(-want +got):\n%s", diff)
+				}
+				return
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
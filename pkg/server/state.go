@@ -0,0 +1,59 @@
+package server
+
+import (
+	"github-actions-exporter/pkg/server/store"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	serviceAccountNamespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+type IStore interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+}
+
+func currentNamespace() (string, error) {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace, nil
+	}
+	b, err := ioutil.ReadFile(serviceAccountNamespacePath)
+	if err != nil {
+		return "", xerrors.Errorf("failed to read %s: %w", serviceAccountNamespacePath, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func newStore(i *Instance, a *Args) (IStore, error) {
+	switch a.StateStore {
+	case "memory":
+		return store.NewMemoryStore(), nil
+	case "file":
+		fileStore, err := store.NewFileStore(a.StateFile)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create file store: %w", err)
+		}
+		return fileStore, nil
+	case "configmap":
+		kubernetesClient, err := i.KubernetesClient()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to get kubernetes client: %w", err)
+		}
+		namespace, err := currentNamespace()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to get current namespace: %w", err)
+		}
+		configMapStore, err := store.NewConfigMapStore(kubernetesClient, namespace, a.StateConfigMap)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create configmap store: %w", err)
+		}
+		return configMapStore, nil
+	default:
+		return nil, xerrors.Errorf("unknown state store: %s", a.StateStore)
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+
+	"golang.org/x/xerrors"
+)
+
+const redacted = "REDACTED"
+
+var (
+	secretArgs = []string{
+		"Tokens",
+		"OTLPHeaders",
+		"PushHeaders",
+	}
+)
+
+func redactedConfig(a *Args) (map[string]interface{}, error) {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to marshal args: %w", err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, xerrors.Errorf("failed to unmarshal args: %w", err)
+	}
+	for _, key := range secretArgs {
+		switch v := config[key].(type) {
+		case string:
+			if v != "" {
+				config[key] = redacted
+			}
+		case []interface{}:
+			for i := range v {
+				v[i] = redacted
+			}
+		case map[string]interface{}:
+			for k := range v {
+				v[k] = redacted
+			}
+		}
+	}
+	return config, nil
+}
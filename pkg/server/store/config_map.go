@@ -0,0 +1,86 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+var (
+	configMapKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+)
+
+type ConfigMapStore struct {
+	kubernetesClient IKubernetesClient
+	namespace        string
+	name             string
+	mutex            sync.Mutex
+}
+
+func NewConfigMapStore(kubernetesClient IKubernetesClient, namespace string, name string) (*ConfigMapStore, error) {
+	s := &ConfigMapStore{
+		kubernetesClient: kubernetesClient,
+		namespace:        namespace,
+		name:             name,
+	}
+	if _, err := s.kubernetesClient.CoreV1().ConfigMaps(s.namespace).Get(s.name, metav1.GetOptions{}); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, xerrors.Errorf("failed to get configmap %s/%s: %w", s.namespace, s.name, err)
+		}
+		if _, err := s.kubernetesClient.CoreV1().ConfigMaps(s.namespace).Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.name,
+				Namespace: s.namespace,
+			},
+		}); err != nil && !errors.IsAlreadyExists(err) {
+			return nil, xerrors.Errorf("failed to create configmap %s/%s: %w", s.namespace, s.name, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *ConfigMapStore) encodeKey(key string) string {
+	k := strings.ReplaceAll(key, "/", ".")
+	if configMapKeyPattern.MatchString(k) && len(k) <= 253 {
+		return k
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *ConfigMapStore) Get(key string) ([]byte, error) {
+	configMap, err := s.kubernetesClient.CoreV1().ConfigMaps(s.namespace).Get(s.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get configmap %s/%s: %w", s.namespace, s.name, err)
+	}
+	return configMap.BinaryData[s.encodeKey(key)], nil
+}
+
+func (s *ConfigMapStore) Put(key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	k := s.encodeKey(key)
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := s.kubernetesClient.CoreV1().ConfigMaps(s.namespace).Get(s.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if configMap.BinaryData == nil {
+			configMap.BinaryData = make(map[string][]byte)
+		}
+		configMap.BinaryData[k] = value
+		_, err = s.kubernetesClient.CoreV1().ConfigMaps(s.namespace).Update(configMap)
+		return err
+	}); err != nil {
+		return xerrors.Errorf("failed to update configmap %s/%s: %w", s.namespace, s.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package store_test
+
+import (
+	"fmt"
+	"github-actions-exporter/pkg/server/store"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+type kubernetesClientMock struct {
+	kubernetes.Interface
+	configMaps *configMapsMock
+}
+
+func (c *kubernetesClientMock) CoreV1() typedcorev1.CoreV1Interface {
+	return &coreV1Mock{configMaps: c.configMaps}
+}
+
+type coreV1Mock struct {
+	typedcorev1.CoreV1Interface
+	configMaps *configMapsMock
+}
+
+func (c *coreV1Mock) ConfigMaps(namespace string) typedcorev1.ConfigMapInterface {
+	return c.configMaps
+}
+
+type configMapsMock struct {
+	typedcorev1.ConfigMapInterface
+	mutex     sync.Mutex
+	configMap *corev1.ConfigMap
+	conflicts int
+	updates   int
+}
+
+func (m *configMapsMock) Get(name string, options metav1.GetOptions) (*corev1.ConfigMap, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.configMap == nil {
+		return nil, errors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+	}
+	return m.configMap.DeepCopy(), nil
+}
+
+func (m *configMapsMock) Create(configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.configMap != nil {
+		return nil, errors.NewAlreadyExists(schema.GroupResource{Resource: "configmaps"}, configMap.Name)
+	}
+	m.configMap = configMap.DeepCopy()
+	return configMap, nil
+}
+
+func (m *configMapsMock) Update(configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.updates++
+	if m.conflicts > 0 {
+		m.conflicts--
+		return nil, errors.NewConflict(schema.GroupResource{Resource: "configmaps"}, configMap.Name, fmt.Errorf("fake"))
+	}
+	m.configMap = configMap.DeepCopy()
+	return configMap, nil
+}
+
+func TestConfigMapStore(t *testing.T) {
+	type in struct {
+		key   string
+		value []byte
+	}
+
+	longKey := "runs/" + strings.Repeat("fake", 64)
+
+	tests := []struct {
+		name        string
+		configMaps  *configMapsMock
+		in          []in
+		want        map[string][]byte
+		wantKeys    []string
+		wantUpdates int
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			&configMapsMock{},
+			[]in{
+				{
+					"runs/fake/fake",
+					[]byte("fake1"),
+				},
+				{
+					"runs/fake/fake",
+					[]byte("fake2"),
+				},
+				{
+					longKey,
+					[]byte("fake3"),
+				},
+			},
+			map[string][]byte{
+				"runs/fake/fake": []byte("fake2"),
+				longKey:          []byte("fake3"),
+				"unknown":        nil,
+			},
+			[]string{
+				"runs.fake.fake",
+				"bdb6c8911a3e84ccf5c355f68d07d36378659055609273df9384b0623bc593cd",
+			},
+			3,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			&configMapsMock{
+				configMap: &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "fake",
+						Namespace: "fake",
+					},
+					BinaryData: map[string][]byte{
+						"workflows.fake.fake": []byte("fake1"),
+					},
+				},
+				conflicts: 2,
+			},
+			[]in{
+				{
+					"runs/fake/fake",
+					[]byte("fake2"),
+				},
+			},
+			map[string][]byte{
+				"workflows/fake/fake": []byte("fake1"),
+				"runs/fake/fake":      []byte("fake2"),
+			},
+			[]string{
+				"runs.fake.fake",
+				"workflows.fake.fake",
+			},
+			3,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		configMaps := tt.configMaps
+		in := tt.in
+		want := tt.want
+		wantKeys := tt.wantKeys
+		wantUpdates := tt.wantUpdates
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := store.NewConfigMapStore(&kubernetesClientMock{configMaps: configMaps}, "fake", "fake")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, i := range in {
+				if err := s.Put(i.key, i.value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for key, value := range want {
+				got, err := s.Get(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(value, got); diff != "" {
+					t.Errorf("(-want +got):\n%s", diff)
+				}
+			}
+			var gotKeys []string
+			for key := range configMaps.configMap.BinaryData {
+				gotKeys = append(gotKeys, key)
+			}
+			if diff := cmp.Diff(wantKeys, gotKeys, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(wantUpdates, configMaps.updates); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
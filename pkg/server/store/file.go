@@ -0,0 +1,60 @@
+package store
+
+import (
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/xerrors"
+)
+
+var (
+	bucketName = []byte("state")
+)
+
+type FileStore struct {
+	db *bolt.DB
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open %s: %w", path, err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to create bucket: %w", err)
+	}
+	return &FileStore{
+		db: db,
+	}, nil
+}
+
+func (s *FileStore) Get(key string) ([]byte, error) {
+	var value []byte
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketName).Get([]byte(key))
+		if v != nil {
+			value = make([]byte, len(v))
+			copy(value, v)
+		}
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to get %s: %w", key, err)
+	}
+	return value, nil
+}
+
+func (s *FileStore) Put(key string, value []byte) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(key), value)
+	}); err != nil {
+		return xerrors.Errorf("failed to put %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *FileStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,90 @@
+package store_test
+
+import (
+	"fmt"
+	"github-actions-exporter/pkg/server/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileStore(t *testing.T) {
+	type in struct {
+		key   string
+		value []byte
+	}
+
+	tests := []struct {
+		name string
+		in   []in
+		want map[string][]byte
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			[]in{
+				{
+					"runs/fake/fake",
+					[]byte("fake1"),
+				},
+				{
+					"runs/fake/fake",
+					[]byte("fake2"),
+				},
+			},
+			map[string][]byte{
+				"runs/fake/fake": []byte("fake2"),
+				"unknown":        nil,
+			},
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		in := tt.in
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir, err := ioutil.TempDir("", "store")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "state.db")
+
+			s, err := store.NewFileStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, i := range in {
+				if err := s.Put(i.key, i.value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			s, err = store.NewFileStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			for key, value := range want {
+				got, err := s.Get(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(value, got); diff != "" {
+					t.Errorf("(-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package store
+
+import (
+	"k8s.io/client-go/kubernetes"
+)
+
+type IKubernetesClient interface {
+	kubernetes.Interface
+}
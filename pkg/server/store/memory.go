@@ -0,0 +1,29 @@
+package store
+
+import (
+	"sync"
+)
+
+type MemoryStore struct {
+	mutex sync.RWMutex
+	m     map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		m: make(map[string][]byte),
+	}
+}
+
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.m[key], nil
+}
+
+func (s *MemoryStore) Put(key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.m[key] = value
+	return nil
+}
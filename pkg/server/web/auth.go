@@ -0,0 +1,90 @@
+package web
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	dummyHash = []byte("$2a$10$h9IVidsGNBCRYM91qoYc8u3tJBytJ/lnQ2hWMa2OrDP/xoPmqkrgu")
+)
+
+type authenticator struct {
+	basicAuthUsers map[string]string
+	bearerTokens   []string
+	mutex          sync.Mutex
+	verified       map[[sha256.Size]byte]bool
+}
+
+func NewAuthMiddleware(config *Config, exemptPaths ...string) func(http.Handler) http.Handler {
+	if config == nil || (len(config.BasicAuthUsers) == 0 && len(config.BearerTokens) == 0) {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+	a := &authenticator{
+		basicAuthUsers: config.BasicAuthUsers,
+		bearerTokens:   config.BearerTokens,
+		verified:       make(map[[sha256.Size]byte]bool),
+	}
+	exempt := make(map[string]bool)
+	for _, path := range exemptPaths {
+		exempt[path] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempt[r.URL.Path] || a.authenticate(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(a.basicAuthUsers) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="github-actions-exporter"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
+
+func (a *authenticator) authenticate(r *http.Request) bool {
+	if user, password, ok := r.BasicAuth(); ok && len(a.basicAuthUsers) > 0 {
+		hash, ok := a.basicAuthUsers[user]
+		if !ok {
+			_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+			return false
+		}
+		return a.verify("basic\x00"+user+"\x00"+password, hash, password)
+	}
+	authorization := r.Header.Get("Authorization")
+	if strings.HasPrefix(authorization, "Bearer ") && len(a.bearerTokens) > 0 {
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		for _, hash := range a.bearerTokens {
+			if a.verify("bearer\x00"+hash+"\x00"+token, hash, token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *authenticator) verify(key string, hash string, secret string) bool {
+	sum := sha256.Sum256([]byte(key))
+	a.mutex.Lock()
+	verified := a.verified[sum]
+	a.mutex.Unlock()
+	if verified {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) != nil {
+		return false
+	}
+	a.mutex.Lock()
+	a.verified[sum] = true
+	a.mutex.Unlock()
+	return true
+}
@@ -0,0 +1,145 @@
+package web_test
+
+import (
+	"fmt"
+	"github-actions-exporter/pkg/server/web"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	config := &web.Config{
+		BasicAuthUsers: map[string]string{
+			"fake": "$2a$04$.4UbaNnW684W84KUcLCSUOVZQmDRU7wMzZL1L/ykFqkw4uInGqZd6",
+		},
+		BearerTokens: []string{
+			"$2a$04$4.nJu0g3HqUurQnv60TYLuJKJPmMkWK9TRjnW.Lmp6CEFi4A8ueg2",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		config *web.Config
+		in     func() *http.Request
+		want   int
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			nil,
+			func() *http.Request {
+				return httptest.NewRequest("GET", "/metrics", nil)
+			},
+			http.StatusOK,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			config,
+			func() *http.Request {
+				return httptest.NewRequest("GET", "/metrics", nil)
+			},
+			http.StatusUnauthorized,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			config,
+			func() *http.Request {
+				return httptest.NewRequest("GET", "/healthz", nil)
+			},
+			http.StatusOK,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			config,
+			func() *http.Request {
+				request := httptest.NewRequest("GET", "/metrics", nil)
+				request.SetBasicAuth("fake", "fake-password")
+				return request
+			},
+			http.StatusOK,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			config,
+			func() *http.Request {
+				request := httptest.NewRequest("GET", "/metrics", nil)
+				request.SetBasicAuth("fake", "wrong")
+				return request
+			},
+			http.StatusUnauthorized,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			config,
+			func() *http.Request {
+				request := httptest.NewRequest("GET", "/metrics", nil)
+				request.SetBasicAuth("unknown", "fake-password")
+				return request
+			},
+			http.StatusUnauthorized,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			config,
+			func() *http.Request {
+				request := httptest.NewRequest("GET", "/metrics", nil)
+				request.Header.Set("Authorization", "Bearer fake-token")
+				return request
+			},
+			http.StatusOK,
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			config,
+			func() *http.Request {
+				request := httptest.NewRequest("GET", "/metrics", nil)
+				request.Header.Set("Authorization", "Bearer wrong")
+				return request
+			},
+			http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		config := tt.config
+		in := tt.in
+		want := tt.want
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver := web.NewAuthMiddleware(config, "/healthz")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			for i := 0; i < 2; i++ {
+				got := httptest.NewRecorder()
+				receiver.ServeHTTP(got, in())
+				if got.Code != want {
+					t.Errorf("want %d, but got %d", want, got.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	tlsVersions = map[string]uint16{
+		"TLS10": tls.VersionTLS10,
+		"TLS11": tls.VersionTLS11,
+		"TLS12": tls.VersionTLS12,
+		"TLS13": tls.VersionTLS13,
+	}
+	clientAuthTypes = map[string]tls.ClientAuthType{
+		"":                           tls.NoClientCert,
+		"NoClientCert":               tls.NoClientCert,
+		"RequestClientCert":          tls.RequestClientCert,
+		"RequireAnyClientCert":       tls.RequireAnyClientCert,
+		"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+		"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+	}
+)
+
+type TLSServerConfig struct {
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	ClientAuthType string `yaml:"client_auth_type"`
+	ClientCAFile   string `yaml:"client_ca_file"`
+	MinVersion     string `yaml:"min_version"`
+	MaxVersion     string `yaml:"max_version"`
+}
+
+type Config struct {
+	TLSServerConfig *TLSServerConfig  `yaml:"tls_server_config"`
+	BasicAuthUsers  map[string]string `yaml:"basic_auth_users"`
+	BearerTokens    []string          `yaml:"bearer_tokens"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", path, err)
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(b, config); err != nil {
+		return nil, xerrors.Errorf("failed to parse %s: %w", path, err)
+	}
+	if config.TLSServerConfig != nil {
+		if _, err := config.TLSConfig(); err != nil {
+			return nil, xerrors.Errorf("invalid tls_server_config: %w", err)
+		}
+	}
+	return config, nil
+}
+
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	if c == nil || c.TLSServerConfig == nil {
+		return nil, nil
+	}
+	s := c.TLSServerConfig
+	if s.CertFile == "" || s.KeyFile == "" {
+		return nil, xerrors.New("cert_file and key_file are required")
+	}
+	reloader := &certificateReloader{
+		certFile: s.CertFile,
+		keyFile:  s.KeyFile,
+	}
+	if _, err := reloader.GetCertificate(nil); err != nil {
+		return nil, xerrors.Errorf("failed to load certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+	if s.MinVersion != "" {
+		version, ok := tlsVersions[s.MinVersion]
+		if !ok {
+			return nil, xerrors.Errorf("unknown min_version: %s", s.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+	if s.MaxVersion != "" {
+		version, ok := tlsVersions[s.MaxVersion]
+		if !ok {
+			return nil, xerrors.Errorf("unknown max_version: %s", s.MaxVersion)
+		}
+		tlsConfig.MaxVersion = version
+	}
+	clientAuth, ok := clientAuthTypes[s.ClientAuthType]
+	if !ok {
+		return nil, xerrors.Errorf("unknown client_auth_type: %s", s.ClientAuthType)
+	}
+	tlsConfig.ClientAuth = clientAuth
+	if s.ClientCAFile != "" {
+		b, err := ioutil.ReadFile(s.ClientCAFile)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read %s: %w", s.ClientCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, xerrors.Errorf("failed to parse %s", s.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	} else if clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert {
+		return nil, xerrors.Errorf("client_ca_file is required for %s", s.ClientAuthType)
+	}
+	return tlsConfig, nil
+}
+
+type certificateReloader struct {
+	certFile    string
+	keyFile     string
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+	modTime     time.Time
+}
+
+func (r *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to stat %s: %w", r.certFile, err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to stat %s: %w", r.keyFile, err)
+	}
+	modTime := certInfo.ModTime()
+	if keyInfo.ModTime().After(modTime) {
+		modTime = keyInfo.ModTime()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.certificate != nil && !modTime.After(r.modTime) {
+		return r.certificate, nil
+	}
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.certificate != nil {
+			return r.certificate, nil
+		}
+		return nil, xerrors.Errorf("failed to load key pair: %w", err)
+	}
+	r.certificate = &certificate
+	r.modTime = modTime
+	return r.certificate, nil
+}
@@ -0,0 +1,148 @@
+package web_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github-actions-exporter/pkg/server/web"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeCertificate(t *testing.T, dir string, commonName string, modTime time.Time) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"tls.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		"tls.key": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+	}
+	for name, b := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		in              string
+		wantErrorString string
+	}{
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			`
+tls_server_config:
+  cert_file: {{dir}}/tls.crt
+  key_file: {{dir}}/tls.key
+  min_version: TLS13
+basic_auth_users:
+  fake: $2a$04$.4UbaNnW684W84KUcLCSUOVZQmDRU7wMzZL1L/ykFqkw4uInGqZd6
+`,
+			"",
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			`
+tls_server_config:
+  cert_file: {{dir}}/tls.crt
+  key_file: {{dir}}/tls.key
+  client_auth_type: RequireAndVerifyClientCert
+`,
+			"client_ca_file is required for RequireAndVerifyClientCert",
+		},
+		{
+			func() string {
+				_, _, line, _ := runtime.Caller(1)
+				return fmt.Sprintf("L%d", line)
+			}(),
+			`
+unknown: true
+`,
+			"field unknown not found",
+		},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		in := tt.in
+		wantErrorString := tt.wantErrorString
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir, err := ioutil.TempDir("", "web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			writeCertificate(t, dir, "fake1", time.Now().Add(-time.Minute))
+			path := filepath.Join(dir, "web.yaml")
+			if err := ioutil.WriteFile(path, []byte(strings.ReplaceAll(in, "{{dir}}", dir)), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := web.LoadConfig(path)
+			if wantErrorString != "" {
+				if err == nil || !strings.Contains(err.Error(), wantErrorString) {
+					t.Fatalf("want %q, but got %v", wantErrorString, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tlsConfig, err := config.TLSConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			commonName := func() string {
+				certificate, err := tlsConfig.GetCertificate(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				parsed, err := x509.ParseCertificate(certificate.Certificate[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				return parsed.Subject.CommonName
+			}
+			if got := commonName(); got != "fake1" {
+				t.Errorf("want fake1, but got %s", got)
+			}
+			writeCertificate(t, dir, "fake2", time.Now())
+			if got := commonName(); got != "fake2" {
+				t.Errorf("want fake2, but got %s", got)
+			}
+		})
+	}
+}